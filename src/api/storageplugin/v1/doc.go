@@ -0,0 +1,26 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package storagepluginv1 contains gRPC protocol of out-of-process storage provider plugins.
+// Regenerate the code from src/api directory after modifying storage.proto:
+//
+//	protoc --go_out=. --go_opt=paths=source_relative \
+//	  --go-grpc_out=. --go-grpc_opt=paths=source_relative storageplugin/v1/storage.proto
+package storagepluginv1
+
+// ChunkSize is the size of data chunks sent in Put and Get streams.
+// gRPC limits messages to 4MiB by default, so chunks have to stay below it.
+const ChunkSize = 1 << 20
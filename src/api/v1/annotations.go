@@ -79,6 +79,20 @@ func ListAnnotations() Annotations {
 				Description: "Set to any value in case if you want to restore the backup",
 				Name:        AnnotationRestore,
 			},
+			{
+				Description: "Set to any value to put Object Lock legal hold on the backup file, remove to release it",
+				Name:        AnnotationLegalHold,
+			},
+			{
+				Description: "It is set by BackupImport on runs it creates, such runs are restored only on demand",
+				Name:        AnnotationImportedFrom,
+			},
+		},
+		"BackupImport": ClassAnnotations{
+			{
+				Description: "Can be set to any value to scan the storage again",
+				Name:        AnnotationTriggerImport,
+			},
 		},
 		"BackupSchedule": ClassAnnotations{
 			{
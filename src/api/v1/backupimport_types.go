@@ -0,0 +1,127 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// EDIT THIS FILE!  THIS IS SCAFFOLDING FOR YOU TO OWN!
+// NOTE: json tags are required.  Any new fields you add must have json tags for the fields to be serialized.
+
+/* BackupImportSpec defines the desired state of BackupImport. */
+type BackupImportSpec struct {
+	// INSERT ADDITIONAL SPEC FIELDS - desired state of cluster
+	// Important: Run "make" to regenerate code after modifying this file
+
+	/* Only files with this path prefix are imported.
+	Default: / */
+	//+kubebuilder:default="/"
+	//+kubebuilder:validation:Pattern=`^/`
+	//+kubebuilder:example="/mysql/"
+	Prefix string `json:"prefix" protobuf:"bytes,1,req,name=prefix"`
+
+	/* Optional regular expression the file path must match to be imported. */
+	//+kubebuilder:validation:MinLength=1
+	//+kubebuilder:validation:Optional
+	//+kubebuilder:example=`\.sql(\.gz)?(\.age)?$`
+	Pattern *string `json:"pattern,omitempty" protobuf:"bytes,2,opt,name=pattern"`
+
+	/* How often to scan the storage again, e.g. if backups are still made by another cluster.
+	If omitted, the storage is scanned once per BackupImport change. Minimum is 1m. */
+	//+kubebuilder:validation:Optional
+	//+kubebuilder:example="1h"
+	Interval *metav1.Duration `json:"interval,omitempty" protobuf:"bytes,3,opt,name=interval"`
+
+	/* BackupRun template for imported files. Files are scanned in the storage
+	set in .spec.template.spec.storage.name, while the path is set for every file.
+	Backup action is dropped and retain policy is always Retain, so runs are restore-only.
+	Compression is enabled for .gz files and encryption for .age files,
+	so set decryption key in .spec.template.spec.encryption if there are encrypted ones. */
+	Template *backupRunTemplate `json:"template" protobuf:"bytes,4,opt,name=template"`
+}
+
+/* BackupImportStatus defines the observed state of BackupImport. */
+type BackupImportStatus struct {
+	// INSERT ADDITIONAL STATUS FIELD - define observed state of cluster
+	// Important: Run "make" to regenerate code after modifying this file
+
+	// Conditions store
+	// +operator-sdk:csv:customresourcedefinitions:type=status
+	// +patchMergeKey=type
+	// +patchStrategy=merge
+	// +listType=map
+	// +listMapKey=type
+	//+kubebuilder:validation:Optional
+	Conditions []metav1.Condition `json:"conditions,omitempty" patchStrategy:"merge" patchMergeKey:"type" protobuf:"bytes,1,rep,name=conditions"`
+
+	/* Total count of runs created by the import. */
+	//+kubebuilder:default=0
+	//+kubebuilder:validation:Minimum=0
+	//+kubebuilder:validation:Optional
+	Imported *uint `json:"imported,omitempty" protobuf:"varint,2,opt,name=imported"`
+
+	/* Files skipped during the last scan with the reason. */
+	//+kubebuilder:validation:Optional
+	Skipped []SkippedObject `json:"skipped,omitempty" protobuf:"bytes,3,rep,name=skipped"`
+
+	/* Information when was the last time the storage was scanned. */
+	//+kubebuilder:validation:Optional
+	LastImportTime *metav1.Time `json:"lastImportTime,omitempty" protobuf:"bytes,4,opt,name=lastImportTime"`
+}
+
+/* SkippedObject is a file that could not be imported. */
+type SkippedObject struct {
+	/* Path of the file. */
+	Path string `json:"path" protobuf:"bytes,1,req,name=path"`
+
+	/* Why the file has been skipped. */
+	Reason string `json:"reason" protobuf:"bytes,2,req,name=reason"`
+}
+
+/*
+BackupImport makes existing backup files in BackupStorage visible to the operator.
+It creates restore-only BackupRun for every file no other run points at,
+e.g. after moving to another cluster. Created runs are not deleted with the import.
+*/
+//+kubebuilder:object:root=true
+//+kubebuilder:subresource:status
+//+kubebuilder:resource:shortName=bi
+//+kubebuilder:printcolumn:name="Ready",type=string,JSONPath=`.status.conditions[?(@.type=="Ready")].status`,description="Readiness"
+//+kubebuilder:printcolumn:name="Storage",type=string,JSONPath=`.spec.template.spec.storage.name`,description="Backup storage"
+//+kubebuilder:printcolumn:name="Prefix",type=string,JSONPath=`.spec.prefix`,description="Path prefix"
+//+kubebuilder:printcolumn:name="Imported",type=integer,JSONPath=`.status.imported`,description="Count of created runs"
+//+kubebuilder:printcolumn:name="Last Import",type=date,format=date-time,JSONPath=`.status.lastImportTime`,description="Last scan time"
+type BackupImport struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty" protobuf:"bytes,3,req,name=metadata"`
+
+	Spec   BackupImportSpec   `json:"spec,omitempty" protobuf:"bytes,4,req,name=metadata"`
+	Status BackupImportStatus `json:"status,omitempty" protobuf:"bytes,5,opt,name=metadata"`
+}
+
+/* BackupImportList contains a list of BackupImport. */
+//+kubebuilder:object:root=true
+type BackupImportList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty" protobuf:"bytes,3,opt,name=metadata"`
+	Items           []BackupImport `json:"items" protobuf:"bytes,4,req,name=items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&BackupImport{}, &BackupImportList{})
+}
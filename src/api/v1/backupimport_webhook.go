@@ -0,0 +1,115 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"strings"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+func (r *BackupImport) SetupWebhookWithManager(mgr ctrl.Manager) error {
+	webhookReader = mgr.GetAPIReader()
+	return ctrl.NewWebhookManagedBy(mgr).
+		For(r).
+		WithValidator(r).
+		Complete()
+}
+
+//+kubebuilder:webhook:path=/validate-backup-operator-io-v1-backupimport,mutating=false,failurePolicy=fail,sideEffects=None,groups=backup-operator.io,resources=backupimports,verbs=create;update,versions=v1,name=vbackupimport.kb.io,admissionReviewVersions=v1
+
+var _ webhook.CustomValidator = &BackupImport{}
+
+// ValidateCreate implements webhook.Validator so a webhook will be registered for the type
+func (r *BackupImport) ValidateCreate(ctx context.Context, obj runtime.Object) (admission.Warnings, error) {
+	return r.validate(ctx, nil, obj)
+}
+
+// ValidateUpdate implements webhook.Validator so a webhook will be registered for the type
+func (r *BackupImport) ValidateUpdate(ctx context.Context, oldObj, obj runtime.Object) (admission.Warnings, error) {
+	return r.validate(ctx, oldObj, obj)
+}
+
+// ValidateDelete implements webhook.Validator so a webhook will be registered for the type
+func (r *BackupImport) ValidateDelete(_ context.Context, _ runtime.Object) (admission.Warnings, error) {
+	return nil, nil
+}
+
+// validate checks the import namespace is allowed to use the storage of the template and to read
+// files with the prefix. Every imported file gets its own run, which is checked by the BackupRun
+// webhook anyway, but a forbidden import is reported right away instead of a skipped file per scan.
+// Access is checked on creation and on change of the storage or the prefix only, like for runs.
+func (r *BackupImport) validate(ctx context.Context, oldObj, obj runtime.Object) (admission.Warnings, error) {
+	log := log.FromContext(ctx)
+	backupImport, ok := obj.(*BackupImport)
+	if !ok {
+		return nil, fmt.Errorf("expected a BackupImport but got a %T", obj)
+	}
+	log.V(1).Info("Validating BackupImport")
+	if backupImport.Spec.Template == nil {
+		// Required by the schema
+		return nil, nil
+	}
+	var allErrs field.ErrorList
+	if old, ok := oldObj.(*BackupImport); !ok || old.Spec.Template == nil || old.Spec.Prefix != backupImport.Spec.Prefix ||
+		old.Spec.Template.Spec.Storage.Name != backupImport.Spec.Template.Spec.Storage.Name {
+		if err := backupImport.validateStorageAccess(ctx); err != nil {
+			allErrs = append(allErrs, err)
+		}
+	}
+	if len(allErrs) == 0 {
+		return nil, nil
+	}
+	return nil, apierrors.NewInvalid(
+		schema.GroupKind{
+			Group: backupImport.GroupVersionKind().Group,
+			Kind:  backupImport.Kind,
+		}, backupImport.Name, allErrs)
+}
+
+// validateStorageAccess checks the import namespace is allowed to use the storage and every path
+// with the import prefix starts with the forced prefix if any.
+func (r *BackupImport) validateStorageAccess(ctx context.Context) (err *field.Error) {
+	storageField := field.NewPath("spec").Child("template").Child("spec").Child("storage")
+	// Any file with the prefix has to be allowed, so the prefix itself must be inside the forced directory
+	storage := &backupStorage{Name: r.Spec.Template.Spec.Storage.Name, Path: r.Spec.Prefix}
+	if err = validateStorageAccess(ctx, r.Namespace, storage, storageField, prefixHasPrefix); err != nil &&
+		err.Field == storageField.Child("path").String() {
+		err.Field = field.NewPath("spec").Child("prefix").String()
+		err.BadValue = r.Spec.Prefix
+	}
+	return
+}
+
+// prefixHasPrefix checks every path with the import prefix is inside the prefix directory.
+// Only canonical prefixes are accepted like by pathHasPrefix, but a trailing slash is allowed.
+func prefixHasPrefix(importPrefix, prefix string) bool {
+	if path.Clean(importPrefix) != strings.TrimSuffix(importPrefix, "/") {
+		return false
+	}
+	return strings.HasPrefix(importPrefix, strings.TrimSuffix(prefix, "/")+"/")
+}
@@ -0,0 +1,40 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("BackupImport Webhook", func() {
+	Context("When checking the import prefix against the forced prefix", func() {
+		DescribeTable("prefixHasPrefix",
+			func(importPrefix, prefix string, expected bool) {
+				Expect(prefixHasPrefix(importPrefix, prefix)).To(Equal(expected))
+			},
+			Entry("directory prefix", "/tenant-a/", "/tenant-a", true),
+			Entry("file name prefix", "/tenant-a/db-", "/tenant-a/", true),
+			Entry("prefix itself as file name prefix", "/tenant-a", "/tenant-a", false),
+			Entry("sibling directory", "/tenant-ab/", "/tenant-a", false),
+			Entry("escaping with dots", "/tenant-a/../tenant-b/", "/tenant-a", false),
+			Entry("duplicate slashes", "//tenant-a/", "/tenant-a", false),
+			Entry("dot segment", "/tenant-a/./db-", "/tenant-a", false),
+			Entry("root", "/", "/tenant-a", false),
+		)
+	})
+})
@@ -27,4 +27,8 @@ var (
 	AnnotationRestoredAt = fmt.Sprintf("%s/restored-at", GroupVersion.Group)
 	// Set to any value in case if you want to restore the backup
 	AnnotationRestore = fmt.Sprintf("%s/restore", GroupVersion.Group)
+	// Set to any value to put Object Lock legal hold on the backup file, remove to release it
+	AnnotationLegalHold = fmt.Sprintf("%s/legal-hold", GroupVersion.Group)
+	// It is set by BackupImport on runs it creates, such runs are restored only on demand
+	AnnotationImportedFrom = fmt.Sprintf("%s/imported-from", GroupVersion.Group)
 )
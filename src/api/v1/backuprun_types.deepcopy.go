@@ -16,6 +16,8 @@ limitations under the License.
 
 package v1
 
+import "k8s.io/utils/ptr"
+
 func (in *BackupRunAction) DeepCopy() *BackupRunAction {
 	if in == nil {
 		return nil
@@ -40,6 +42,13 @@ func (in *backupCompression) DeepCopy() *backupCompression {
 
 func (in *backupCompression) DeepCopyInto(out *backupCompression) {
 	*out = *in
+	if in.Level != nil {
+		out.Level = ptr.To(*in.Level)
+	}
+	if in.BlockSize != nil {
+		blockSize := in.BlockSize.DeepCopy()
+		out.BlockSize = &blockSize
+	}
 }
 
 func (in *backupEncryption) DeepCopy() *backupEncryption {
@@ -67,3 +76,37 @@ func (in *pod) DeepCopy() *pod {
 func (in *pod) DeepCopyInto(out *pod) {
 	*out = *in
 }
+
+func (in *backupStorage) DeepCopy() *backupStorage {
+	if in == nil {
+		return nil
+	}
+	out := new(backupStorage)
+	in.DeepCopyInto(out)
+	return out
+}
+
+func (in *backupStorage) DeepCopyInto(out *backupStorage) {
+	*out = *in
+	if in.ObjectLock != nil {
+		out.ObjectLock = new(backupObjectLock)
+		*out.ObjectLock = *in.ObjectLock
+	}
+	if in.StorageClass != nil {
+		out.StorageClass = new(string)
+		*out.StorageClass = *in.StorageClass
+	}
+}
+
+func (in *backupChecksums) DeepCopy() *backupChecksums {
+	if in == nil {
+		return nil
+	}
+	out := new(backupChecksums)
+	in.DeepCopyInto(out)
+	return out
+}
+
+func (in *backupChecksums) DeepCopyInto(out *backupChecksums) {
+	*out = *in
+}
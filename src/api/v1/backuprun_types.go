@@ -17,8 +17,12 @@ limitations under the License.
 package v1
 
 import (
+	"fmt"
+
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/ptr"
 )
 
 // EDIT THIS FILE!  THIS IS SCAFFOLDING FOR YOU TO OWN!
@@ -110,48 +114,161 @@ type backupStorage struct {
 	//+kubebuilder:default=`{{ now | date "20060102-150405" | printf "/%s.backup" }}`
 	//+kubebuilder:example=`/my-backups/{{ now | date "2006.01.02-15:04:05" }}.tgz`
 	Path string `json:"path" protobuf:"bytes,2,req,name=path"`
+
+	/* Object Lock (WORM) retention of the backup file. Storage must support it,
+	e.g. S3 bucket with Object Lock enabled. Locked backup can not be deleted or overwritten
+	until the retention period is over, even with RetainPolicy set to Delete. */
+	//+kubebuilder:validation:Optional
+	ObjectLock *backupObjectLock `json:"objectLock,omitempty" protobuf:"bytes,3,opt,name=objectLock"`
+
+	/* Storage class of the backup file, it overrides the one from BackupStorage parameters.
+	Valid values depend on the storage, e.g. STANDARD_IA, GLACIER_IR or DEEP_ARCHIVE for S3.
+	Archived backups are rehydrated automatically before the restoration. */
+	//+kubebuilder:validation:Optional
+	//+kubebuilder:validation:MinLength=1
+	//+kubebuilder:example="STANDARD_IA"
+	StorageClass *string `json:"storageClass,omitempty" protobuf:"bytes,4,opt,name=storageClass"`
 }
 
-// +kubebuilder:validation:Enum=gzip
+// +kubebuilder:validation:Enum=GOVERNANCE;COMPLIANCE
+type objectLockMode string
+
+const (
+	// ObjectLockGovernance Users with special permissions may still delete the object
+	ObjectLockGovernance objectLockMode = "GOVERNANCE"
+	// ObjectLockCompliance Nobody, including root user, can delete the object
+	ObjectLockCompliance objectLockMode = "COMPLIANCE"
+)
+
+/* Object Lock retention options. */
+type backupObjectLock struct {
+	/* Retention mode.
+	Valid values: GOVERNANCE, COMPLIANCE
+	Default: GOVERNANCE */
+	//+kubebuilder:default="GOVERNANCE"
+	//+kubebuilder:example="COMPLIANCE"
+	Mode objectLockMode `json:"mode" protobuf:"bytes,1,req,name=mode"`
+
+	/* Retention period counted from the backup upload, e.g. 720h for 30 days. */
+	//+kubebuilder:example="720h"
+	Retention metav1.Duration `json:"retention" protobuf:"bytes,2,req,name=retention"`
+}
+
+// +kubebuilder:validation:Enum=gzip;zstd;lz4;xz
 type compressionAlgorithm string
 
 const (
 	// GZIP compression name
 	GZIP compressionAlgorithm = "gzip"
+	// ZSTD Zstandard compression name
+	ZSTD compressionAlgorithm = "zstd"
+	// LZ4 compression name
+	LZ4 compressionAlgorithm = "lz4"
+	// XZ compression name
+	XZ compressionAlgorithm = "xz"
 )
 
 /* Backup compression options. */
 type backupCompression struct {
-	/* Check https://pkg.go.dev/compress for available algorithms.
-	Valid values: gzip
+	/* Compression algorithm.
+	Valid values: gzip, zstd, lz4, xz
 	Example: gzip
 	Default: gzip */
 	//+kubebuilder:default="gzip"
 	//+kubebuilder:example="gzip"
 	Algorithm compressionAlgorithm `json:"algorithm" protobuf:"bytes,1,req,name=algorithm"`
 
-	/* Compression level according to https://pkg.go.dev/compress/flate#pkg-constants values.
-	May be equal to any number between BestSpeed and BestCompression.
-	Minimum: -2
-	Maximum: 9
-	Default: 0 */
-	//+kubebuilder:default=0
-	//+kubebuilder:validation:Minimum=-2
-	//+kubebuilder:validation:Maximum=9
-	Level int8 `json:"level" protobuf:"varint,2,req,name=level"`
+	/* Compression level, its range depends on the algorithm.
+	gzip: from -2 to 9 according to https://pkg.go.dev/compress/flate#pkg-constants values, 0 means no compression.
+	zstd: from 1 to 22 like zstd CLI levels, 0 means the default level 3. Negative levels like zstd --fast ones
+	down to -128 are accepted, but they are all mapped to the fastest encoder level as levels 1 and 2.
+	lz4: from 1 to 9 for high compression, 0 means the fast one.
+	xz: from 0 to 9 like xz CLI presets.
+	Default: 0 for gzip, zstd and lz4, preset 6 for xz */
+	//+kubebuilder:validation:Minimum=-128
+	//+kubebuilder:validation:Maximum=22
+	//+kubebuilder:validation:Optional
+	Level *int8 `json:"level,omitempty" protobuf:"varint,2,opt,name=level"`
+
+	/* Long distance matching finds repeated data up to 128MiB back at the cost of memory
+	on both compression and restoration. Supported by zstd only. */
+	//+kubebuilder:validation:Optional
+	LongDistanceMatching bool `json:"longDistanceMatching,omitempty" protobuf:"varint,3,opt,name=longDistanceMatching"`
+
+	/* Count of blocks compressed in parallel, every one is buffered in memory.
+	gzip output of parallel compression is standard, but a bit larger.
+	Default: 1 for zstd. Parallel gzip compression with a block per CPU for runs of schedules
+	whose previous backup has been larger than 1Gi, otherwise 1. */
+	//+kubebuilder:validation:Minimum=1
+	//+kubebuilder:validation:Maximum=64
+	//+kubebuilder:validation:Optional
+	Concurrency uint8 `json:"concurrency,omitempty" protobuf:"varint,4,opt,name=concurrency"`
+
+	/* Size of blocks compressed in parallel, from 64Ki to 64Mi. Supported by gzip only.
+	Default: 1Mi */
+	//+kubebuilder:validation:Optional
+	BlockSize *resource.Quantity `json:"blockSize,omitempty" protobuf:"bytes,5,opt,name=blockSize"`
+}
+
+// SetCompression enables compression with the algorithm, the options are kept if it is enabled with the same algorithm already
+func (s *BackupRunSpec) SetCompression(algorithm compressionAlgorithm) {
+	if s.Compression == nil || s.Compression.Algorithm != algorithm {
+		s.Compression = &backupCompression{}
+	}
+	s.Compression.Algorithm = algorithm
+}
+
+// Description Human readable algorithm and level with respect to the algorithm level semantics
+func (c *backupCompression) Description() string {
+	var description string
+	level := ptr.Deref(c.Level, 0)
+	switch {
+	case c.Algorithm == GZIP && level == -2:
+		description = "gzip with Huffman only compression"
+	case c.Algorithm == GZIP && level == -1:
+		description = "gzip with default level"
+	case c.Algorithm == GZIP && level == 0:
+		description = "gzip without compression"
+	case c.Algorithm == ZSTD && level == 0:
+		description = "zstd with default level 3"
+	case c.Algorithm == LZ4 && level == 0:
+		description = "lz4 with fast compression"
+	case c.Algorithm == LZ4:
+		description = fmt.Sprintf("lz4 with high compression level %d", level)
+	case c.Algorithm == XZ && c.Level == nil:
+		description = "xz with default preset 6"
+	case c.Algorithm == XZ:
+		description = fmt.Sprintf("xz with preset %d", level)
+	default:
+		description = fmt.Sprintf("%s with level %d", c.Algorithm, level)
+	}
+	if c.LongDistanceMatching {
+		description += ", long distance matching"
+	}
+	if c.Concurrency > 1 {
+		description += fmt.Sprintf(", %d blocks in parallel", c.Concurrency)
+	}
+	return description
 }
 
 /* Backup encryption options */
 type backupEncryption struct {
 	/* Recipients list to encrypt with.
 	We use Age for encryption https://github.com/FiloSottile/age.
-	Pattern: ^age1-.+|ssh-.+ */
+	Both age X25519 public keys and ssh-ed25519 or ssh-rsa SSH public keys are supported.
+	Pattern: ^age1.+|ssh-.+ */
 	//+kubebuilder:validation:MinItems=1
 	Recipients []string `json:"recipients" protobuf:"bytes,1,rep,name=recipients"`
 
-	/* Decryption key if you need automatic restoration. May be omitted. */
+	/* Decryption key if you need automatic restoration. May be omitted.
+	Either age X25519 secret key or SSH private key in PEM format. */
 	//+kubebuilder:validation:Optional
 	DecryptionKey *secretKeyReference `json:"decryptionKey,omitempty" protobuf:"bytes,2,opt,name=decryptionKey"`
+
+	/* Key of the decryption key secret with the passphrase of the SSH private key, if it is protected. */
+	//+kubebuilder:validation:MinLength=1
+	//+kubebuilder:validation:Optional
+	PassphraseKey string `json:"passphraseKey,omitempty" protobuf:"bytes,3,opt,name=passphraseKey"`
 }
 
 /* Backup Pod definition with metadata and spec. */
@@ -183,6 +300,14 @@ const (
 	BackupRunConditionTypeEncrypted BackupRunConditionType = "Encrypted"
 	// BackupRunConditionTypeCompressed Is compressed, message will contain algorithm
 	BackupRunConditionTypeCompressed BackupRunConditionType = "Compressed"
+	// BackupRunConditionTypeLegalHold Legal hold is applied to the backup file
+	BackupRunConditionTypeLegalHold BackupRunConditionType = "LegalHold"
+	// BackupRunConditionTypeDeletionBlocked Storage refuses to delete the backup file because of Object Lock
+	BackupRunConditionTypeDeletionBlocked BackupRunConditionType = "DeletionBlocked"
+	// BackupRunConditionTypeRehydrating Archived backup file is being restored to be available for download
+	BackupRunConditionTypeRehydrating BackupRunConditionType = "Rehydrating"
+	// BackupRunConditionTypeQuotaExceeded Backup is held because the storage is over its quota
+	BackupRunConditionTypeQuotaExceeded BackupRunConditionType = "QuotaExceeded"
 )
 
 /* BackupRunStatus defines the observed state of BackupRun. */
@@ -218,6 +343,28 @@ type BackupRunStatus struct {
 	//+kubebuilder:validation:Minimum=0
 	//+kubebuilder:validation:Optional
 	SizeInBytes *uint `json:"sizeInBytes,omitempty" protobuf:"varint,5,opt,name=sizeInBytes"`
+
+	/* SHA-256 checksums of the backup. */
+	//+kubebuilder:validation:Optional
+	Checksums *backupChecksums `json:"checksums,omitempty" protobuf:"bytes,6,opt,name=checksums"`
+}
+
+/* Hex encoded SHA-256 checksums of the backup data. */
+type backupChecksums struct {
+	/* Checksum of the data produced by the backup command, before compression and encryption. */
+	//+kubebuilder:validation:Pattern=`^[0-9a-f]{64}$`
+	//+kubebuilder:validation:Optional
+	Raw string `json:"raw,omitempty" protobuf:"bytes,1,opt,name=raw"`
+
+	/* Checksum of the file in the storage, it is verified on restoration. */
+	//+kubebuilder:validation:Pattern=`^[0-9a-f]{64}$`
+	//+kubebuilder:validation:Optional
+	Stored string `json:"stored,omitempty" protobuf:"bytes,2,opt,name=stored"`
+}
+
+// SetChecksums Set hex encoded checksums of the backup
+func (s *BackupRunStatus) SetChecksums(raw, stored string) {
+	s.Checksums = &backupChecksums{Raw: raw, Stored: stored}
 }
 
 /*
@@ -19,7 +19,9 @@ package v1
 import (
 	"context"
 	"fmt"
+	"math"
 	"regexp"
+	"slices"
 	"strings"
 
 	"backup-operator.io/internal/controller/utils"
@@ -34,6 +36,7 @@ import (
 
 // SetupWebhookWithManager will setup the manager to manage the webhooks
 func (r *BackupRun) SetupWebhookWithManager(mgr ctrl.Manager) error {
+	webhookReader = mgr.GetAPIReader()
 	return ctrl.NewWebhookManagedBy(mgr).
 		For(r).
 		WithDefaulter(r).
@@ -92,12 +95,12 @@ func (r *BackupRun) TemplateStoragePath() (err error) {
 
 // ValidateCreate implements webhook.Validator so a webhook will be registered for the type
 func (r *BackupRun) ValidateCreate(ctx context.Context, obj runtime.Object) (admission.Warnings, error) {
-	return r.validate(ctx, obj)
+	return r.validate(ctx, nil, obj)
 }
 
 // ValidateUpdate implements webhook.Validator so a webhook will be registered for the type
-func (r *BackupRun) ValidateUpdate(ctx context.Context, _, obj runtime.Object) (admission.Warnings, error) {
-	return r.validate(ctx, obj)
+func (r *BackupRun) ValidateUpdate(ctx context.Context, oldObj, obj runtime.Object) (admission.Warnings, error) {
+	return r.validate(ctx, oldObj, obj)
 }
 
 // ValidateDelete implements webhook.Validator so a webhook will be registered for the type
@@ -109,7 +112,9 @@ func (r *BackupRun) ValidateDelete(_ context.Context, _ runtime.Object) (admissi
 // It calls validateSpec to validate the spec and aggregates any validation errors into
 // a field.ErrorList. If there are no validation errors, it returns nil. Otherwise, it returns
 // an apierrors.Invalid error containing the aggregated field.ErrorList.
-func (r *BackupRun) validate(ctx context.Context, obj runtime.Object) (admission.Warnings, error) {
+// Access to the storage is checked on creation and on change of the storage only,
+// so existing runs can still be updated and deleted after the storage allow-list is changed.
+func (r *BackupRun) validate(ctx context.Context, oldObj, obj runtime.Object) (admission.Warnings, error) {
 	log := log.FromContext(ctx)
 	run, ok := obj.(*BackupRun)
 	if !ok {
@@ -119,6 +124,11 @@ func (r *BackupRun) validate(ctx context.Context, obj runtime.Object) (admission
 	var allErrs field.ErrorList
 	if err := run.validateSpec(); err != nil {
 		allErrs = append(allErrs, err)
+	} else if old, ok := oldObj.(*BackupRun); !ok || old.Spec.Storage.Name != run.Spec.Storage.Name ||
+		old.Spec.Storage.Path != run.Spec.Storage.Path {
+		if err := validateStorageAccess(ctx, run.Namespace, run.Spec.Storage, field.NewPath("spec").Child("storage")); err != nil {
+			allErrs = append(allErrs, err)
+		}
 	}
 	if len(allErrs) == 0 {
 		return nil, nil
@@ -143,10 +153,20 @@ func (r *BackupRun) validateSpec() (err *field.Error) {
 		fld := field.NewPath("spec").Child("encryption").Child("decryptionKey")
 		msg := "both restore and encryption blocks are present, but not decryption key provided for decryption"
 		err = field.Invalid(fld, r.Spec.Encryption, msg)
+	} else if r.Spec.Encryption != nil && r.Spec.Encryption.PassphraseKey != "" && r.Spec.Encryption.DecryptionKey == nil {
+		fld := field.NewPath("spec").Child("encryption").Child("passphraseKey")
+		msg := "passphrase key is taken from the decryption key secret, but no decryption key provided"
+		err = field.Invalid(fld, r.Spec.Encryption.PassphraseKey, msg)
 	} else if r.Spec.Backup == nil && r.Spec.Restore != nil && (*r.Spec.RetainPolicy) != BackupRetainRetain {
 		fld := field.NewPath("spec").Child("RetainPolicy")
 		msg := "only Retain policy is allowed for .spec.retainPolicy in restore-only mode"
 		err = field.Invalid(fld, r.Spec.RetainPolicy, msg)
+	} else if r.Spec.Storage.ObjectLock != nil && r.Spec.Storage.ObjectLock.Retention.Duration <= 0 {
+		fld := field.NewPath("spec").Child("storage").Child("objectLock").Child("retention")
+		msg := "object lock retention must be positive"
+		err = field.Invalid(fld, r.Spec.Storage.ObjectLock.Retention, msg)
+	} else if e := r.Spec.Compression.validate(field.NewPath("spec").Child("compression")); e != nil {
+		err = e
 	} else if e := r.TemplateStoragePath(); e != nil {
 		fld := field.NewPath("spec").Child("storage").Child("path")
 		msg := e.Error()
@@ -154,3 +174,45 @@ func (r *BackupRun) validateSpec() (err *field.Error) {
 	}
 	return
 }
+
+// Allowed compression levels by algorithm
+var compressionLevels = map[compressionAlgorithm]struct{ min, max int8 }{
+	GZIP: {-2, 9},
+	ZSTD: {math.MinInt8, 22},
+	LZ4:  {0, 9},
+	XZ:   {0, 9},
+}
+
+// validate Check options are supported by the algorithm
+func (c *backupCompression) validate(fld *field.Path) *field.Error {
+	if c == nil {
+		return nil
+	}
+	levels, ok := compressionLevels[c.Algorithm]
+	switch {
+	case !ok:
+		supported := make([]string, 0, len(compressionLevels))
+		for algorithm := range compressionLevels {
+			supported = append(supported, string(algorithm))
+		}
+		slices.Sort(supported)
+		return field.NotSupported(fld.Child("algorithm"), c.Algorithm, supported)
+	case c.Level != nil && (*c.Level < levels.min || *c.Level > levels.max):
+		return field.Invalid(fld.Child("level"), *c.Level,
+			fmt.Sprintf("%s level must be between %d and %d", c.Algorithm, levels.min, levels.max))
+	case c.LongDistanceMatching && c.Algorithm != ZSTD:
+		return field.Invalid(fld.Child("longDistanceMatching"), c.LongDistanceMatching,
+			fmt.Sprintf("long distance matching is not supported by %s", c.Algorithm))
+	case c.Concurrency > 64:
+		return field.Invalid(fld.Child("concurrency"), c.Concurrency, "must be between 1 and 64")
+	case c.Concurrency > 1 && c.Algorithm != ZSTD && c.Algorithm != GZIP:
+		return field.Invalid(fld.Child("concurrency"), c.Concurrency,
+			fmt.Sprintf("concurrency is not supported by %s", c.Algorithm))
+	case c.BlockSize != nil && c.Algorithm != GZIP:
+		return field.Invalid(fld.Child("blockSize"), c.BlockSize.String(),
+			fmt.Sprintf("block size is not supported by %s", c.Algorithm))
+	case c.BlockSize != nil && (c.BlockSize.Value() < 64<<10 || c.BlockSize.Value() > 64<<20):
+		return field.Invalid(fld.Child("blockSize"), c.BlockSize.String(), "must be between 64Ki and 64Mi")
+	}
+	return nil
+}
@@ -18,6 +18,10 @@ package v1
 
 import (
 	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"k8s.io/utils/ptr"
 )
 
 var _ = Describe("BackupRun Webhook", func() {
@@ -36,4 +40,47 @@ var _ = Describe("BackupRun Webhook", func() {
 			// TODO(user): Add your logic here
 		})
 	})
+
+	Describe("Validating compression options", func() {
+		quantity := func(value string) *resource.Quantity {
+			q := resource.MustParse(value)
+			return &q
+		}
+
+		It("Should report the invalid option by the algorithm", func() {
+			for _, c := range []struct {
+				compression backupCompression
+				field       string
+			}{
+				{backupCompression{Algorithm: GZIP, Level: ptr.To[int8](6)}, ""},
+				{backupCompression{Algorithm: XZ}, ""},
+				{backupCompression{Algorithm: XZ, Level: ptr.To[int8](0)}, ""},
+				{backupCompression{Algorithm: GZIP, Concurrency: 64, BlockSize: quantity("64Ki")}, ""},
+				{backupCompression{Algorithm: GZIP, Concurrency: 8, BlockSize: quantity("64Mi")}, ""},
+				{backupCompression{Algorithm: ZSTD, Concurrency: 4}, ""},
+				{backupCompression{Algorithm: ZSTD, Level: ptr.To[int8](-5)}, ""},
+				{backupCompression{Algorithm: ZSTD, Level: ptr.To[int8](23)}, "level"},
+				{backupCompression{Algorithm: GZIP, BlockSize: quantity("65536")}, ""},
+				{backupCompression{Algorithm: GZIP, BlockSize: quantity("65535")}, "blockSize"},
+				{backupCompression{Algorithm: GZIP, BlockSize: quantity("0")}, "blockSize"},
+				{backupCompression{Algorithm: GZIP, BlockSize: quantity("-1Mi")}, "blockSize"},
+				{backupCompression{Algorithm: GZIP, BlockSize: quantity("65Mi")}, "blockSize"},
+				{backupCompression{Algorithm: ZSTD, BlockSize: quantity("1Mi")}, "blockSize"},
+				{backupCompression{Algorithm: GZIP, Concurrency: 65}, "concurrency"},
+				{backupCompression{Algorithm: ZSTD, Concurrency: 255}, "concurrency"},
+				{backupCompression{Algorithm: LZ4, Concurrency: 2}, "concurrency"},
+				{backupCompression{Algorithm: XZ, Concurrency: 2}, "concurrency"},
+				{backupCompression{Algorithm: GZIP, Level: ptr.To[int8](10)}, "level"},
+				{backupCompression{Algorithm: XZ, Level: ptr.To[int8](-1)}, "level"},
+			} {
+				err := c.compression.validate(field.NewPath("compression"))
+				if c.field == "" {
+					Expect(err).To(BeNil(), "%+v", c.compression)
+				} else {
+					Expect(err).NotTo(BeNil(), "%+v", c.compression)
+					Expect(err.Field).To(Equal("compression."+c.field), "%+v", c.compression)
+				}
+			}
+		})
+	})
 })
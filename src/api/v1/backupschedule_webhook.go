@@ -35,6 +35,7 @@ import (
 )
 
 func (r *BackupSchedule) SetupWebhookWithManager(mgr ctrl.Manager) error {
+	webhookReader = mgr.GetAPIReader()
 	return ctrl.NewWebhookManagedBy(mgr).
 		For(r).
 		WithValidator(r).
@@ -47,12 +48,12 @@ var _ webhook.CustomValidator = &BackupSchedule{}
 
 // ValidateCreate implements webhook.Validator so a webhook will be registered for the type
 func (r *BackupSchedule) ValidateCreate(ctx context.Context, obj runtime.Object) (admission.Warnings, error) {
-	return r.validate(ctx, obj)
+	return r.validate(ctx, nil, obj)
 }
 
 // ValidateUpdate implements webhook.Validator so a webhook will be registered for the type
-func (r *BackupSchedule) ValidateUpdate(ctx context.Context, _, obj runtime.Object) (admission.Warnings, error) {
-	return r.validate(ctx, obj)
+func (r *BackupSchedule) ValidateUpdate(ctx context.Context, oldObj, obj runtime.Object) (admission.Warnings, error) {
+	return r.validate(ctx, oldObj, obj)
 }
 
 // ValidateDelete implements webhook.Validator so a webhook will be registered for the type
@@ -65,8 +66,9 @@ func (r *BackupSchedule) ValidateDelete(_ context.Context, _ runtime.Object) (ad
 // validateRunSpec to validate the run spec, and validateCron to
 // validate the schedule format. Any validation errors are aggregated into a field.ErrorList.
 // If there are no validation errors, it returns nil. Otherwise, it returns an apierrors.Invalid
-// error containing the aggregated field.ErrorList.
-func (r *BackupSchedule) validate(ctx context.Context, obj runtime.Object) (admission.Warnings, error) {
+// error containing the aggregated field.ErrorList. Access to the storage is checked on creation
+// and on change of the storage only, so existing schedules can still be updated and deleted.
+func (r *BackupSchedule) validate(ctx context.Context, oldObj, obj runtime.Object) (admission.Warnings, error) {
 	log := log.FromContext(ctx)
 	schedule, ok := obj.(*BackupSchedule)
 	if !ok {
@@ -80,6 +82,12 @@ func (r *BackupSchedule) validate(ctx context.Context, obj runtime.Object) (admi
 	}
 	if err := schedule.validateRunSpec(); err != nil {
 		allErrs = append(allErrs, err)
+	} else if old, ok := oldObj.(*BackupSchedule); !ok ||
+		old.Spec.Template.Spec.Storage.Name != schedule.Spec.Template.Spec.Storage.Name ||
+		old.Spec.Template.Spec.Storage.Path != schedule.Spec.Template.Spec.Storage.Path {
+		if err := schedule.validateStorageAccess(ctx); err != nil {
+			allErrs = append(allErrs, err)
+		}
 	}
 	if err := schedule.validateCron(); err != nil {
 		allErrs = append(allErrs, err)
@@ -110,6 +118,18 @@ func (r *BackupSchedule) validateRunSpec() (err *field.Error) {
 	return
 }
 
+// validateStorageAccess checks the schedule namespace is allowed to use the storage.
+// Path template is rendered the same way as for the runs created by the schedule.
+func (r *BackupSchedule) validateStorageAccess(ctx context.Context) (err *field.Error) {
+	run := &BackupRun{Spec: *r.Spec.Template.Spec.DeepCopy()}
+	if e := run.TemplateStoragePath(); e != nil {
+		// Reported by validateRunSpec
+		return
+	}
+	return validateStorageAccess(ctx, r.Namespace, run.Spec.Storage,
+		field.NewPath("spec").Child("template").Child("spec").Child("storage"))
+}
+
 // validateName checks the length of the BackupSchedule name to ensure it meets the
 // requirements for creating corresponding jobs. Kubernetes object names must fit in a DNS subdomain
 // and have a maximum length of 63 characters. The controller appends an 11-character suffix
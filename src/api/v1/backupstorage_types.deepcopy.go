@@ -0,0 +1,98 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+func (in *storageInventory) DeepCopy() *storageInventory {
+	if in == nil {
+		return nil
+	}
+	out := new(storageInventory)
+	in.DeepCopyInto(out)
+	return out
+}
+
+func (in *storageInventory) DeepCopyInto(out *storageInventory) {
+	*out = *in
+}
+
+func (in *storageQuota) DeepCopy() *storageQuota {
+	if in == nil {
+		return nil
+	}
+	out := new(storageQuota)
+	in.DeepCopyInto(out)
+	return out
+}
+
+func (in *storageQuota) DeepCopyInto(out *storageQuota) {
+	*out = *in
+	if in.MaxSize != nil {
+		x := in.MaxSize.DeepCopy()
+		out.MaxSize = &x
+	}
+	if in.MaxRuns != nil {
+		out.MaxRuns = new(uint16)
+		*out.MaxRuns = *in.MaxRuns
+	}
+}
+
+func (in *storageHealthCheck) DeepCopy() *storageHealthCheck {
+	if in == nil {
+		return nil
+	}
+	out := new(storageHealthCheck)
+	in.DeepCopyInto(out)
+	return out
+}
+
+func (in *storageHealthCheck) DeepCopyInto(out *storageHealthCheck) {
+	*out = *in
+}
+
+func (in *storageNamespaceRule) DeepCopy() *storageNamespaceRule {
+	if in == nil {
+		return nil
+	}
+	out := new(storageNamespaceRule)
+	in.DeepCopyInto(out)
+	return out
+}
+
+func (in *storageNamespaceRule) DeepCopyInto(out *storageNamespaceRule) {
+	*out = *in
+	if in.Names != nil {
+		out.Names = make([]string, len(in.Names))
+		copy(out.Names, in.Names)
+	}
+	if in.Selector != nil {
+		out.Selector = in.Selector.DeepCopy()
+	}
+}
+
+func (in *storageDeduplication) DeepCopy() *storageDeduplication {
+	if in == nil {
+		return nil
+	}
+	out := new(storageDeduplication)
+	in.DeepCopyInto(out)
+	return out
+}
+
+func (in *storageDeduplication) DeepCopyInto(out *storageDeduplication) {
+	*out = *in
+	out.AverageChunkSize = in.AverageChunkSize.DeepCopy()
+}
@@ -17,18 +17,29 @@ limitations under the License.
 package v1
 
 import (
+	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
 // EDIT THIS FILE!  THIS IS SCAFFOLDING FOR YOU TO OWN!
 // NOTE: json tags are required.  Any new fields you add must have json tags for the fields to be serialized.
 
-// +kubebuilder:validation:Enum=s3
+// Storage type is validated by the webhook against registered storage providers
+// +kubebuilder:validation:MinLength=1
 type storageType string
 
+// Built-in storage types
 const (
 	// S3 storage type name
 	S3 storageType = "s3"
+	// Filesystem storage type name
+	Filesystem storageType = "filesystem"
+	// SFTP storage type name
+	SFTP storageType = "sftp"
+	// AzureBlob storage type name
+	AzureBlob storageType = "azureblob"
+	// GCS storage type name
+	GCS storageType = "gcs"
 )
 
 /* BackupStorageSpec defines the desired state of BackupStorage. */
@@ -36,8 +47,10 @@ type BackupStorageSpec struct {
 	// INSERT ADDITIONAL SPEC FIELDS - desired state of cluster
 	// Important: Run "make" to regenerate code after modifying this file
 
-	/* Type of the storage. At the moment, only S3 is supported.
-	Valid values: s3
+	/* Type of the storage. It must match one of storage providers registered in the operator.
+	Built-in ones are S3, Azure Blob Storage, Google Cloud Storage, SFTP and filesystem
+	(a directory mounted into the operator).
+	Built-in values: s3, filesystem, sftp, azureblob, gcs
 	Default: s3 */
 	//+kubebuilder:default="s3"
 	//+kubebuilder:example="s3"
@@ -50,6 +63,145 @@ type BackupStorageSpec struct {
 	/* Credentials to use for connection. You can select exact keys adding overrides in parameters. */
 	//+kubebuilder:validation:Optional
 	Credentials *secretReferenceRequireNamespace `json:"credentials,omitempty" protobuf:"bytes,3,opt,name=credentials"`
+
+	/* Periodic comparison of files in the storage with BackupRuns pointing at it.
+	Results are stored in .status.inventory. Disabled if omitted. */
+	//+kubebuilder:validation:Optional
+	Inventory *storageInventory `json:"inventory,omitempty" protobuf:"bytes,4,opt,name=inventory"`
+
+	/* Limits of the storage usage. New backups are held with QuotaExceeded condition
+	until the usage is below the limits again. */
+	//+kubebuilder:validation:Optional
+	Quota *storageQuota `json:"quota,omitempty" protobuf:"bytes,5,opt,name=quota"`
+
+	/* Periodic put, get and delete of a small probe file. The storage becomes not ready
+	if the probe fails. Disabled if omitted, set it to {} to enable it with default settings. */
+	//+kubebuilder:validation:Optional
+	HealthCheck *storageHealthCheck `json:"healthCheck,omitempty" protobuf:"bytes,6,opt,name=healthCheck"`
+
+	/* Namespaces allowed to create BackupRuns and BackupSchedules pointing at the storage.
+	Namespace is allowed if it matches any rule. All namespaces are allowed if omitted. */
+	//+kubebuilder:validation:Optional
+	AllowedNamespaces []storageNamespaceRule `json:"allowedNamespaces,omitempty" protobuf:"bytes,7,rep,name=allowedNamespaces"`
+
+	/* Store backups as content-defined chunks kept once by hash, and backup files as manifests
+	of chunk references. Compression and encryption make every backup unique, so disable them
+	in runs using the storage. Disabled if omitted. */
+	//+kubebuilder:validation:Optional
+	Deduplication *storageDeduplication `json:"deduplication,omitempty" protobuf:"bytes,8,opt,name=deduplication"`
+}
+
+/* Storage deduplication options. */
+type storageDeduplication struct {
+	/* Target average size of chunks, it is rounded down to a power of two. Chunks are from
+	a quarter to four times of it. Smaller chunks deduplicate better, but make more requests.
+	Default: 4Mi */
+	//+kubebuilder:default="4Mi"
+	//+kubebuilder:example="1Mi"
+	AverageChunkSize resource.Quantity `json:"averageChunkSize" protobuf:"bytes,1,req,name=averageChunkSize"`
+
+	/* Count of chunks uploaded in parallel, every one is kept in memory.
+	Default: 4 */
+	//+kubebuilder:default=4
+	//+kubebuilder:validation:Minimum=1
+	//+kubebuilder:validation:Maximum=64
+	Concurrency uint8 `json:"concurrency" protobuf:"varint,2,req,name=concurrency"`
+}
+
+/* Namespaces allowed to use the storage. Namespace matches the rule if it is listed or matches the selector. */
+type storageNamespaceRule struct {
+	/* Namespace names. */
+	//+kubebuilder:validation:Optional
+	//+kubebuilder:example={"team-a","team-b"}
+	Names []string `json:"names,omitempty" protobuf:"bytes,1,rep,name=names"`
+
+	/* Namespace label selector. Empty selector matches all namespaces. */
+	//+kubebuilder:validation:Optional
+	Selector *metav1.LabelSelector `json:"selector,omitempty" protobuf:"bytes,2,opt,name=selector"`
+
+	/* Backup paths in matching namespaces must start with this prefix, so tenants can not
+	overwrite or restore files of each other. It is a Go template with sprig functions
+	rendered with .Namespace. Any path is allowed if omitted. */
+	//+kubebuilder:validation:Optional
+	//+kubebuilder:validation:Pattern=`^/`
+	//+kubebuilder:example="/tenants/{{ .Namespace }}/"
+	PathPrefix string `json:"pathPrefix,omitempty" protobuf:"bytes,3,opt,name=pathPrefix"`
+}
+
+/* Storage health check options. */
+type storageHealthCheck struct {
+	/* Set to false to disable the health check without dropping its settings. Keep it disabled
+	if the bucket has default Object Lock retention, since probe files can not be deleted.
+	Default: true */
+	//+kubebuilder:default=true
+	Enabled bool `json:"enabled" protobuf:"varint,1,req,name=enabled"`
+
+	/* How often to probe the storage. Failed probe is repeated within a minute.
+	Default: 5m */
+	//+kubebuilder:default="5m"
+	//+kubebuilder:example="1m"
+	Interval metav1.Duration `json:"interval" protobuf:"bytes,2,req,name=interval"`
+
+	/* Timeout of the whole probe.
+	Default: 30s */
+	//+kubebuilder:default="30s"
+	//+kubebuilder:example="1m"
+	Timeout metav1.Duration `json:"timeout" protobuf:"bytes,3,req,name=timeout"`
+}
+
+/* Storage quota options. */
+type storageQuota struct {
+	/* Maximum total size of backups made by BackupRuns pointing at the storage. */
+	//+kubebuilder:validation:Optional
+	//+kubebuilder:example="500Gi"
+	MaxSize *resource.Quantity `json:"maxSize,omitempty" protobuf:"bytes,1,opt,name=maxSize"`
+
+	/* Maximum count of BackupRuns pointing at the storage. Runs waiting for the quota are not
+	counted, they start oldest first while there is room for them. */
+	//+kubebuilder:validation:Minimum=1
+	//+kubebuilder:validation:Optional
+	//+kubebuilder:example=100
+	MaxRuns *uint16 `json:"maxRuns,omitempty" protobuf:"varint,2,opt,name=maxRuns"`
+}
+
+// +kubebuilder:validation:Enum=Report;Delete
+type orphanPolicy string
+
+const (
+	// OrphanPolicyReport Orphaned files are only reported in status
+	OrphanPolicyReport orphanPolicy = "Report"
+	// OrphanPolicyDelete Orphaned files are deleted after the grace period
+	OrphanPolicyDelete orphanPolicy = "Delete"
+)
+
+/* Storage inventory options. */
+type storageInventory struct {
+	/* How often to list the storage.
+	Default: 1h */
+	//+kubebuilder:default="1h"
+	//+kubebuilder:example="6h"
+	Interval metav1.Duration `json:"interval" protobuf:"bytes,1,req,name=interval"`
+
+	/* Only files with this path prefix are inventoried. Set it if the storage
+	is shared with other BackupStorages or anything else.
+	Default: / */
+	//+kubebuilder:default="/"
+	//+kubebuilder:validation:Pattern=`^/`
+	//+kubebuilder:example="/mysql/"
+	Prefix string `json:"prefix" protobuf:"bytes,2,req,name=prefix"`
+
+	/* What to do with files that no BackupRun points at. Keep in mind files of runs
+	deleted with RetainPolicy Retain become orphaned too.
+	Valid values: Report, Delete
+	Default: Report */
+	//+kubebuilder:default="Report"
+	OrphanPolicy orphanPolicy `json:"orphanPolicy" protobuf:"bytes,3,req,name=orphanPolicy"`
+
+	/* Orphaned file is deleted only if it has been orphaned for this period.
+	Default: 24h */
+	//+kubebuilder:default="24h"
+	//+kubebuilder:example="168h"
+	GracePeriod metav1.Duration `json:"gracePeriod" protobuf:"bytes,4,req,name=gracePeriod"`
 }
 
 /* BackupStorageStatus defines the observed state of BackupStorage. */
@@ -87,6 +239,52 @@ type BackupStorageStatus struct {
 	//+kubebuilder:validation:Minimum=0
 	//+kubebuilder:validation:Optional
 	SizeInBytes *uint `json:"sizeInBytes,omitempty" protobuf:"varint,5,opt,name=sizeInBytes"`
+
+	/* Result of the last storage inventory. */
+	//+kubebuilder:validation:Optional
+	Inventory *StorageInventoryStatus `json:"inventory,omitempty" protobuf:"bytes,6,opt,name=inventory"`
+}
+
+/* StorageInventoryStatus is a storage inventory result. Lists are truncated, counters are not. */
+type StorageInventoryStatus struct {
+	/* Time of the last inventory. */
+	//+kubebuilder:validation:Optional
+	LastInventoryTime *metav1.Time `json:"lastInventoryTime,omitempty" protobuf:"bytes,1,opt,name=lastInventoryTime"`
+
+	/* Total count of files found with the prefix. */
+	//+kubebuilder:default=0
+	//+kubebuilder:validation:Minimum=0
+	//+kubebuilder:validation:Optional
+	Objects uint `json:"objects,omitempty" protobuf:"varint,2,opt,name=objects"`
+
+	/* Total count of files that no BackupRun points at. */
+	//+kubebuilder:default=0
+	//+kubebuilder:validation:Minimum=0
+	//+kubebuilder:validation:Optional
+	OrphanedObjectsCount uint `json:"orphanedObjectsCount,omitempty" protobuf:"varint,3,opt,name=orphanedObjectsCount"`
+
+	/* Files that no BackupRun points at. */
+	//+kubebuilder:validation:Optional
+	OrphanedObjects []OrphanedObject `json:"orphanedObjects,omitempty" protobuf:"bytes,4,rep,name=orphanedObjects"`
+
+	/* Total count of completed BackupRuns whose files are absent. */
+	//+kubebuilder:default=0
+	//+kubebuilder:validation:Minimum=0
+	//+kubebuilder:validation:Optional
+	MissingObjectsCount uint `json:"missingObjectsCount,omitempty" protobuf:"varint,5,opt,name=missingObjectsCount"`
+
+	/* Completed BackupRuns whose files are absent in namespace/name format. */
+	//+kubebuilder:validation:Optional
+	MissingObjects []string `json:"missingObjects,omitempty" protobuf:"bytes,6,rep,name=missingObjects"`
+}
+
+/* OrphanedObject is a file that no BackupRun points at. */
+type OrphanedObject struct {
+	/* Path of the file. */
+	Path string `json:"path" protobuf:"bytes,1,req,name=path"`
+
+	/* When the file has been found orphaned for the first time. */
+	Since metav1.Time `json:"since" protobuf:"bytes,2,req,name=since"`
 }
 
 /*
@@ -102,6 +300,8 @@ and upload backups to the place defined in these BackupStorages for long time st
 //+kubebuilder:printcolumn:name="Schedules",type=integer,JSONPath=`.status.schedules`,description="Count of child schedules"
 //+kubebuilder:printcolumn:name="Runs",type=integer,JSONPath=`.status.runs`,description="Count of child runs"
 //+kubebuilder:printcolumn:name="Size",type=string,JSONPath=`.status.size`,description="Total occupied storage size",priority=1
+//+kubebuilder:printcolumn:name="Orphaned",type=integer,JSONPath=`.status.inventory.orphanedObjectsCount`,description="Count of files no run points at",priority=1
+//+kubebuilder:printcolumn:name="Missing",type=integer,JSONPath=`.status.inventory.missingObjectsCount`,description="Count of runs with absent files",priority=1
 type BackupStorage struct {
 	metav1.TypeMeta   `json:",inline"`
 	metav1.ObjectMeta `json:"metadata,omitempty" protobuf:"bytes,3,req,name=metadata"`
@@ -18,10 +18,20 @@ package v1
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"path"
+	"slices"
+	"strings"
+	"time"
 
+	"backup-operator.io/internal/controller/utils"
+	corev1 "k8s.io/api/core/v1"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/runtime/schema"
@@ -30,6 +40,20 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
 )
 
+// ErrUnknownStorageType is returned by StorageProviderValidator if there is no provider for the storage type
+var ErrUnknownStorageType = errors.New("unknown storage type")
+
+// StorageProviderValidator validates storage type and parameters against registered storage providers.
+// It is set by the manager on startup, since providers can not be imported here without an import cycle.
+// Unknown storage type or missing mandatory parameter is an error, other findings are returned as warnings.
+var StorageProviderValidator func(storageType string, parameters map[string]string) (warnings []string, err error)
+
+// webhookReader reads BackupStorages and Namespaces to check .spec.allowedNamespaces.
+// It is set up with BackupRun and BackupSchedule webhooks, the check is skipped if it is not set.
+var webhookReader client.Reader
+
+//+kubebuilder:rbac:groups="",resources=namespaces,verbs=get
+
 func (r *BackupStorage) SetupWebhookWithManager(mgr ctrl.Manager) error {
 	return ctrl.NewWebhookManagedBy(mgr).
 		For(r).
@@ -61,13 +85,13 @@ func (r *BackupStorage) Default(ctx context.Context, obj runtime.Object) (err er
 //+kubebuilder:webhook:path=/validate-backup-operator-io-v1-backupstorage,mutating=false,failurePolicy=fail,sideEffects=None,groups=backup-operator.io,resources=backupstorages,verbs=create;update;delete,versions=v1,name=vbackupstorage.kb.io,admissionReviewVersions=v1
 
 // ValidateCreate implements webhook.Validator so a webhook will be registered for the type
-func (r *BackupStorage) ValidateCreate(_ context.Context, _ runtime.Object) (admission.Warnings, error) {
-	return nil, nil
+func (r *BackupStorage) ValidateCreate(ctx context.Context, obj runtime.Object) (admission.Warnings, error) {
+	return r.validateBackupStorage(ctx, obj)
 }
 
 // ValidateUpdate implements webhook.Validator so a webhook will be registered for the type
-func (r *BackupStorage) ValidateUpdate(_ context.Context, _, _ runtime.Object) (admission.Warnings, error) {
-	return nil, nil
+func (r *BackupStorage) ValidateUpdate(ctx context.Context, _, newObj runtime.Object) (admission.Warnings, error) {
+	return r.validateBackupStorage(ctx, newObj)
 }
 
 // ValidateDelete implements webhook.Validator so a webhook will be registered for the type
@@ -75,6 +99,204 @@ func (r *BackupStorage) ValidateDelete(ctx context.Context, obj runtime.Object)
 	return r.validateBackupStorageDeletion(ctx, obj)
 }
 
+// validateBackupStorage checks the BackupStorage object spec against the registered storage provider.
+// Unknown type and missing mandatory parameters are errors, unknown parameters are warnings.
+func (r *BackupStorage) validateBackupStorage(ctx context.Context, obj runtime.Object) (admission.Warnings, error) {
+	log := log.FromContext(ctx)
+	storage, ok := obj.(*BackupStorage)
+	if !ok {
+		return nil, fmt.Errorf("expected a BackupStorage but got a %T", obj)
+	}
+	log.V(1).Info("Validating BackupStorage")
+
+	var allErrs field.ErrorList
+	warnings, err := storage.validateProvider()
+	if err != nil {
+		allErrs = append(allErrs, err)
+	}
+	allErrs = append(allErrs, storage.validateInventory()...)
+	allErrs = append(allErrs, storage.validateHealthCheck()...)
+	allErrs = append(allErrs, storage.validateAllowedNamespaces()...)
+	if dedup := storage.Spec.Deduplication; dedup != nil &&
+		(dedup.AverageChunkSize.Value() < 64<<10 || dedup.AverageChunkSize.Value() > 64<<20) {
+		allErrs = append(allErrs, field.Invalid(field.NewPath("spec").Child("deduplication").Child("averageChunkSize"),
+			dedup.AverageChunkSize.String(), "must be between 64Ki and 64Mi"))
+	}
+	if storage.Spec.Quota != nil && storage.Spec.Quota.MaxSize != nil && storage.Spec.Quota.MaxSize.Sign() <= 0 {
+		allErrs = append(allErrs, field.Invalid(field.NewPath("spec").Child("quota").Child("maxSize"),
+			storage.Spec.Quota.MaxSize.String(), "must be positive"))
+	}
+	if len(allErrs) == 0 {
+		return warnings, nil
+	}
+	return warnings, apierrors.NewInvalid(
+		schema.GroupKind{
+			Group: storage.GroupVersionKind().Group,
+			Kind:  storage.Kind,
+		}, storage.Name, allErrs)
+}
+
+// validateProvider validates .spec.type and .spec.parameters with StorageProviderValidator.
+// Validation is skipped if no validator is set.
+func (r *BackupStorage) validateProvider() (warnings admission.Warnings, err *field.Error) {
+	if StorageProviderValidator == nil {
+		return
+	}
+	var e error
+	if warnings, e = StorageProviderValidator(string(r.Spec.Type), r.Spec.Parameters); e != nil {
+		if errors.Is(e, ErrUnknownStorageType) {
+			err = field.Invalid(field.NewPath("spec").Child("type"), r.Spec.Type, e.Error())
+		} else {
+			err = field.Invalid(field.NewPath("spec").Child("parameters"), r.Spec.Parameters, e.Error())
+		}
+	}
+	return
+}
+
+// validateInventory validates .spec.inventory periods.
+func (r *BackupStorage) validateInventory() (errs field.ErrorList) {
+	if r.Spec.Inventory == nil {
+		return
+	}
+	fld := field.NewPath("spec").Child("inventory")
+	if r.Spec.Inventory.Interval.Duration < time.Minute {
+		errs = append(errs, field.Invalid(fld.Child("interval"), r.Spec.Inventory.Interval.Duration.String(),
+			"must be at least 1m"))
+	}
+	if r.Spec.Inventory.GracePeriod.Duration < 0 {
+		errs = append(errs, field.Invalid(fld.Child("gracePeriod"), r.Spec.Inventory.GracePeriod.Duration.String(),
+			"must not be negative"))
+	}
+	return
+}
+
+// validateHealthCheck validates .spec.healthCheck periods.
+func (r *BackupStorage) validateHealthCheck() (errs field.ErrorList) {
+	if r.Spec.HealthCheck == nil || !r.Spec.HealthCheck.Enabled {
+		return
+	}
+	fld := field.NewPath("spec").Child("healthCheck")
+	if r.Spec.HealthCheck.Interval.Duration < 10*time.Second {
+		errs = append(errs, field.Invalid(fld.Child("interval"), r.Spec.HealthCheck.Interval.Duration.String(),
+			"must be at least 10s"))
+	}
+	if r.Spec.HealthCheck.Timeout.Duration <= 0 {
+		errs = append(errs, field.Invalid(fld.Child("timeout"), r.Spec.HealthCheck.Timeout.Duration.String(),
+			"must be positive"))
+	}
+	return
+}
+
+// validateAllowedNamespaces validates .spec.allowedNamespaces selectors and path prefix templates.
+func (r *BackupStorage) validateAllowedNamespaces() (errs field.ErrorList) {
+	for i, rule := range r.Spec.AllowedNamespaces {
+		fld := field.NewPath("spec").Child("allowedNamespaces").Index(i)
+		if len(rule.Names) == 0 && rule.Selector == nil {
+			errs = append(errs, field.Required(fld, "either names or selector must be set"))
+		}
+		if rule.Selector != nil {
+			if _, err := metav1.LabelSelectorAsSelector(rule.Selector); err != nil {
+				errs = append(errs, field.Invalid(fld.Child("selector"), rule.Selector, err.Error()))
+			}
+		}
+		if _, err := rule.renderPathPrefix("namespace"); err != nil {
+			errs = append(errs, field.Invalid(fld.Child("pathPrefix"), rule.PathPrefix, err.Error()))
+		}
+	}
+	return
+}
+
+// matches checks whether the namespace matches the rule
+func (r *storageNamespaceRule) matches(namespace *corev1.Namespace) (bool, error) {
+	if slices.Contains(r.Names, namespace.Name) {
+		return true, nil
+	}
+	if r.Selector == nil {
+		return false, nil
+	}
+	selector, err := metav1.LabelSelectorAsSelector(r.Selector)
+	if err != nil {
+		return false, err
+	}
+	return selector.Matches(labels.Set(namespace.Labels)), nil
+}
+
+// renderPathPrefix renders the path prefix template for the namespace, empty prefix allows any path
+func (r *storageNamespaceRule) renderPathPrefix(namespace string) (prefix string, err error) {
+	if len(r.PathPrefix) == 0 {
+		return
+	}
+	if prefix, err = utils.TextTemplateSprig(r.PathPrefix, struct{ Namespace string }{namespace}); err != nil {
+		return
+	}
+	if prefix = strings.TrimSpace(prefix); !strings.HasPrefix(prefix, "/") || strings.Trim(prefix, "/") == "" {
+		return "", fmt.Errorf("rendered path prefix must start with / and must not be the root: %s", prefix)
+	}
+	return
+}
+
+// validateStorageAccess checks the namespace is allowed to use the storage by its .spec.allowedNamespaces
+// and the backup path starts with the forced prefix if any. Path must be rendered already. The path is
+// checked with pathHasPrefix unless another check is passed. Missing storage is not an error, runs wait for it anyway.
+func validateStorageAccess(ctx context.Context, namespace string, storage *backupStorage, fld *field.Path,
+	hasPrefix ...func(path, prefix string) bool,
+) *field.Error {
+	inside := pathHasPrefix
+	if len(hasPrefix) > 0 {
+		inside = hasPrefix[0]
+	}
+	if webhookReader == nil {
+		return nil
+	}
+	target := &BackupStorage{}
+	if err := webhookReader.Get(ctx, client.ObjectKey{Name: storage.Name}, target); apierrors.IsNotFound(err) {
+		return nil
+	} else if err != nil {
+		return field.InternalError(fld.Child("name"), fmt.Errorf("could not get the storage: %s", err))
+	}
+	if len(target.Spec.AllowedNamespaces) == 0 {
+		return nil
+	}
+	ns := &corev1.Namespace{}
+	if err := webhookReader.Get(ctx, client.ObjectKey{Name: namespace}, ns); err != nil {
+		return field.InternalError(fld.Child("name"), fmt.Errorf("could not get the namespace: %s", err))
+	}
+	var prefixes []string
+	allowed := false
+	for _, rule := range target.Spec.AllowedNamespaces {
+		matches, err := rule.matches(ns)
+		if err != nil {
+			return field.InternalError(fld.Child("name"), err)
+		} else if !matches {
+			continue
+		}
+		allowed = true
+		prefix, err := rule.renderPathPrefix(namespace)
+		if err != nil {
+			return field.InternalError(fld.Child("path"), err)
+		} else if len(prefix) == 0 || inside(storage.Path, prefix) {
+			return nil
+		}
+		prefixes = append(prefixes, prefix)
+	}
+	if !allowed {
+		return field.Forbidden(fld.Child("name"),
+			fmt.Sprintf("namespace %s is not allowed to use storage %s", namespace, storage.Name))
+	}
+	return field.Forbidden(fld.Child("path"),
+		fmt.Sprintf("path in namespace %s must start with %s", namespace, strings.Join(prefixes, " or ")))
+}
+
+// pathHasPrefix checks the backup path is inside the prefix directory.
+// Only canonical paths are accepted, since providers clean paths and e.g. .. segments or
+// duplicate slashes could make the stored path differ from the checked one.
+func pathHasPrefix(backupPath, prefix string) bool {
+	if path.Clean(backupPath) != backupPath {
+		return false
+	}
+	return strings.HasPrefix(backupPath, strings.TrimSuffix(prefix, "/")+"/")
+}
+
 // validateBackupStorageDeletion checks the BackupStorage object for deletion correctness by
 // validating its deletion protection. It calls validateDeletionProtection to check
 // if the object is protected from deletion using an annotation. If the object is protected,
@@ -18,6 +18,7 @@ package v1
 
 import (
 	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
 )
 
 var _ = Describe("BackupStorage Webhook", func() {
@@ -36,4 +37,30 @@ var _ = Describe("BackupStorage Webhook", func() {
 			// TODO(user): Add your logic here
 		})
 	})
+
+	Describe("Checking backup paths against the forced prefix", func() {
+		It("Should admit canonical paths inside the prefix directory only", func() {
+			for _, c := range []struct {
+				path, prefix string
+				expected     bool
+			}{
+				{"/tenant-a/db.gz", "/tenant-a", true},
+				{"/tenant-a/db.gz", "/tenant-a/", true},
+				{"/tenant-a/nested/db.gz", "/tenant-a", true},
+				{"/tenant-ab/db.gz", "/tenant-a", false},
+				{"/tenant-b/db.gz", "/tenant-a", false},
+				{"/tenant-a", "/tenant-a", false},
+				{"/tenant-a/../tenant-b/db.gz", "/tenant-a", false},
+				{"/tenant-a/nested/../../tenant-b/db.gz", "/tenant-a", false},
+				{"/tenant-a/..", "/tenant-a", false},
+				{"/tenant-a/./db.gz", "/tenant-a", false},
+				{"//tenant-a/db.gz", "/tenant-a", false},
+				{"/tenant-a//db.gz", "/tenant-a", false},
+				{"/tenant-a/nested/", "/tenant-a", false},
+			} {
+				Expect(pathHasPrefix(c.path, c.prefix)).To(Equal(c.expected),
+					"pathHasPrefix(%q, %q)", c.path, c.prefix)
+			}
+		})
+	})
 })
@@ -36,4 +36,6 @@ type TemplateMetadata struct {
 const (
 	// ConditionTypeReady Readiness marker
 	ConditionTypeReady string = "Ready"
+	// ConditionTypeQuotaExceeded Storage usage is over its quota
+	ConditionTypeQuotaExceeded string = "QuotaExceeded"
 )
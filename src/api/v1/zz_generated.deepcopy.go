@@ -72,6 +72,130 @@ func (in Annotations) DeepCopy() Annotations {
 	return *out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *BackupImport) DeepCopyInto(out *BackupImport) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+	in.Status.DeepCopyInto(&out.Status)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new BackupImport.
+func (in *BackupImport) DeepCopy() *BackupImport {
+	if in == nil {
+		return nil
+	}
+	out := new(BackupImport)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *BackupImport) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *BackupImportList) DeepCopyInto(out *BackupImportList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]BackupImport, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new BackupImportList.
+func (in *BackupImportList) DeepCopy() *BackupImportList {
+	if in == nil {
+		return nil
+	}
+	out := new(BackupImportList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *BackupImportList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *BackupImportSpec) DeepCopyInto(out *BackupImportSpec) {
+	*out = *in
+	if in.Pattern != nil {
+		in, out := &in.Pattern, &out.Pattern
+		*out = new(string)
+		**out = **in
+	}
+	if in.Interval != nil {
+		in, out := &in.Interval, &out.Interval
+		*out = new(metav1.Duration)
+		**out = **in
+	}
+	if in.Template != nil {
+		in, out := &in.Template, &out.Template
+		*out = (*in).DeepCopy()
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new BackupImportSpec.
+func (in *BackupImportSpec) DeepCopy() *BackupImportSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(BackupImportSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *BackupImportStatus) DeepCopyInto(out *BackupImportStatus) {
+	*out = *in
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]metav1.Condition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Imported != nil {
+		in, out := &in.Imported, &out.Imported
+		*out = new(uint)
+		**out = **in
+	}
+	if in.Skipped != nil {
+		in, out := &in.Skipped, &out.Skipped
+		*out = make([]SkippedObject, len(*in))
+		copy(*out, *in)
+	}
+	if in.LastImportTime != nil {
+		in, out := &in.LastImportTime, &out.LastImportTime
+		*out = (*in).DeepCopy()
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new BackupImportStatus.
+func (in *BackupImportStatus) DeepCopy() *BackupImportStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(BackupImportStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *BackupRun) DeepCopyInto(out *BackupRun) {
 	*out = *in
@@ -149,8 +273,7 @@ func (in *BackupRunSpec) DeepCopyInto(out *BackupRunSpec) {
 	}
 	if in.Storage != nil {
 		in, out := &in.Storage, &out.Storage
-		*out = new(backupStorage)
-		**out = **in
+		*out = (*in).DeepCopy()
 	}
 	if in.Compression != nil {
 		in, out := &in.Compression, &out.Compression
@@ -206,6 +329,10 @@ func (in *BackupRunStatus) DeepCopyInto(out *BackupRunStatus) {
 		*out = new(uint)
 		**out = **in
 	}
+	if in.Checksums != nil {
+		in, out := &in.Checksums, &out.Checksums
+		*out = (*in).DeepCopy()
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new BackupRunStatus.
@@ -455,6 +582,29 @@ func (in *BackupStorageSpec) DeepCopyInto(out *BackupStorageSpec) {
 		*out = new(secretReferenceRequireNamespace)
 		**out = **in
 	}
+	if in.Inventory != nil {
+		in, out := &in.Inventory, &out.Inventory
+		*out = (*in).DeepCopy()
+	}
+	if in.Quota != nil {
+		in, out := &in.Quota, &out.Quota
+		*out = (*in).DeepCopy()
+	}
+	if in.HealthCheck != nil {
+		in, out := &in.HealthCheck, &out.HealthCheck
+		*out = (*in).DeepCopy()
+	}
+	if in.AllowedNamespaces != nil {
+		in, out := &in.AllowedNamespaces, &out.AllowedNamespaces
+		*out = make([]storageNamespaceRule, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Deduplication != nil {
+		in, out := &in.Deduplication, &out.Deduplication
+		*out = (*in).DeepCopy()
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new BackupStorageSpec.
@@ -497,6 +647,11 @@ func (in *BackupStorageStatus) DeepCopyInto(out *BackupStorageStatus) {
 		*out = new(uint)
 		**out = **in
 	}
+	if in.Inventory != nil {
+		in, out := &in.Inventory, &out.Inventory
+		*out = new(StorageInventoryStatus)
+		(*in).DeepCopyInto(*out)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new BackupStorageStatus.
@@ -528,6 +683,68 @@ func (in ClassAnnotations) DeepCopy() ClassAnnotations {
 	return *out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *OrphanedObject) DeepCopyInto(out *OrphanedObject) {
+	*out = *in
+	in.Since.DeepCopyInto(&out.Since)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new OrphanedObject.
+func (in *OrphanedObject) DeepCopy() *OrphanedObject {
+	if in == nil {
+		return nil
+	}
+	out := new(OrphanedObject)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SkippedObject) DeepCopyInto(out *SkippedObject) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SkippedObject.
+func (in *SkippedObject) DeepCopy() *SkippedObject {
+	if in == nil {
+		return nil
+	}
+	out := new(SkippedObject)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *StorageInventoryStatus) DeepCopyInto(out *StorageInventoryStatus) {
+	*out = *in
+	if in.LastInventoryTime != nil {
+		in, out := &in.LastInventoryTime, &out.LastInventoryTime
+		*out = (*in).DeepCopy()
+	}
+	if in.OrphanedObjects != nil {
+		in, out := &in.OrphanedObjects, &out.OrphanedObjects
+		*out = make([]OrphanedObject, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.MissingObjects != nil {
+		in, out := &in.MissingObjects, &out.MissingObjects
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new StorageInventoryStatus.
+func (in *StorageInventoryStatus) DeepCopy() *StorageInventoryStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(StorageInventoryStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *TemplateMetadata) DeepCopyInto(out *TemplateMetadata) {
 	*out = *in
@@ -0,0 +1,83 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Reference storage provider plugin. It serves the built-in filesystem provider over
+// the plugin protocol, so the protocol can be tested end to end.
+package main
+
+import (
+	"flag"
+	"net"
+	"os"
+	"strings"
+
+	"google.golang.org/grpc"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/log/zap"
+
+	storagepluginv1 "backup-operator.io/api/storageplugin/v1"
+	backupstorage "backup-operator.io/internal/controller/backupStorage"
+	storageplugin "backup-operator.io/internal/controller/backupStorage/plugin"
+	_ "backup-operator.io/internal/controller/backupStorage/providers"
+)
+
+var setupLog = ctrl.Log.WithName("setup")
+
+func main() {
+	var bindAddr string
+	flag.StringVar(&bindAddr, "bind-address", ":9090",
+		"The address the plugin binds to. Use unix:///path/to/socket for a unix socket.")
+	opts := zap.Options{
+		Development: true,
+	}
+	opts.BindFlags(flag.CommandLine)
+	flag.Parse()
+
+	ctrl.SetLogger(zap.New(zap.UseFlagOptions(&opts)))
+
+	registration, ok := backupstorage.GetProviderRegistration("filesystem")
+	if !ok {
+		setupLog.Info("filesystem storage provider is not registered")
+		os.Exit(1)
+	}
+
+	network, address := "tcp", bindAddr
+	if strings.HasPrefix(bindAddr, "unix://") {
+		network, address = "unix", strings.TrimPrefix(bindAddr, "unix://")
+		// Remove the socket left after the previous run
+		os.Remove(address)
+	}
+	listener, err := net.Listen(network, address)
+	if err != nil {
+		setupLog.Error(err, "unable to listen", "address", bindAddr)
+		os.Exit(1)
+	}
+
+	server := grpc.NewServer()
+	storagepluginv1.RegisterStorageProviderServer(server, storageplugin.NewServer(registration.Factory))
+
+	ctx := ctrl.SetupSignalHandler()
+	go func() {
+		<-ctx.Done()
+		server.GracefulStop()
+	}()
+
+	setupLog.Info("starting plugin", "address", bindAddr)
+	if err = server.Serve(listener); err != nil {
+		setupLog.Error(err, "problem running plugin")
+		os.Exit(1)
+	}
+}
@@ -25,10 +25,12 @@ import (
 	// to ensure that exec-entrypoint and run can make use of them.
 	_ "k8s.io/client-go/plugin/pkg/client/auth"
 
+	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
 	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/healthz"
 	"sigs.k8s.io/controller-runtime/pkg/log/zap"
 	metricsserver "sigs.k8s.io/controller-runtime/pkg/metrics/server"
@@ -36,6 +38,7 @@ import (
 
 	backupoperatoriov1 "backup-operator.io/api/v1"
 	"backup-operator.io/internal/controller"
+	backupstorage "backup-operator.io/internal/controller/backupStorage"
 	"backup-operator.io/internal/monitoring"
 	prometheus "github.com/prometheus-operator/prometheus-operator/pkg/apis/monitoring/v1"
 	//+kubebuilder:scaffold:imports
@@ -107,6 +110,12 @@ func main() {
 			SecureServing: secureMetrics,
 			TLSOpts:       tlsOpts,
 		},
+		// Secrets are read right from the API server, so the whole cluster's Secrets are not kept in memory
+		Client: client.Options{
+			Cache: &client.CacheOptions{
+				DisableFor: []client.Object{&corev1.Secret{}},
+			},
+		},
 		WebhookServer:          webhookServer,
 		HealthProbeBindAddress: probeAddr,
 		LeaderElection:         enableLeaderElection,
@@ -155,6 +164,15 @@ func main() {
 		setupLog.Error(err, "unable to create controller", "controller", "BackupRun")
 		os.Exit(1)
 	}
+	if err = (&controller.BackupImportReconciler{
+		Client:   mgr.GetClient(),
+		Config:   mgr.GetConfig(),
+		Scheme:   mgr.GetScheme(),
+		Recorder: mgr.GetEventRecorderFor("BackupImport"),
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "BackupImport")
+		os.Exit(1)
+	}
 	if os.Getenv("ENABLE_WEBHOOKS") != "false" {
 		if err = (&backupoperatoriov1.BackupRun{}).SetupWebhookWithManager(mgr); err != nil {
 			setupLog.Error(err, "unable to create webhook", "webhook", "BackupRun")
@@ -164,6 +182,12 @@ func main() {
 			setupLog.Error(err, "unable to create webhook", "webhook", "BackupSchedule")
 			os.Exit(1)
 		}
+		if err = (&backupoperatoriov1.BackupImport{}).SetupWebhookWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create webhook", "webhook", "BackupImport")
+			os.Exit(1)
+		}
+		// Validate storage type and parameters against registered storage providers
+		backupoperatoriov1.StorageProviderValidator = backupstorage.ValidateProviderSpec
 		if err = (&backupoperatoriov1.BackupStorage{}).SetupWebhookWithManager(mgr); err != nil {
 			setupLog.Error(err, "unable to create webhook", "webhook", "BackupStorage")
 			os.Exit(1)
@@ -0,0 +1,50 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package backupimport
+
+import (
+	"context"
+
+	"k8s.io/client-go/util/retry"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	backupoperatoriov1 "backup-operator.io/api/v1"
+	"backup-operator.io/internal/controller/utils"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// ChangeImportReadiness Set Ready condition of the import for its current generation
+func ChangeImportReadiness(ctx context.Context, c client.Client,
+	backupImport *backupoperatoriov1.BackupImport, ready bool, reason string, message string,
+) (err error) {
+	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		if err = c.Get(ctx, client.ObjectKeyFromObject(backupImport), backupImport); err != nil {
+			return err
+		}
+		backupImport.Status.Conditions = *utils.AddOrUpdateConditions(backupImport.Status.Conditions,
+			metav1.Condition{
+				Type:               backupoperatoriov1.ConditionTypeReady,
+				Status:             utils.ToConditionStatus(&ready),
+				Reason:             reason,
+				Message:            message,
+				LastTransitionTime: metav1.Now(),
+				ObservedGeneration: backupImport.Generation,
+			},
+		)
+		return c.Status().Update(ctx, backupImport)
+	})
+}
@@ -0,0 +1,149 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package backupimport
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strings"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/client-go/util/retry"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	backupoperatoriov1 "backup-operator.io/api/v1"
+	"backup-operator.io/internal/controller/backupRun/compression"
+	"backup-operator.io/internal/controller/backupRun/encryption"
+	backupstorage "backup-operator.io/internal/controller/backupStorage"
+	"backup-operator.io/internal/controller/utils"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// Maximum count of skipped files kept in status
+const skippedStatusLimit = 100
+
+// ImportFiles Create restore-only run for every file in the storage no other run points at
+// and save the result into the import status. Returns names of created runs.
+func ImportFiles(ctx context.Context, c client.Client, backupImport *backupoperatoriov1.BackupImport,
+	storage backupstorage.BackupStorageProvider,
+) (created []string, err error) {
+	var pattern *regexp.Regexp
+	if backupImport.Spec.Pattern != nil {
+		if pattern, err = regexp.Compile(*backupImport.Spec.Pattern); err != nil {
+			return nil, fmt.Errorf("invalid pattern: %s", err)
+		}
+	}
+	// Files are listed before runs, so a file can not appear after its run has been missed
+	var paths []string
+	if paths, err = storage.List(ctx, backupImport.Spec.Prefix); err != nil {
+		return nil, fmt.Errorf("could not list files: %s", err)
+	}
+	runs := &backupoperatoriov1.BackupRunList{}
+	if err = c.List(ctx, runs, client.MatchingFields{
+		".spec.storage.name": backupImport.Spec.Template.Spec.Storage.Name,
+	}); err != nil {
+		return nil, fmt.Errorf("could not list runs: %s", err)
+	}
+	owned := make(map[string]bool, len(runs.Items))
+	for _, run := range runs.Items {
+		owned[run.Spec.Storage.Path] = true
+	}
+	sort.Strings(paths)
+	var skipped []backupoperatoriov1.SkippedObject
+	for _, path := range paths {
+		// Skip directory markers, files of the operator itself, files of other runs and the ones not matching the pattern
+		if strings.HasSuffix(path, "/") || strings.HasPrefix(path, backupstorage.InternalPrefix) || owned[path] ||
+			(pattern != nil && !pattern.MatchString(path)) {
+			continue
+		}
+		var run *backupoperatoriov1.BackupRun
+		if run, err = createRun(ctx, c, backupImport, storage, path); apierrors.IsAlreadyExists(err) {
+			// Run has been created by the previous scan, but the cache does not know it yet
+			continue
+		} else if err != nil {
+			skipped = append(skipped, backupoperatoriov1.SkippedObject{Path: path, Reason: err.Error()})
+			continue
+		}
+		created = append(created, run.Name)
+	}
+	// Save the result
+	now := metav1.Now()
+	err = retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		if err := c.Get(ctx, client.ObjectKeyFromObject(backupImport), backupImport); err != nil {
+			return err
+		}
+		backupImport.Status.Imported = ptr.To(ptr.Deref(backupImport.Status.Imported, 0) + uint(len(created)))
+		backupImport.Status.Skipped = skipped[:min(len(skipped), skippedStatusLimit)]
+		backupImport.Status.LastImportTime = &now
+		return c.Status().Update(ctx, backupImport)
+	})
+	return
+}
+
+// createRun Create restore-only run for the file
+func createRun(ctx context.Context, c client.Client, backupImport *backupoperatoriov1.BackupImport,
+	storage backupstorage.BackupStorageProvider, path string,
+) (run *backupoperatoriov1.BackupRun, err error) {
+	var spec *backupoperatoriov1.BackupRunSpec
+	if spec, err = InferRunSpec(backupImport, path, readFileFormat(ctx, storage, path)); err != nil {
+		return
+	}
+	// Name is derived from the path, so the same file is never imported twice
+	run = &backupoperatoriov1.BackupRun{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      fmt.Sprintf("%s-%s", strings.TrimRight(backupImport.Name[:min(len(backupImport.Name), 52)], "-."), utils.Hash(path)[:10]),
+			Namespace: backupImport.Namespace,
+		},
+		Spec: *spec,
+	}
+	// Set labels and annotations if any, runs are not owned by the import, so they survive its deletion
+	annotations := make(map[string]string)
+	if backupImport.Spec.Template.Metadata != nil {
+		run.SetLabels(backupImport.Spec.Template.Metadata.Labels)
+		for k, v := range backupImport.Spec.Template.Metadata.Annotations {
+			annotations[k] = v
+		}
+	}
+	annotations[backupoperatoriov1.AnnotationImportedFrom] = backupImport.Name
+	run.SetAnnotations(annotations)
+	err = c.Create(ctx, run)
+	return
+}
+
+// readFileFormat Get metadata of the file and its first bytes, unless the metadata describes the file.
+// Anything that can not be read is left empty, e.g. archived files can not be downloaded.
+func readFileFormat(ctx context.Context, storage backupstorage.BackupStorageProvider, path string) (format FileFormat) {
+	if info, err := storage.Stat(ctx, path); err == nil {
+		format.Metadata = info.Metadata
+	}
+	if len(format.Metadata[backupstorage.MetadataRunUID]) > 0 {
+		return
+	}
+	reader, err := storage.Get(ctx, path)
+	if err != nil {
+		return
+	}
+	defer reader.Close()
+	header := make([]byte, max(encryption.HeaderLength, compression.MagicLength))
+	n, _ := io.ReadFull(reader, header)
+	format.Header = header[:n]
+	return
+}
@@ -0,0 +1,98 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package backupimport
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"k8s.io/utils/ptr"
+
+	backupoperatoriov1 "backup-operator.io/api/v1"
+	"backup-operator.io/internal/controller/backupRun/compression"
+	"backup-operator.io/internal/controller/backupRun/encryption"
+	backupstorage "backup-operator.io/internal/controller/backupStorage"
+)
+
+// FileFormat What is known about the file format before it is imported
+type FileFormat struct {
+	// Custom metadata of the file, empty if the provider does not keep it
+	Metadata map[string]string
+	// First bytes of the file, empty if they could not be read
+	Header []byte
+}
+
+// Compression algorithms by file extension
+var compressionExtensions = map[string]string{
+	".gz":   string(backupoperatoriov1.GZIP),
+	".gzip": string(backupoperatoriov1.GZIP),
+	".zst":  string(backupoperatoriov1.ZSTD),
+	".zstd": string(backupoperatoriov1.ZSTD),
+	".lz4":  string(backupoperatoriov1.LZ4),
+	".xz":   string(backupoperatoriov1.XZ),
+}
+
+// InferRunSpec Prepare restore-only run spec for the file from the import template.
+// Compression and encryption are taken from the metadata of files uploaded by the operator,
+// otherwise they are detected by the first bytes of the file. File extensions like in
+// /mysql/20240101.sql.gz.age are the last resort, e.g. for compression under encryption.
+func InferRunSpec(backupImport *backupoperatoriov1.BackupImport, path string, format FileFormat) (spec *backupoperatoriov1.BackupRunSpec, err error) {
+	spec = backupImport.Spec.Template.Spec.DeepCopy()
+	spec.Backup = nil
+	spec.RetainPolicy = ptr.To(backupoperatoriov1.BackupRetainRetain)
+	spec.Storage.Path = path
+	// Object Lock is applied on upload only
+	spec.Storage.ObjectLock = nil
+	// Encryption is the last layer
+	encrypted := strings.HasSuffix(path, ".age")
+	algorithm := compressionExtensions[filepath.Ext(strings.TrimSuffix(path, ".age"))]
+	switch {
+	case len(format.Metadata[backupstorage.MetadataRunUID]) > 0:
+		// File uploaded by the operator describes itself
+		encrypted = len(format.Metadata[backupstorage.MetadataEncryption]) > 0
+		algorithm = format.Metadata[backupstorage.MetadataCompression]
+	case len(format.Header) > 0:
+		// Compression of encrypted files can not be seen under encryption
+		if encrypted = encryption.IsEncrypted(format.Header); !encrypted {
+			algorithm = compression.Detect(format.Header)
+		}
+	}
+	if encrypted {
+		if spec.Encryption == nil {
+			return nil, errors.New("file is encrypted, but the template has no encryption")
+		}
+	} else {
+		spec.Encryption = nil
+	}
+	switch algorithm {
+	case "":
+		spec.Compression = nil
+	case string(backupoperatoriov1.GZIP):
+		spec.SetCompression(backupoperatoriov1.GZIP)
+	case string(backupoperatoriov1.ZSTD):
+		spec.SetCompression(backupoperatoriov1.ZSTD)
+	case string(backupoperatoriov1.LZ4):
+		spec.SetCompression(backupoperatoriov1.LZ4)
+	case string(backupoperatoriov1.XZ):
+		spec.SetCompression(backupoperatoriov1.XZ)
+	default:
+		return nil, fmt.Errorf("file is compressed with unknown algorithm %s", algorithm)
+	}
+	return
+}
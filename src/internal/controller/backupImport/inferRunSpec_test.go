@@ -0,0 +1,126 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package backupimport
+
+import (
+	"testing"
+
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/yaml"
+
+	backupoperatoriov1 "backup-operator.io/api/v1"
+	backupstorage "backup-operator.io/internal/controller/backupStorage"
+)
+
+func TestInferRunSpec(t *testing.T) {
+	backupImport := &backupoperatoriov1.BackupImport{}
+	if err := yaml.Unmarshal([]byte(`
+spec:
+  template:
+    spec:
+      storage:
+        name: s3
+        path: /unused
+      backup:
+        container: mysql
+        command: [mysqldump]
+      restore:
+        container: mysql
+        command: [mysql]
+      compression:
+        algorithm: zstd
+        level: 9
+      encryption:
+        recipients:
+        - age1ql3z7hjy54pw3hyww5ayyfg7zqgvc7w3j2elw8zmrj2kg5sfn9aqmcac8p
+        decryptionKey:
+          name: backup-keys
+          key: identity
+`), backupImport); err != nil {
+		t.Fatal(err)
+	}
+	uploaded := func(compression, encryption string) map[string]string {
+		metadata := map[string]string{backupstorage.MetadataRunUID: "uid"}
+		if len(compression) > 0 {
+			metadata[backupstorage.MetadataCompression] = compression
+		}
+		if len(encryption) > 0 {
+			metadata[backupstorage.MetadataEncryption] = encryption
+		}
+		return metadata
+	}
+	gzipHeader, zstdHeader := []byte{0x1f, 0x8b, 0x08, 0x00}, []byte{0x28, 0xb5, 0x2f, 0xfd, 0x00}
+	ageHeader := []byte("age-encryption.org/v1\n")
+	for _, tc := range []struct {
+		name        string
+		path        string
+		format      FileFormat
+		compression string
+		encrypted   bool
+		fails       bool
+	}{
+		// Extensions are used if nothing else is known
+		{name: "plain extension", path: "/mysql/db.sql"},
+		{name: "compressed extension", path: "/mysql/db.sql.gz", compression: "gzip"},
+		{name: "encrypted extension", path: "/mysql/db.sql.zst.age", compression: "zstd", encrypted: true},
+		// First bytes are trusted more than extensions
+		{name: "plain header", path: "/mysql/db.sql.gz", format: FileFormat{Header: []byte("backup")}},
+		{name: "compressed header", path: "/mysql/db.sql", format: FileFormat{Header: zstdHeader}, compression: "zstd"},
+		{name: "misleading extension", path: "/mysql/db.sql.xz", format: FileFormat{Header: gzipHeader}, compression: "gzip"},
+		{name: "encrypted header", path: "/mysql/db.sql.lz4", format: FileFormat{Header: ageHeader}, compression: "lz4", encrypted: true},
+		// Metadata of files uploaded by the operator is trusted the most
+		{name: "uploaded", path: "/mysql/db", format: FileFormat{Metadata: uploaded("xz", "age"), Header: ageHeader},
+			compression: "xz", encrypted: true},
+		{name: "uploaded plain", path: "/mysql/db.sql.gz.age", format: FileFormat{Metadata: uploaded("", "")}},
+		{name: "foreign metadata", path: "/mysql/db.sql.gz", format: FileFormat{Metadata: map[string]string{"compression": "xz"}},
+			compression: "gzip"},
+		{name: "unknown algorithm", path: "/mysql/db", format: FileFormat{Metadata: uploaded("brotli", "")}, fails: true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			spec, err := InferRunSpec(backupImport, tc.path, tc.format)
+			if tc.fails {
+				if err == nil {
+					t.Fatal("file is imported")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if spec.Backup != nil || spec.Storage.Path != tc.path {
+				t.Errorf("run is not restore-only for the file: backup %v, path %s", spec.Backup, spec.Storage.Path)
+			}
+			if (spec.Encryption != nil) != tc.encrypted {
+				t.Errorf("encryption is %v", spec.Encryption)
+			}
+			switch {
+			case len(tc.compression) == 0 && spec.Compression != nil:
+				t.Errorf("compression %s is set", spec.Compression.Algorithm)
+			case len(tc.compression) > 0 && (spec.Compression == nil || string(spec.Compression.Algorithm) != tc.compression):
+				t.Errorf("compression is %v, expected %s", spec.Compression, tc.compression)
+			case tc.compression == "zstd" && ptr.Deref(spec.Compression.Level, 0) != 9:
+				t.Errorf("level of the template is dropped")
+			}
+		})
+	}
+
+	// Encrypted file can not be restored without the key
+	backupImport.Spec.Template.Spec.Encryption = nil
+	if _, err := InferRunSpec(backupImport, "/mysql/db.sql", FileFormat{Header: ageHeader}); err == nil {
+		t.Error("encrypted file is imported without encryption in the template")
+	}
+}
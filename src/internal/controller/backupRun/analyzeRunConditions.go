@@ -49,6 +49,12 @@ type BackupRunState struct {
 	HaveToBackup bool
 	// True if we have to make a restoration
 	HaveToRestore bool
+	// True if run has been created by BackupImport for the existing backup file
+	Imported bool
+	// True if restoration has failed since the backup file does not match its checksum
+	ChecksumMismatch bool
+	// True if restoration has failed since the backup file is not encrypted or compressed the way the run is
+	FormatMismatch bool
 }
 
 var inProgressRuns = &sync.Map{}
@@ -89,6 +95,8 @@ func AnalyzeRunConditions(run *backupoperatoriov1.BackupRun) (s *BackupRunState)
 	backupIsDefined := run.Spec.Backup != nil
 	restoreIsDefined := run.Spec.Restore != nil
 	restoreOnlyMode := !backupIsDefined && restoreIsDefined
+	// Imported backup exists already, so it is restored only on demand like the completed one
+	_, s.Imported = run.GetAnnotations()[backupoperatoriov1.AnnotationImportedFrom]
 	// Determine if backup is necessary based on the following conditions:
 	//   - If nothing has been completed,
 	//   - If a backup block is defined, and
@@ -97,9 +105,9 @@ func AnalyzeRunConditions(run *backupoperatoriov1.BackupRun) (s *BackupRunState)
 		backupIsDefined &&
 		s.NeverRun
 	// Determine if restoration is necessary based on the following conditions:
-	//   - If in restore-only mode and the backup is not completed or it was, but repeat is requested
+	//   - If in restore-only mode and the backup is not completed or imported, or it was, but repeat is requested
 	//   - If not in restore-only mode and the restore block is defined, and a restore is requested, and backup is completed
-	s.HaveToRestore = (restoreOnlyMode && (!s.Completed || restoreAnnotationExists)) ||
+	s.HaveToRestore = (restoreOnlyMode && (!(s.Completed || s.Imported) || restoreAnnotationExists)) ||
 		(restoreIsDefined && s.Completed && restoreAnnotationExists)
 	// Check encryption
 	s.Encrypted = run.Spec.Encryption != nil
@@ -18,6 +18,8 @@ package backuprun
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
 	"io"
 	"os"
@@ -30,17 +32,21 @@ import (
 	"backup-operator.io/internal/controller/backupRun/compression"
 	"backup-operator.io/internal/controller/backupRun/encryption"
 	backupstorage "backup-operator.io/internal/controller/backupStorage"
+	"backup-operator.io/internal/controller/utils"
 	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/client-go/rest"
 	"k8s.io/utils/ptr"
 )
 
 // Make a backup run
-func Backup(ctx context.Context, _ client.Client, _ *runtime.Scheme,
+func Backup(ctx context.Context, c client.Client, _ *runtime.Scheme,
 	config *rest.Config, run *backupoperatoriov1.BackupRun,
 	pod *corev1.Pod, storage backupstorage.BackupStorageProvider,
 ) (err error) {
+	// Provider must not be destroyed between operations if the storage is reconfigured meanwhile
+	defer backupstorage.Hold(storage)()
 	state := AnalyzeRunConditions(run)
 	action := run.Spec.Backup
 
@@ -67,11 +73,32 @@ func Backup(ctx context.Context, _ client.Client, _ *runtime.Scheme,
 	if encryptor, compressor, err = getEncryptorAndCompressor(run); err != nil {
 		return
 	}
-	// Start stream to storage file
+	setDefaultConcurrency(ctx, c, run, compressor)
+	// Options are applied on promotion, but unsupported ones must fail before the backup is made
+	if err = backupstorage.CheckPutOptions(storage, getPutOptions(run)); err != nil {
+		return
+	}
+	// Start stream to the staging file, so a failed backup never looks like a complete one at its path
+	stagingPath := backupstorage.StagingPath(run)
+	// Checksums of the data produced by the command and of the data uploaded to the storage
+	rawHash, storedHash := sha256.New(), sha256.New()
 	storageRoutineEgr, storageRoutineEgrCtx := errgroup.WithContext(context.WithoutCancel(ctx))
 	storageRoutineEgr.Go(func() (err error) {
-		return storage.Put(storageRoutineEgrCtx, run.Spec.Storage.Path, resultReader)
+		return storage.Put(storageRoutineEgrCtx, stagingPath, io.TeeReader(resultReader, storedHash), getPutMetadata(run))
 	})
+	// Nothing is left to clean up once the backup is published
+	var published bool
+	defer func() {
+		if err == nil || published {
+			return
+		}
+		// Stop the upload and wait for it, so nothing is written after the staging file is deleted
+		resultWriter.CloseWithError(err)
+		storageRoutineEgr.Wait()
+		if deleteErr := storage.Delete(context.WithoutCancel(ctx), stagingPath); deleteErr != nil {
+			err = fmt.Errorf("%s, failed to delete staging file: %s", err, deleteErr)
+		}
+	}()
 	// We have 4 possible schemes
 	switch {
 	case !state.Encrypted && !state.Compressed:
@@ -80,7 +107,7 @@ func Backup(ctx context.Context, _ client.Client, _ *runtime.Scheme,
 		// Will be closed by default
 	case !state.Encrypted && state.Compressed:
 		// exec -> compression -> result -> storage
-		if stdout, err = compressor.Compress(resultWriter, int(run.Spec.Compression.Level)); err != nil {
+		if stdout, err = compressor.Compress(resultWriter, compressionLevel(run)); err != nil {
 			err = fmt.Errorf("failed to create compressor writer: %s", err.Error())
 			return
 		}
@@ -96,14 +123,14 @@ func Backup(ctx context.Context, _ client.Client, _ *runtime.Scheme,
 			err = fmt.Errorf("failed to create encryptor writer: %s", err.Error())
 			return
 		}
-		if stdout, err = compressor.Compress(encryptionWriter, int(run.Spec.Compression.Level)); err != nil {
+		if stdout, err = compressor.Compress(encryptionWriter, compressionLevel(run)); err != nil {
 			err = fmt.Errorf("failed to create compressor writer: %s", err.Error())
 			return
 		}
 	}
 	defer stdout.Close()
 	// Make Pod exec
-	exec.Stdout = stdout
+	exec.Stdout = io.MultiWriter(stdout, rawHash)
 	if err = podExec(ctx, config, pod, exec); err != nil {
 		return
 	}
@@ -122,5 +149,57 @@ func Backup(ctx context.Context, _ client.Client, _ *runtime.Scheme,
 		err = fmt.Errorf("failed storage put routine: %s", err.Error())
 		return
 	}
+	// Publish the complete backup at its path with checksums known only now
+	raw, stored := hex.EncodeToString(rawHash.Sum(nil)), hex.EncodeToString(storedHash.Sum(nil))
+	metadata := getPutMetadata(run)
+	metadata[backupstorage.MetadataRawSHA256] = raw
+	metadata[backupstorage.MetadataSHA256] = stored
+	// Retention is counted from the upload of the final file, not from the start of a long backup
+	if err = backupstorage.Promote(context.WithoutCancel(ctx), storage, stagingPath, run.Spec.Storage.Path,
+		metadata, getPutOptions(run)); err != nil {
+		err = fmt.Errorf("failed to promote staging file: %s", err.Error())
+		return
+	}
+	published = true
+	// Published backup must not fail the run, checksums are saved again on the next reconciliation
+	if err = setChecksumsInStatus(ctx, c, run, raw, stored); err != nil {
+		pendingChecksums.Store(run.UID, backupChecksums{raw: raw, stored: stored})
+		err = fmt.Errorf("%w: %s", ErrChecksumsPending, err)
+	}
+	return
+}
+
+// getPutMetadata Describe the backup in the file metadata, so the file can be identified without the run
+func getPutMetadata(run *backupoperatoriov1.BackupRun) map[string]string {
+	metadata := map[string]string{
+		backupstorage.MetadataRunUID:          string(run.UID),
+		backupstorage.MetadataRun:             fmt.Sprintf("%s/%s", run.Namespace, run.Name),
+		backupstorage.MetadataPath:            run.Spec.Storage.Path,
+		backupstorage.MetadataOperatorVersion: utils.Version,
+	}
+	if owner := metav1.GetControllerOf(run); owner != nil && owner.Kind == "BackupSchedule" {
+		metadata[backupstorage.MetadataSchedule] = owner.Name
+	}
+	if run.Spec.Compression != nil {
+		metadata[backupstorage.MetadataCompression] = string(run.Spec.Compression.Algorithm)
+	}
+	if run.Spec.Encryption != nil {
+		metadata[backupstorage.MetadataEncryption] = "age"
+	}
+	return metadata
+}
+
+// getPutOptions Prepare upload options from the run spec. Object Lock retention starts now,
+// so options must be prepared right before the file is written at its path.
+func getPutOptions(run *backupoperatoriov1.BackupRun) (options backupstorage.PutOptions) {
+	if run.Spec.Storage.StorageClass != nil {
+		options.StorageClass = *run.Spec.Storage.StorageClass
+	}
+	if lock := run.Spec.Storage.ObjectLock; lock != nil {
+		options.Retention = &backupstorage.ObjectRetention{
+			Mode:        string(lock.Mode),
+			RetainUntil: time.Now().Add(lock.Retention.Duration),
+		}
+	}
 	return
 }
@@ -0,0 +1,83 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package backuprun
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	backupoperatoriov1 "backup-operator.io/api/v1"
+)
+
+func TestGetPutOptions(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		storage string
+		// Expected options, retain until is counted from now
+		storageClass string
+		mode         string
+		retention    time.Duration
+	}{
+		{name: "no options", storage: `{"name": "s3", "path": "/db.gz"}`},
+		{
+			name:         "storage class",
+			storage:      `{"name": "s3", "path": "/db.gz", "storageClass": "GLACIER_IR"}`,
+			storageClass: "GLACIER_IR",
+		},
+		{
+			name:      "compliance retention",
+			storage:   `{"name": "s3", "path": "/db.gz", "objectLock": {"mode": "COMPLIANCE", "retention": "720h"}}`,
+			mode:      "COMPLIANCE",
+			retention: 720 * time.Hour,
+		},
+		{
+			name: "governance retention with storage class",
+			storage: `{"name": "s3", "path": "/db.gz", "storageClass": "STANDARD_IA",
+				"objectLock": {"mode": "GOVERNANCE", "retention": "36h30m"}}`,
+			storageClass: "STANDARD_IA",
+			mode:         "GOVERNANCE",
+			retention:    36*time.Hour + 30*time.Minute,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			run := &backupoperatoriov1.BackupRun{}
+			if err := json.Unmarshal([]byte(`{"storage": `+tc.storage+`}`), &run.Spec); err != nil {
+				t.Fatal(err)
+			}
+			before := time.Now()
+			options := getPutOptions(run)
+			after := time.Now()
+			if options.StorageClass != tc.storageClass {
+				t.Errorf("got storage class %q, expected %q", options.StorageClass, tc.storageClass)
+			}
+			switch {
+			case tc.retention == 0 && options.Retention != nil:
+				t.Errorf("got retention %+v without object lock", *options.Retention)
+			case tc.retention == 0:
+			case options.Retention == nil:
+				t.Error("retention is not set")
+			case options.Retention.Mode != tc.mode:
+				t.Errorf("got mode %q, expected %q", options.Retention.Mode, tc.mode)
+			// Retention starts when the options are prepared, right before the upload
+			case options.Retention.RetainUntil.Before(before.Add(tc.retention)) ||
+				options.Retention.RetainUntil.After(after.Add(tc.retention)):
+				t.Errorf("got retain until %s, expected %s from now", options.Retention.RetainUntil, tc.retention)
+			}
+		})
+	}
+}
@@ -76,6 +76,14 @@ func ChangeRunState(ctx context.Context, c client.Client,
 				reason = "BackupFailed"
 				message = "Backup failed"
 				run.Status.State = ptr.To("BackupFailed")
+			case state.HaveToRestore && state.ChecksumMismatch:
+				reason = "ChecksumMismatch"
+				message = "Backup file does not match its checksum, it has been corrupted or changed"
+				run.Status.State = ptr.To("RestoreFailed")
+			case state.HaveToRestore && state.FormatMismatch:
+				reason = "FormatMismatch"
+				message = "Backup file is not encrypted or compressed the way the run is"
+				run.Status.State = ptr.To("RestoreFailed")
 			case state.HaveToRestore:
 				reason = "RestoreFailed"
 				message = "Restore failed"
@@ -101,6 +109,10 @@ func ChangeRunState(ctx context.Context, c client.Client,
 				reason = "RestoreSuccessful"
 				message = "Restore successful"
 				run.Status.State = ptr.To("RestoreSuccessful")
+			case state.Imported:
+				reason = "Imported"
+				message = "Existing backup has been imported"
+				run.Status.State = ptr.To("Imported")
 			default:
 				reason = "Unknown"
 				message = string(ct)
@@ -0,0 +1,45 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package backuprun
+
+import (
+	"context"
+
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	backupoperatoriov1 "backup-operator.io/api/v1"
+	backupstorage "backup-operator.io/internal/controller/backupStorage"
+)
+
+// CheckStorageQuota Check whether the storage of the run is over its quota, so the backup has to wait
+func CheckStorageQuota(ctx context.Context, c client.Client,
+	run *backupoperatoriov1.BackupRun,
+) (exceeded bool, message string, err error) {
+	// Provider object keeps the storage as it was configured, while totals are updated in status
+	storage := &backupoperatoriov1.BackupStorage{}
+	if err = c.Get(ctx, types.NamespacedName{Name: run.Spec.Storage.Name}, storage); err != nil {
+		return
+	}
+	// Runs of the storage decide whether there is room for the run
+	runs := &backupoperatoriov1.BackupRunList{}
+	if err = c.List(ctx, runs, client.MatchingFields{".spec.storage.name": storage.Name}); err != nil {
+		return
+	}
+	exceeded, message = backupstorage.CheckRunQuota(storage, runs.Items, run)
+	return
+}
@@ -0,0 +1,134 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package backuprun
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"sync"
+
+	"k8s.io/client-go/util/retry"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	backupoperatoriov1 "backup-operator.io/api/v1"
+	backupstorage "backup-operator.io/internal/controller/backupStorage"
+)
+
+// ErrChecksumMismatch is returned by Restore if the backup file differs from the one that has been uploaded
+var ErrChecksumMismatch = errors.New("checksum mismatch")
+
+// ErrChecksumsPending is returned by Backup if the backup has been published, but its checksums
+// could not be saved in status, they are saved by SetPendingChecksums later
+var ErrChecksumsPending = errors.New("checksums are not saved in status yet")
+
+// Checksums of published backups by run UID, which are still to be saved in status
+var pendingChecksums = &sync.Map{}
+
+// backupChecksums Hex encoded checksums of the raw and the stored data
+type backupChecksums struct {
+	raw, stored string
+}
+
+// checksumReader Calculate SHA-256 of everything read through it
+type checksumReader struct {
+	reader io.Reader
+	hash   hash.Hash
+	eof    bool
+}
+
+func newChecksumReader(reader io.Reader) *checksumReader {
+	return &checksumReader{reader: reader, hash: sha256.New()}
+}
+
+func (r *checksumReader) Read(p []byte) (n int, err error) {
+	n, err = r.reader.Read(p)
+	r.hash.Write(p[:n])
+	if err == io.EOF {
+		r.eof = true
+	}
+	return
+}
+
+// verify Compare checksum of the whole file with the expected one.
+// Readers like decompressors may stop before the end, so the rest is read if drain is set,
+// otherwise a partially read file is not verified.
+func (r *checksumReader) verify(expected string, drain bool) error {
+	if !r.eof {
+		if !drain {
+			return nil
+		}
+		if _, err := io.Copy(io.Discard, r); err != nil {
+			return fmt.Errorf("failed to read the rest of the backup: %s", err)
+		}
+	}
+	if actual := hex.EncodeToString(r.hash.Sum(nil)); actual != expected {
+		return fmt.Errorf("%w: backup file has SHA-256 %s, but %s is expected", ErrChecksumMismatch, actual, expected)
+	}
+	return nil
+}
+
+// getStoredChecksum Get checksum the backup file must have. Imported runs have it in the file metadata only,
+// while backups made before checksums have been introduced have none, so they are not verified.
+func getStoredChecksum(ctx context.Context, run *backupoperatoriov1.BackupRun,
+	storage backupstorage.BackupStorageProvider,
+) string {
+	if run.Status.Checksums != nil && len(run.Status.Checksums.Stored) > 0 {
+		return run.Status.Checksums.Stored
+	}
+	if info, err := storage.Stat(ctx, run.Spec.Storage.Path); err == nil && isChecksum(info.Metadata[backupstorage.MetadataSHA256]) {
+		return info.Metadata[backupstorage.MetadataSHA256]
+	}
+	return ""
+}
+
+// isChecksum Check the string is hex encoded SHA-256
+func isChecksum(s string) bool {
+	decoded, err := hex.DecodeString(s)
+	return err == nil && len(decoded) == sha256.Size && s == hex.EncodeToString(decoded)
+}
+
+// SetPendingChecksums Save checksums of the published backup in status, if it has failed after the backup
+func SetPendingChecksums(ctx context.Context, c client.Client, run *backupoperatoriov1.BackupRun) error {
+	value, pending := pendingChecksums.Load(run.UID)
+	if !pending {
+		return nil
+	}
+	checksums := value.(backupChecksums)
+	if err := setChecksumsInStatus(ctx, c, run, checksums.raw, checksums.stored); client.IgnoreNotFound(err) != nil {
+		return err
+	}
+	pendingChecksums.Delete(run.UID)
+	return nil
+}
+
+// setChecksumsInStatus updates checksums status field
+func setChecksumsInStatus(ctx context.Context, c client.Client,
+	run *backupoperatoriov1.BackupRun, raw, stored string,
+) error {
+	return retry.RetryOnConflict(retry.DefaultRetry, func() (err error) {
+		if err = c.Get(ctx, client.ObjectKeyFromObject(run), run); err != nil {
+			return err
+		}
+		run.Status.SetChecksums(raw, stored)
+		return c.Status().Update(ctx, run)
+	})
+}
@@ -0,0 +1,106 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package backuprun
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+
+	backupoperatoriov1 "backup-operator.io/api/v1"
+)
+
+// newFakeClient Make a client of the fake API server with the objects, status updates fail if failing is set
+func newFakeClient(t *testing.T, failing *bool, objects ...client.Object) client.Client {
+	t.Helper()
+	scheme := runtime.NewScheme()
+	if err := backupoperatoriov1.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	return fake.NewClientBuilder().WithScheme(scheme).WithObjects(objects...).
+		WithStatusSubresource(objects...).WithInterceptorFuncs(interceptor.Funcs{
+		SubResourceUpdate: func(ctx context.Context, c client.Client, subResource string, o client.Object,
+			opts ...client.SubResourceUpdateOption,
+		) error {
+			if *failing {
+				return errors.New("API server is not available")
+			}
+			return c.SubResource(subResource).Update(ctx, o, opts...)
+		},
+	}).Build()
+}
+
+func TestSetPendingChecksums(t *testing.T) {
+	ctx := context.Background()
+	raw, stored := strings.Repeat("a", 64), strings.Repeat("b", 64)
+	run := &backupoperatoriov1.BackupRun{ObjectMeta: metav1.ObjectMeta{
+		Name: "pending", Namespace: "default", UID: types.UID("pending"),
+	}}
+	failing := true
+	c := newFakeClient(t, &failing, run)
+	// Nothing is saved for runs without pending checksums
+	if err := SetPendingChecksums(ctx, c, run); err != nil {
+		t.Fatal(err)
+	}
+
+	pendingChecksums.Store(run.UID, backupChecksums{raw: raw, stored: stored})
+	defer pendingChecksums.Delete(run.UID)
+	if err := SetPendingChecksums(ctx, c, run); err == nil {
+		t.Fatal("status update has not failed")
+	}
+	if _, pending := pendingChecksums.Load(run.UID); !pending {
+		t.Fatal("checksums are not pending after the failure")
+	}
+
+	failing = false
+	if err := SetPendingChecksums(ctx, c, run); err != nil {
+		t.Fatal(err)
+	}
+	saved := &backupoperatoriov1.BackupRun{}
+	if err := c.Get(ctx, client.ObjectKeyFromObject(run), saved); err != nil {
+		t.Fatal(err)
+	}
+	if saved.Status.Checksums == nil || saved.Status.Checksums.Raw != raw || saved.Status.Checksums.Stored != stored {
+		t.Errorf("got checksums %+v in status", saved.Status.Checksums)
+	}
+	if _, pending := pendingChecksums.Load(run.UID); pending {
+		t.Error("checksums are still pending after they have been saved")
+	}
+}
+
+func TestSetPendingChecksumsOfDeletedRun(t *testing.T) {
+	run := &backupoperatoriov1.BackupRun{ObjectMeta: metav1.ObjectMeta{
+		Name: "deleted", Namespace: "default", UID: types.UID("deleted"),
+	}}
+	failing := false
+	pendingChecksums.Store(run.UID, backupChecksums{raw: strings.Repeat("a", 64), stored: strings.Repeat("b", 64)})
+	defer pendingChecksums.Delete(run.UID)
+	if err := SetPendingChecksums(context.Background(), newFakeClient(t, &failing), run); err != nil {
+		t.Fatal(err)
+	}
+	if _, pending := pendingChecksums.Load(run.UID); pending {
+		t.Error("checksums of the deleted run are still pending")
+	}
+}
@@ -19,19 +19,62 @@ package compression
 import (
 	"compress/gzip"
 	"io"
+	"math"
+
+	"github.com/klauspost/pgzip"
 )
 
+// Size of blocks compressed in parallel by gzip if it is not set
+const gzipDefaultBlockSize = 1 << 20
+
+func init() {
+	Register(Registration{
+		Algorithm: "gzip",
+		Magic:     []byte{0x1f, 0x8b},
+		Factory: func(settings Settings) Compression {
+			return &GZIPCompression{Concurrency: settings.Concurrency, BlockSize: settings.BlockSize}
+		},
+	})
+}
+
+// DefaultLevel Level passed to compressors if it is not set, every algorithm picks its own default
+const DefaultLevel = math.MinInt
+
 type Compression interface {
 	Compress(out io.Writer, level int) (compressed io.WriteCloser, err error)
 	Decompress(in io.Reader) (plain io.ReadCloser, err error)
 }
 
-type GZIPCompression struct{}
+// GZIPCompression Gzip compression. Level follows compress/flate levels from -2 to 9, no compression by default.
+type GZIPCompression struct {
+	// Count of blocks compressed in parallel, output is still a standard gzip stream
+	Concurrency int
+	// Size of blocks compressed in parallel, 1MiB if not set
+	BlockSize int
+}
 
 func (g *GZIPCompression) Compress(out io.Writer, level int) (compressed io.WriteCloser, err error) {
-	return gzip.NewWriterLevel(out, level)
+	if level == DefaultLevel {
+		level = gzip.NoCompression
+	}
+	if g.Concurrency <= 1 {
+		return gzip.NewWriterLevel(out, level)
+	}
+	var writer *pgzip.Writer
+	if writer, err = pgzip.NewWriterLevel(out, level); err != nil {
+		return
+	}
+	blockSize := g.BlockSize
+	if blockSize <= 0 {
+		blockSize = gzipDefaultBlockSize
+	}
+	if err = writer.SetConcurrency(blockSize, g.Concurrency); err != nil {
+		return
+	}
+	return writer, nil
 }
 
+// Decompress Output of both single-threaded and parallel compression is read the same way
 func (g *GZIPCompression) Decompress(in io.Reader) (plain io.ReadCloser, err error) {
 	return gzip.NewReader(in)
 }
@@ -0,0 +1,201 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package compression
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"math/rand"
+	"testing"
+
+	backupoperatoriov1 "backup-operator.io/api/v1"
+)
+
+// testData Generate reproducible data that compresses moderately
+func testData(size int) []byte {
+	random := rand.New(rand.NewSource(int64(size)))
+	words := [][]byte{[]byte("backup "), []byte("operator "), []byte("restore "), []byte("\n")}
+	data := make([]byte, 0, size+16)
+	for len(data) < size {
+		if random.Intn(4) == 0 {
+			data = append(data, byte(random.Intn(256)))
+		} else {
+			data = append(data, words[random.Intn(len(words))]...)
+		}
+	}
+	return data[:size]
+}
+
+// compress Compress data writing it in uneven pieces
+func compress(t *testing.T, c Compression, level int, data []byte) []byte {
+	t.Helper()
+	var buffer bytes.Buffer
+	writer, err := c.Compress(&buffer, level)
+	if err != nil {
+		t.Fatalf("failed to create compressor: %s", err)
+	}
+	for rest, piece := data, 1; len(rest) > 0; piece = piece*3 + 1 {
+		n := min(piece, len(rest))
+		if _, err = writer.Write(rest[:n]); err != nil {
+			t.Fatalf("failed to compress: %s", err)
+		}
+		rest = rest[n:]
+	}
+	if err = writer.Close(); err != nil {
+		t.Fatalf("failed to finish compression: %s", err)
+	}
+	return buffer.Bytes()
+}
+
+func TestGZIPParallelRoundTrip(t *testing.T) {
+	data := testData(5<<20 + 123)
+	for _, blockSize := range []int{0, 64 << 10, 1 << 20, 4 << 20} {
+		for _, concurrency := range []int{1, 2, 8} {
+			for _, level := range []int{gzip.HuffmanOnly, gzip.BestSpeed, gzip.DefaultCompression} {
+				t.Run(fmt.Sprintf("block %d concurrency %d level %d", blockSize, concurrency, level), func(t *testing.T) {
+					c := &GZIPCompression{Concurrency: concurrency, BlockSize: blockSize}
+					compressed := compress(t, c, level, data)
+					if Detect(compressed) != "gzip" {
+						t.Fatal("output is not detected as gzip")
+					}
+					// Parallel output is a standard gzip stream
+					reader, err := gzip.NewReader(bytes.NewReader(compressed))
+					if err != nil {
+						t.Fatalf("failed to read gzip header: %s", err)
+					}
+					if plain, err := io.ReadAll(reader); err != nil || !bytes.Equal(plain, data) {
+						t.Errorf("gzip reader has got different data, error %v", err)
+					}
+					decompressed, err := c.Decompress(bytes.NewReader(compressed))
+					if err != nil {
+						t.Fatalf("failed to decompress: %s", err)
+					}
+					defer decompressed.Close()
+					if plain, err := io.ReadAll(decompressed); err != nil || !bytes.Equal(plain, data) {
+						t.Errorf("decompressor has got different data, error %v", err)
+					}
+				})
+			}
+		}
+	}
+}
+
+func TestGZIPParallelEmpty(t *testing.T) {
+	c := &GZIPCompression{Concurrency: 4, BlockSize: 64 << 10}
+	decompressed, err := c.Decompress(bytes.NewReader(compress(t, c, gzip.DefaultCompression, nil)))
+	if err != nil {
+		t.Fatalf("failed to decompress: %s", err)
+	}
+	if plain, err := io.ReadAll(decompressed); err != nil || len(plain) != 0 {
+		t.Errorf("got %d bytes and error %v, expected empty data", len(plain), err)
+	}
+}
+
+func TestGZIPInvalidSettings(t *testing.T) {
+	for _, c := range []struct {
+		compression GZIPCompression
+		level       int
+	}{
+		{GZIPCompression{Concurrency: 2, BlockSize: 512}, gzip.DefaultCompression},
+		{GZIPCompression{Concurrency: 2}, 10},
+		{GZIPCompression{Concurrency: 2}, -3},
+		{GZIPCompression{Concurrency: 1}, 10},
+	} {
+		if _, err := c.compression.Compress(io.Discard, c.level); err == nil {
+			t.Errorf("%+v with level %d: compressor has been created", c.compression, c.level)
+		}
+	}
+}
+
+func TestRegisteredAlgorithms(t *testing.T) {
+	// Every algorithm allowed in BackupRun spec has an implementation
+	expected := []string{
+		string(backupoperatoriov1.GZIP), string(backupoperatoriov1.LZ4),
+		string(backupoperatoriov1.XZ), string(backupoperatoriov1.ZSTD),
+	}
+	if algorithms := Algorithms(); fmt.Sprint(algorithms) != fmt.Sprint(expected) {
+		t.Fatalf("registered algorithms are %v, expected %v", algorithms, expected)
+	}
+	data := testData(64 << 10)
+	for _, algorithm := range Algorithms() {
+		t.Run(algorithm, func(t *testing.T) {
+			c, err := New(algorithm, Settings{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			compressed := compress(t, c, 0, data)
+			// Registered magic bytes detect the stream of the algorithm
+			if detected := Detect(compressed[:MagicLength]); detected != algorithm {
+				t.Errorf("stream is detected as %q", detected)
+			}
+			reader, err := c.Decompress(bytes.NewReader(compressed))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer reader.Close()
+			if plain, err := io.ReadAll(reader); err != nil || !bytes.Equal(plain, data) {
+				t.Errorf("round trip has failed: %v", err)
+			}
+		})
+	}
+	if detected := Detect(data[:MagicLength]); len(detected) > 0 {
+		t.Errorf("plain data is detected as %q", detected)
+	}
+	if _, err := New("brotli", Settings{}); err == nil {
+		t.Error("unknown algorithm is created")
+	}
+}
+
+func TestXZPresets(t *testing.T) {
+	// Random block repeated 512KiB back fits into the dictionary of the default preset 6 only
+	block := make([]byte, 512<<10)
+	rand.New(rand.NewSource(1)).Read(block)
+	data := append(bytes.Clone(block), block...)
+	c := &XZCompression{}
+	sizes := map[int]int{}
+	for _, level := range []int{DefaultLevel, 0, 6} {
+		compressed := compress(t, c, level, data)
+		reader, err := c.Decompress(bytes.NewReader(compressed))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if plain, err := io.ReadAll(reader); err != nil || !bytes.Equal(plain, data) {
+			t.Errorf("level %d: round trip has failed: %v", level, err)
+		}
+		sizes[level] = len(compressed)
+	}
+	if sizes[DefaultLevel] != sizes[6] {
+		t.Errorf("default preset has compressed to %d bytes, preset 6 to %d", sizes[DefaultLevel], sizes[6])
+	}
+	if sizes[0] < len(data) {
+		t.Errorf("preset 0 has found matches %d bytes back", len(block))
+	}
+}
+
+func TestZstdNegativeLevels(t *testing.T) {
+	// Negative levels allowed by the webhook are compressed with the fastest encoder level like level 1
+	data := testData(256 << 10)
+	c := &ZstdCompression{}
+	fastest := compress(t, c, 1, data)
+	for _, level := range []int{-1, -128} {
+		if compressed := compress(t, c, level, data); !bytes.Equal(compressed, fastest) {
+			t.Errorf("level %d: output differs from level 1", level)
+		}
+	}
+}
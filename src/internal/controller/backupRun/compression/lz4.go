@@ -0,0 +1,53 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package compression
+
+import (
+	"io"
+
+	"github.com/pierrec/lz4/v4"
+)
+
+func init() {
+	Register(Registration{
+		Algorithm: "lz4",
+		Magic:     []byte{0x04, 0x22, 0x4d, 0x18},
+		Factory: func(_ Settings) Compression {
+			return &LZ4Compression{}
+		},
+	})
+}
+
+// LZ4Compression LZ4 frame compression. Level 0 is the fast one, levels from 1 to 9 are high compression ones,
+// which are slower to compress, while decompression is equally fast.
+type LZ4Compression struct{}
+
+func (l *LZ4Compression) Compress(out io.Writer, level int) (compressed io.WriteCloser, err error) {
+	compressionLevel := lz4.Fast
+	if level > 0 {
+		compressionLevel = lz4.Level1 << (level - 1)
+	}
+	writer := lz4.NewWriter(out)
+	if err = writer.Apply(lz4.CompressionLevelOption(compressionLevel)); err != nil {
+		return
+	}
+	return writer, nil
+}
+
+func (l *LZ4Compression) Decompress(in io.Reader) (plain io.ReadCloser, err error) {
+	return io.NopCloser(lz4.NewReader(in)), nil
+}
@@ -0,0 +1,89 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package compression
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+)
+
+// Settings Run settings compressions may use, the ones an algorithm does not support are ignored
+type Settings struct {
+	// Count of blocks compressed in parallel
+	Concurrency int
+	// Size of blocks compressed in parallel
+	BlockSize int
+	// Find matches within the larger window
+	LongDistanceMatching bool
+}
+
+// Registration Compression algorithm with the magic bytes its streams start with
+type Registration struct {
+	// Algorithm name as in BackupRun spec
+	Algorithm string
+	// Magic bytes every stream starts with, up to MagicLength
+	Magic []byte
+	// Create compression with the run settings
+	Factory func(settings Settings) Compression
+}
+
+// MagicLength Count of the first bytes of the stream enough to detect any algorithm
+const MagicLength = 6
+
+// Registered algorithms by name
+var registrations = map[string]Registration{}
+
+// Register Add the algorithm, it is called from init of the file implementing it
+func Register(registration Registration) {
+	if len(registration.Magic) == 0 || len(registration.Magic) > MagicLength {
+		panic(fmt.Sprintf("magic bytes of compression %s must be from 1 to %d bytes long",
+			registration.Algorithm, MagicLength))
+	}
+	if _, registered := registrations[registration.Algorithm]; registered {
+		panic(fmt.Sprintf("compression %s is registered twice", registration.Algorithm))
+	}
+	registrations[registration.Algorithm] = registration
+}
+
+// New Create compression of the algorithm with the settings
+func New(algorithm string, settings Settings) (Compression, error) {
+	registration, registered := registrations[algorithm]
+	if !registered {
+		return nil, fmt.Errorf("unknown compression algorithm: %s", algorithm)
+	}
+	return registration.Factory(settings), nil
+}
+
+// Algorithms Names of all registered algorithms in alphabetical order
+func Algorithms() (algorithms []string) {
+	for algorithm := range registrations {
+		algorithms = append(algorithms, algorithm)
+	}
+	sort.Strings(algorithms)
+	return
+}
+
+// Detect Algorithm name of the compressed stream by its first bytes, empty if it is not known
+func Detect(header []byte) string {
+	for algorithm, registration := range registrations {
+		if bytes.HasPrefix(header, registration.Magic) {
+			return algorithm
+		}
+	}
+	return ""
+}
@@ -0,0 +1,58 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package compression
+
+import (
+	"io"
+
+	"github.com/ulikunitz/xz"
+	"github.com/ulikunitz/xz/lzma"
+)
+
+// Dictionary sizes of xz CLI presets from 0 to 9
+var xzDictionarySizes = []int{256 << 10, 1 << 20, 2 << 20, 4 << 20, 4 << 20, 8 << 20, 8 << 20, 16 << 20, 32 << 20, 64 << 20}
+
+func init() {
+	Register(Registration{
+		Algorithm: "xz",
+		Magic:     []byte{0xfd, '7', 'z', 'X', 'Z', 0x00},
+		Factory: func(_ Settings) Compression {
+			return &XZCompression{}
+		},
+	})
+}
+
+// XZCompression XZ compression. Levels from 0 to 9 follow dictionary sizes of xz CLI presets,
+// preset 6 is the default one. Hash table match finder is used on every level,
+// since binary tree one of the library is too slow for streamed backups.
+type XZCompression struct{}
+
+func (x *XZCompression) Compress(out io.Writer, level int) (compressed io.WriteCloser, err error) {
+	if level < 0 || level >= len(xzDictionarySizes) {
+		level = 6
+	}
+	config := xz.WriterConfig{DictCap: xzDictionarySizes[level], Matcher: lzma.HashTable4}
+	return config.NewWriter(out)
+}
+
+func (x *XZCompression) Decompress(in io.Reader) (plain io.ReadCloser, err error) {
+	var reader *xz.Reader
+	if reader, err = xz.NewReader(in); err != nil {
+		return
+	}
+	return io.NopCloser(reader), nil
+}
@@ -0,0 +1,71 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package compression
+
+import (
+	"io"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// Window size with long distance matching, the same as zstd --long uses by default
+const zstdLongWindowSize = 128 << 20
+
+func init() {
+	Register(Registration{
+		Algorithm: "zstd",
+		Magic:     []byte{0x28, 0xb5, 0x2f, 0xfd},
+		Factory: func(settings Settings) Compression {
+			return &ZstdCompression{
+				LongDistanceMatching: settings.LongDistanceMatching,
+				Concurrency:          settings.Concurrency,
+			}
+		},
+	})
+}
+
+// ZstdCompression Zstandard compression. Level follows zstd CLI levels from 1 to 22,
+// which are mapped to the closest encoder level, 0 means the default one. Negative levels like
+// zstd --fast ones are mapped to the fastest encoder level.
+type ZstdCompression struct {
+	// Find matches within the larger window, it costs memory on both compression and decompression
+	LongDistanceMatching bool
+	// Count of blocks compressed in parallel, 1 disables asynchronous compression
+	Concurrency int
+}
+
+func (z *ZstdCompression) Compress(out io.Writer, level int) (compressed io.WriteCloser, err error) {
+	options := []zstd.EOption{
+		zstd.WithEncoderConcurrency(max(z.Concurrency, 1)),
+	}
+	if level != 0 && level != DefaultLevel {
+		options = append(options, zstd.WithEncoderLevel(zstd.EncoderLevelFromZstd(level)))
+	}
+	if z.LongDistanceMatching {
+		options = append(options, zstd.WithWindowSize(zstdLongWindowSize))
+	}
+	return zstd.NewWriter(out, options...)
+}
+
+// Decompress Streams with windows up to 512MiB are accepted, so long distance matching needs nothing special
+func (z *ZstdCompression) Decompress(in io.Reader) (plain io.ReadCloser, err error) {
+	var decoder *zstd.Decoder
+	if decoder, err = zstd.NewReader(in); err != nil {
+		return
+	}
+	return decoder.IOReadCloser(), nil
+}
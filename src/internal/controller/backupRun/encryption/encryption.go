@@ -17,11 +17,18 @@ limitations under the License.
 package encryption
 
 import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"errors"
 	"fmt"
 	"io"
+	"strings"
 
 	"backup-operator.io/internal/controller/backupRun/wrappers"
 	"filippo.io/age"
+	"filippo.io/age/agessh"
+	"golang.org/x/crypto/ssh"
 )
 
 type Encryption interface {
@@ -29,14 +36,33 @@ type Encryption interface {
 	Decrypt(in io.Reader, keys ...string) (plain io.ReadCloser, err error)
 }
 
-type AgeEncryption struct{}
+// AgeEncryption Age encryption with X25519 keys or ssh-ed25519 and ssh-rsa SSH keys
+type AgeEncryption struct {
+	// Passphrase of SSH private keys, if they are protected
+	Passphrase string
+}
+
+// Every age file starts with the version line
+const ageHeader = "age-encryption.org/"
+
+// HeaderLength Count of the first bytes of the stream enough to detect encryption
+const HeaderLength = len(ageHeader)
+
+// IsEncrypted Check the stream is encrypted with age by its first bytes
+func IsEncrypted(header []byte) bool {
+	return bytes.HasPrefix(header, []byte(ageHeader))
+}
 
 func (a *AgeEncryption) Encrypt(out io.Writer, keys ...string) (encrypted io.WriteCloser, err error) {
 	var recipients []age.Recipient
 	// Parse recipients
 	for _, key := range keys {
 		var recipient age.Recipient
-		if recipient, err = age.ParseX25519Recipient(key); err != nil {
+		if strings.HasPrefix(key, "ssh-") {
+			if recipient, err = agessh.ParseRecipient(key); err != nil {
+				return nil, fmt.Errorf("failed to parse SSH recipient: %s", key)
+			}
+		} else if recipient, err = age.ParseX25519Recipient(key); err != nil {
 			return nil, fmt.Errorf("failed to parse X25519Recipient: %s", key)
 		}
 		recipients = append(recipients, recipient)
@@ -53,7 +79,12 @@ func (a *AgeEncryption) Decrypt(in io.Reader, keys ...string) (plain io.ReadClos
 	// Parse identities
 	for _, key := range keys {
 		var identity age.Identity
-		if identity, err = age.ParseX25519Identity(key); err != nil {
+		if strings.HasPrefix(key, "-----BEGIN") {
+			// Private key is not printed
+			if identity, err = a.parseSSHIdentity([]byte(key)); err != nil {
+				return nil, fmt.Errorf("failed to parse SSH identity: %s", err.Error())
+			}
+		} else if identity, err = age.ParseX25519Identity(key); err != nil {
 			return nil, fmt.Errorf("failed to parse X25519Recipient: %s", key)
 		}
 		identities = append(identities, identity)
@@ -65,3 +96,31 @@ func (a *AgeEncryption) Decrypt(in io.Reader, keys ...string) (plain io.ReadClos
 	}
 	return &wrappers.ReaderWrapper{Reader: ar}, err
 }
+
+// parseSSHIdentity Parse SSH private key in PEM format, protected one is decrypted with the passphrase
+func (a *AgeEncryption) parseSSHIdentity(pemBytes []byte) (identity age.Identity, err error) {
+	if a.Passphrase == "" {
+		if identity, err = agessh.ParseIdentity(pemBytes); err != nil {
+			var missing *ssh.PassphraseMissingError
+			if errors.As(err, &missing) {
+				return nil, errors.New("private key is protected with a passphrase, but no passphrase is set")
+			}
+		}
+		return
+	}
+	// Secrets created from files usually end with a newline
+	passphrase := strings.TrimRight(a.Passphrase, "\r\n")
+	var key any
+	if key, err = ssh.ParseRawPrivateKeyWithPassphrase(pemBytes, []byte(passphrase)); err != nil {
+		return
+	}
+	switch key := key.(type) {
+	case *ed25519.PrivateKey:
+		return agessh.NewEd25519Identity(*key)
+	case ed25519.PrivateKey:
+		return agessh.NewEd25519Identity(key)
+	case *rsa.PrivateKey:
+		return agessh.NewRSAIdentity(key)
+	}
+	return nil, fmt.Errorf("unsupported SSH private key type: %T", key)
+}
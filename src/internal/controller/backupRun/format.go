@@ -0,0 +1,86 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package backuprun
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+
+	backupoperatoriov1 "backup-operator.io/api/v1"
+	"backup-operator.io/internal/controller/backupRun/compression"
+	"backup-operator.io/internal/controller/backupRun/encryption"
+)
+
+// ErrFormatMismatch is returned by Restore if the backup file is not encrypted or compressed the way the run is
+var ErrFormatMismatch = errors.New("format mismatch")
+
+// peekHeader Read the first bytes of the stream without consuming them, a shorter file is not an error
+func peekHeader(reader *bufio.Reader, length int) (header []byte, err error) {
+	if header, err = reader.Peek(length); err == io.EOF {
+		err = nil
+	}
+	return
+}
+
+// checkEncryption Check the stream is encrypted with age if and only if the run has encryption
+func checkEncryption(reader *bufio.Reader, encrypted bool) (err error) {
+	var header []byte
+	if header, err = peekHeader(reader, encryption.HeaderLength); err != nil {
+		return fmt.Errorf("failed to read backup file header: %s", err.Error())
+	}
+	switch detected := encryption.IsEncrypted(header); {
+	case encrypted && !detected:
+		return fmt.Errorf("%w: backup file is not encrypted with age, but the run has encryption", ErrFormatMismatch)
+	case !encrypted && detected:
+		return fmt.Errorf("%w: backup file is encrypted with age, but the run has no encryption", ErrFormatMismatch)
+	}
+	return nil
+}
+
+// getDecompressor Choose decompression of the stream by its first bytes, nil if it is passed as is.
+// Compression of the run must match the stream. Format of imported files is only guessed on import,
+// so the detected algorithm is used for imported runs, even if the run has no compression. Compressed
+// stream of other runs without compression is passed as is, since the restore command may decompress it itself.
+func getDecompressor(reader *bufio.Reader, run *backupoperatoriov1.BackupRun, imported bool) (c compression.Compression, err error) {
+	if run.Spec.Compression == nil && !imported {
+		return nil, nil
+	}
+	var header []byte
+	if header, err = peekHeader(reader, compression.MagicLength); err != nil {
+		return nil, fmt.Errorf("failed to read backup file header: %s", err.Error())
+	}
+	detected := compression.Detect(header)
+	switch {
+	case imported && len(detected) > 0:
+		return compression.New(detected, compression.Settings{})
+	case run.Spec.Compression == nil:
+		return nil, nil
+	}
+	expected := string(run.Spec.Compression.Algorithm)
+	switch detected {
+	case expected:
+		return compression.New(detected, compression.Settings{})
+	case "":
+		return nil, fmt.Errorf("%w: backup file is not compressed with any known algorithm, but %s is expected",
+			ErrFormatMismatch, expected)
+	default:
+		return nil, fmt.Errorf("%w: backup file is compressed with %s, but %s is expected",
+			ErrFormatMismatch, detected, expected)
+	}
+}
@@ -0,0 +1,112 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package backuprun
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"testing"
+
+	backupoperatoriov1 "backup-operator.io/api/v1"
+	"backup-operator.io/internal/controller/backupRun/compression"
+)
+
+// compressed Compress data with the algorithm, data is returned as is if it is empty
+func compressed(t *testing.T, algorithm string, data []byte) []byte {
+	t.Helper()
+	if len(algorithm) == 0 {
+		return data
+	}
+	c, err := compression.New(algorithm, compression.Settings{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buffer bytes.Buffer
+	writer, err := c.Compress(&buffer, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	writer.Write(data)
+	writer.Close()
+	return buffer.Bytes()
+}
+
+func TestGetDecompressor(t *testing.T) {
+	data := []byte("backup data")
+	for _, tc := range []struct {
+		name string
+		// Algorithm in the run spec, none if empty
+		spec string
+		// Algorithm of the stream, plain data if empty
+		stream   string
+		imported bool
+		// Algorithm chosen to decompress the stream, it is passed as is if empty
+		expected string
+		mismatch bool
+	}{
+		{name: "matching", spec: "zstd", stream: "zstd", expected: "zstd"},
+		{name: "mismatching", spec: "gzip", stream: "zstd", mismatch: true},
+		{name: "not compressed", spec: "gzip", mismatch: true},
+		{name: "compressed without spec", stream: "gzip"},
+		{name: "plain without spec"},
+		{name: "imported with another algorithm", spec: "gzip", stream: "xz", imported: true, expected: "xz"},
+		{name: "imported without spec", stream: "lz4", imported: true, expected: "lz4"},
+		{name: "imported plain", imported: true},
+		{name: "imported not compressed", spec: "gzip", imported: true, mismatch: true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			run := &backupoperatoriov1.BackupRun{}
+			if len(tc.spec) > 0 {
+				spec := fmt.Sprintf(`{"compression": {"algorithm": %q}}`, tc.spec)
+				if err := json.Unmarshal([]byte(spec), &run.Spec); err != nil {
+					t.Fatal(err)
+				}
+			}
+			stream := compressed(t, tc.stream, data)
+			reader := bufio.NewReader(bytes.NewReader(stream))
+			decompressor, err := getDecompressor(reader, run, tc.imported)
+			if tc.mismatch {
+				if !errors.Is(err, ErrFormatMismatch) {
+					t.Fatalf("got error %v instead of mismatch", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			expected, _ := compression.New(tc.expected, compression.Settings{})
+			if fmt.Sprintf("%T", decompressor) != fmt.Sprintf("%T", expected) {
+				t.Fatalf("got decompressor %T, expected %T", decompressor, expected)
+			}
+			// Header must not be consumed by the detection
+			var plain io.Reader = reader
+			if decompressor != nil {
+				if plain, err = decompressor.Decompress(reader); err != nil {
+					t.Fatal(err)
+				}
+				stream = data
+			}
+			if result, err := io.ReadAll(plain); err != nil || !bytes.Equal(result, stream) {
+				t.Errorf("got %q, %v", result, err)
+			}
+		})
+	}
+}
@@ -17,8 +17,6 @@ limitations under the License.
 package backuprun
 
 import (
-	"fmt"
-
 	backupoperatoriov1 "backup-operator.io/api/v1"
 	"backup-operator.io/internal/controller/backupRun/compression"
 	"backup-operator.io/internal/controller/backupRun/encryption"
@@ -34,13 +32,23 @@ func getEncryptorAndCompressor(run *backupoperatoriov1.BackupRun) (
 		e = &encryption.AgeEncryption{}
 	}
 	if state.Compressed {
-		switch run.Spec.Compression.Algorithm {
-		// ...and compressor depending on the algorithm
-		case backupoperatoriov1.GZIP:
-			c = &compression.GZIPCompression{}
-		default:
-			err = fmt.Errorf("unknown compression algorithm: %s", run.Spec.Compression.Algorithm)
+		// ...and compressor of the algorithm
+		settings := compression.Settings{
+			Concurrency:          int(run.Spec.Compression.Concurrency),
+			LongDistanceMatching: run.Spec.Compression.LongDistanceMatching,
+		}
+		if run.Spec.Compression.BlockSize != nil {
+			settings.BlockSize = int(run.Spec.Compression.BlockSize.Value())
 		}
+		c, err = compression.New(string(run.Spec.Compression.Algorithm), settings)
 	}
 	return
 }
+
+// compressionLevel Level of the run compression, compressors pick their own default if it is not set
+func compressionLevel(run *backupoperatoriov1.BackupRun) int {
+	if run.Spec.Compression.Level == nil {
+		return compression.DefaultLevel
+	}
+	return int(*run.Spec.Compression.Level)
+}
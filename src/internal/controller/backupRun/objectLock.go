@@ -0,0 +1,81 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package backuprun
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	backupoperatoriov1 "backup-operator.io/api/v1"
+	backupstorage "backup-operator.io/internal/controller/backupStorage"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// SyncLegalHold puts or releases legal hold on the backup file according to the run annotation.
+// Applied state is kept in LegalHold condition, so the storage is called on changes only.
+func SyncLegalHold(ctx context.Context, c client.Client,
+	run *backupoperatoriov1.BackupRun, storage backupstorage.BackupStorageProvider,
+) (changed bool, err error) {
+	_, hold := run.GetAnnotations()[backupoperatoriov1.AnnotationLegalHold]
+	held := false
+	for _, condition := range run.Status.Conditions {
+		if condition.Type == string(backupoperatoriov1.BackupRunConditionTypeLegalHold) {
+			held = condition.Status == metav1.ConditionTrue
+		}
+	}
+	if hold == held {
+		return
+	}
+	locker, ok := backupstorage.As[backupstorage.ObjectLocker](storage)
+	if !ok {
+		return false, fmt.Errorf("storage %s does not support Object Lock legal hold", run.Spec.Storage.Name)
+	}
+	if err = locker.SetLegalHold(ctx, run.Spec.Storage.Path, hold); err != nil {
+		return
+	}
+	status, reason, message := metav1.ConditionFalse, "Released", "Legal hold has been released"
+	if hold {
+		status, reason, message = metav1.ConditionTrue, "Held", "Legal hold has been put"
+	}
+	return true, SetRunCondition(ctx, c, run, backupoperatoriov1.BackupRunConditionTypeLegalHold, status, reason, message)
+}
+
+// DescribeObjectLock explains why the storage refuses to delete the backup file and when it is worth to retry.
+// Zero retry means there is no known time, e.g. legal hold must be released first.
+func DescribeObjectLock(ctx context.Context, run *backupoperatoriov1.BackupRun,
+	storage backupstorage.BackupStorageProvider,
+) (message string, retryAfter time.Duration) {
+	message = "storage refuses to delete the backup because of Object Lock"
+	locker, ok := backupstorage.As[backupstorage.ObjectLocker](storage)
+	if !ok {
+		return
+	}
+	lock, err := locker.GetObjectLock(ctx, run.Spec.Storage.Path)
+	if err != nil {
+		return
+	}
+	switch {
+	case lock.LegalHold:
+		message = fmt.Sprintf("backup is under legal hold, remove %s annotation to release it", backupoperatoriov1.AnnotationLegalHold)
+	case lock.Retention != nil && lock.Retention.RetainUntil.After(time.Now()):
+		message = fmt.Sprintf("backup is retained in %s mode until %s", lock.Retention.Mode, lock.Retention.RetainUntil.Format(time.RFC3339))
+		retryAfter = time.Until(lock.Retention.RetainUntil)
+	}
+	return
+}
@@ -0,0 +1,72 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package backuprun
+
+import (
+	"context"
+	"runtime"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	backupoperatoriov1 "backup-operator.io/api/v1"
+	"backup-operator.io/internal/controller/backupRun/compression"
+)
+
+// Backups of schedules are compressed with gzip in parallel if the previous one has been larger
+const parallelGzipThreshold = 1 << 30
+
+// setDefaultConcurrency Compress large backups with gzip in parallel with a block per CPU unless concurrency is set.
+// Size of the backup is not known in advance, so the previous backup of the same schedule is looked at.
+func setDefaultConcurrency(ctx context.Context, c client.Client,
+	run *backupoperatoriov1.BackupRun, compressor compression.Compression,
+) {
+	gzipCompression, ok := compressor.(*compression.GZIPCompression)
+	if !ok || gzipCompression.Concurrency != 0 || getPreviousBackupSize(ctx, c, run) <= parallelGzipThreshold {
+		return
+	}
+	gzipCompression.Concurrency = runtime.GOMAXPROCS(0)
+}
+
+// getPreviousBackupSize Get size of the latest successful backup of the schedule that has created the run, 0 if unknown
+func getPreviousBackupSize(ctx context.Context, c client.Client, run *backupoperatoriov1.BackupRun) uint {
+	owner := metav1.GetControllerOf(run)
+	if owner == nil || owner.Kind != "BackupSchedule" {
+		return 0
+	}
+	runs := &backupoperatoriov1.BackupRunList{}
+	if err := c.List(ctx, runs, client.InNamespace(run.Namespace),
+		client.MatchingFields{".metadata.controller": string(owner.UID)}); err != nil {
+		return 0
+	}
+	var latest *backupoperatoriov1.BackupRun
+	for i := range runs.Items {
+		sibling := &runs.Items[i]
+		if sibling.UID == run.UID || sibling.Status.SizeInBytes == nil ||
+			!meta.IsStatusConditionTrue(sibling.Status.Conditions, string(backupoperatoriov1.BackupRunConditionTypeSuccessful)) {
+			continue
+		}
+		if latest == nil || latest.CreationTimestamp.Before(&sibling.CreationTimestamp) {
+			latest = sibling
+		}
+	}
+	if latest == nil {
+		return 0
+	}
+	return *latest.Status.SizeInBytes
+}
@@ -0,0 +1,53 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package backuprun
+
+import (
+	"context"
+	"fmt"
+
+	backupoperatoriov1 "backup-operator.io/api/v1"
+	backupstorage "backup-operator.io/internal/controller/backupStorage"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// Rehydrate makes sure the backup file is available for download before the restoration.
+// Archived file is requested to be restored, and Rehydrating condition is set until it is available.
+func Rehydrate(ctx context.Context, c client.Client,
+	run *backupoperatoriov1.BackupRun, storage backupstorage.BackupStorageProvider,
+) (pending bool, err error) {
+	rehydrator, ok := backupstorage.As[backupstorage.Rehydrator](storage)
+	if !ok {
+		return
+	}
+	if pending, err = rehydrator.Rehydrate(ctx, run.Spec.Storage.Path); err != nil {
+		return false, fmt.Errorf("failed to rehydrate the backup: %s", err)
+	}
+	if pending {
+		return true, SetRunCondition(ctx, c, run, backupoperatoriov1.BackupRunConditionTypeRehydrating,
+			metav1.ConditionTrue, "Rehydrating", "backup is archived and is being restored to become available for download")
+	}
+	// Clean the condition after the rehydration only, non-archived backups never have it
+	for _, condition := range run.Status.Conditions {
+		if condition.Type == string(backupoperatoriov1.BackupRunConditionTypeRehydrating) && condition.Status == metav1.ConditionTrue {
+			return false, SetRunCondition(ctx, c, run, backupoperatoriov1.BackupRunConditionTypeRehydrating,
+				metav1.ConditionFalse, "Rehydrated", "backup is available for download")
+		}
+	}
+	return
+}
@@ -17,6 +17,7 @@ limitations under the License.
 package backuprun
 
 import (
+	"bufio"
 	"context"
 	"errors"
 	"fmt"
@@ -42,6 +43,8 @@ func Restore(ctx context.Context, c client.Client, _ *runtime.Scheme,
 	config *rest.Config, run *backupoperatoriov1.BackupRun,
 	pod *corev1.Pod, storage backupstorage.BackupStorageProvider,
 ) (err error) {
+	// Provider must not be destroyed between operations if the storage is reconfigured meanwhile
+	defer backupstorage.Hold(storage)()
 	state := AnalyzeRunConditions(run)
 	action := run.Spec.Restore
 
@@ -51,15 +54,34 @@ func Restore(ctx context.Context, c client.Client, _ *runtime.Scheme,
 		return
 	}
 	// Create a reader from storage
-	var backupReader io.ReadCloser
+	var storageReader io.ReadCloser
+	expectedChecksum := getStoredChecksum(ctx, run, storage)
 	// Open reader to storage
-	if backupReader, err = storage.Get(ctx, run.Spec.Storage.Path); err != nil {
+	if storageReader, err = storage.Get(ctx, run.Spec.Storage.Path); err != nil {
 		err = fmt.Errorf("failed to open reader to storage backup: %s", err.Error())
 		return
 	}
-	defer backupReader.Close()
+	defer storageReader.Close()
+	// Checksum is calculated while the backup is streamed
+	checksum := newChecksumReader(storageReader)
+	// Format of the file is checked before anything is sent to the Pod
+	mismatch := func(err error) error {
+		// Corrupted file is the reason of the mismatch if it does not match its checksum too.
+		// The rest of the backup is not downloaded for it, so only small files already read to the end are verified.
+		if len(expectedChecksum) > 0 {
+			if verifyErr := checksum.verify(expectedChecksum, false); verifyErr != nil {
+				return verifyErr
+			}
+		}
+		return err
+	}
+	storageBuffer := bufio.NewReader(checksum)
+	if err = checkEncryption(storageBuffer, state.Encrypted); err != nil {
+		err = mismatch(err)
+		return
+	}
 	// Get decryption key
-	var decryptionKey string
+	var decryptionKey, passphrase string
 	if state.Encrypted {
 		secret := &corev1.Secret{}
 		secret.Name = run.Spec.Encryption.DecryptionKey.Name
@@ -74,16 +96,22 @@ func Restore(ctx context.Context, c client.Client, _ *runtime.Scheme,
 				secret.Namespace, secret.Name, err.Error())
 			return
 		}
+		data := utils.DecodeSecretData(secret)
 		var ok bool
-		if decryptionKey, ok = utils.DecodeSecretData(secret)[run.Spec.Encryption.DecryptionKey.Key]; !ok {
+		if decryptionKey, ok = data[run.Spec.Encryption.DecryptionKey.Key]; !ok {
 			// ...or it does not have requested key
 			err = fmt.Errorf("secret %s/%s does not have key %s",
 				secret.Namespace, secret.Name, run.Spec.Encryption.DecryptionKey.Key)
 			return
 		}
+		// Passphrase of the protected SSH private key is kept in the same secret
+		if key := run.Spec.Encryption.PassphraseKey; key != "" {
+			if passphrase, ok = data[key]; !ok {
+				err = fmt.Errorf("secret %s/%s does not have key %s", secret.Namespace, secret.Name, key)
+				return
+			}
+		}
 	}
-	// Future stdin stream
-	var stdin io.ReadCloser
 	// This will be passed to pod exec
 	exec := &podExecParameters{
 		Stdin:              nil, // Stdin will be set below
@@ -95,39 +123,34 @@ func Restore(ctx context.Context, c client.Client, _ *runtime.Scheme,
 	if action.DeadlineSeconds != nil {
 		exec.Timeout = ptr.To[time.Duration](time.Second * time.Duration(*action.DeadlineSeconds))
 	}
-	// Create compressor and encryptor
-	var compressor compression.Compression
+	// Create encryptor, decompression is chosen by the stream itself
 	var encryptor encryption.Encryption
-	if encryptor, compressor, err = getEncryptorAndCompressor(run); err != nil {
+	if encryptor, _, err = getEncryptorAndCompressor(run); err != nil {
 		return
 	}
-	// We have 4 possible schemes
-	switch {
-	case !state.Encrypted && !state.Compressed:
-		// storage -> exec
-		stdin = backupReader
-		// Will be closed by default
-	case !state.Encrypted && state.Compressed:
-		// storage -> decompression -> exec
-		if stdin, err = compressor.Decompress(backupReader); err != nil {
-			err = fmt.Errorf("failed to create compressor reader: %s", err.Error())
-			return
-		}
-	case state.Encrypted && !state.Compressed:
-		// storage -> decryption -> exec
-		if stdin, err = encryptor.Decrypt(backupReader, decryptionKey); err != nil {
-			err = fmt.Errorf("failed to create encryptor reader: %s", err.Error())
-			return
-		}
-	case state.Encrypted && state.Compressed:
-		// storage -> decryption -> decompression -> exec
+	if ageEncryption, ok := encryptor.(*encryption.AgeEncryption); ok {
+		ageEncryption.Passphrase = passphrase
+	}
+	// storage -> decryption -> decompression -> exec, decryption and decompression are optional
+	compressionBuffer := storageBuffer
+	if state.Encrypted {
 		var compressionReader io.ReadCloser
-		if compressionReader, err = encryptor.Decrypt(backupReader, decryptionKey); err != nil {
+		if compressionReader, err = encryptor.Decrypt(storageBuffer, decryptionKey); err != nil {
 			err = fmt.Errorf("failed to create encryptor reader: %s", err.Error())
 			return
 		}
 		defer compressionReader.Close()
-		if stdin, err = compressor.Decompress(compressionReader); err != nil {
+		compressionBuffer = bufio.NewReader(compressionReader)
+	}
+	var decompressor compression.Compression
+	if decompressor, err = getDecompressor(compressionBuffer, run, state.Imported); err != nil {
+		err = mismatch(err)
+		return
+	}
+	// Future stdin stream
+	stdin := io.NopCloser(compressionBuffer)
+	if decompressor != nil {
+		if stdin, err = decompressor.Decompress(compressionBuffer); err != nil {
 			err = fmt.Errorf("failed to create compressor reader: %s", err.Error())
 			return
 		}
@@ -136,5 +159,11 @@ func Restore(ctx context.Context, c client.Client, _ *runtime.Scheme,
 	// Make Pod exec
 	exec.Stdin = stdin
 	err = podExec(ctx, config, pod, exec)
+	// Corrupted file is the reason of the failure if it has been read to the end anyway
+	if len(expectedChecksum) > 0 {
+		if verifyErr := checksum.verify(expectedChecksum, err == nil); verifyErr != nil {
+			err = verifyErr
+		}
+	}
 	return
 }
@@ -28,7 +28,7 @@ import (
 	"backup-operator.io/internal/controller/utils"
 )
 
-// SetBackupSizeInStatus updates backup size status field
+// SetBackupSizeInStatus updates backup size status field and checksums if they are unknown yet
 func SetBackupSizeInStatus(ctx context.Context, c client.Client,
 	run *backupoperatoriov1.BackupRun, storage backupstorage.BackupStorageProvider,
 ) (err error) {
@@ -36,12 +36,20 @@ func SetBackupSizeInStatus(ctx context.Context, c client.Client,
 		if err = c.Get(ctx, client.ObjectKeyFromObject(run), run); err != nil {
 			return err
 		}
-		var bytes uint
-		if bytes, err = storage.GetSize(ctx, run.Spec.Storage.Path); err != nil {
+		var info backupstorage.ObjectInfo
+		if info, err = storage.Stat(ctx, run.Spec.Storage.Path); err != nil {
 			return
 		}
-		run.Status.SizeInBytes = ptr.To(bytes)
-		run.Status.Size = ptr.To(utils.ConvertBytesToHumanReadable(bytes))
+		run.Status.SizeInBytes = ptr.To(info.Size)
+		run.Status.Size = ptr.To(utils.ConvertBytesToHumanReadable(info.Size))
+		// Imported backups get checksums from the file metadata
+		if stored := info.Metadata[backupstorage.MetadataSHA256]; run.Status.Checksums == nil && isChecksum(stored) {
+			raw := info.Metadata[backupstorage.MetadataRawSHA256]
+			if !isChecksum(raw) {
+				raw = ""
+			}
+			run.Status.SetChecksums(raw, stored)
+		}
 		return c.Status().Update(ctx, run)
 	})
 }
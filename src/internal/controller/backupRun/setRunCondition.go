@@ -0,0 +1,74 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package backuprun
+
+import (
+	"context"
+
+	"k8s.io/client-go/util/retry"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	backupoperatoriov1 "backup-operator.io/api/v1"
+	"backup-operator.io/internal/controller/utils"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// SetRunCondition adds or updates a single run condition, transition time is kept if status is the same
+func SetRunCondition(ctx context.Context, c client.Client,
+	run *backupoperatoriov1.BackupRun, ct backupoperatoriov1.BackupRunConditionType,
+	status metav1.ConditionStatus, reason, message string,
+) (err error) {
+	return retry.RetryOnConflict(retry.DefaultRetry, func() (err error) {
+		if err = c.Get(ctx, client.ObjectKeyFromObject(run), run); err != nil {
+			return err
+		}
+		condition := metav1.Condition{
+			Type:               string(ct),
+			Status:             status,
+			Reason:             reason,
+			Message:            message,
+			LastTransitionTime: metav1.Now(),
+			ObservedGeneration: run.Generation,
+		}
+		if old, err := utils.GetConditionByType(&run.Status.Conditions, string(ct)); err == nil {
+			if old.Status == status && old.Reason == reason && old.Message == message {
+				return nil
+			}
+			if old.Status == status {
+				condition.LastTransitionTime = old.LastTransitionTime
+			}
+		}
+		run.Status.Conditions = *utils.AddOrUpdateConditions(run.Status.Conditions, condition)
+		return c.Status().Update(ctx, run)
+	})
+}
+
+// RemoveRunCondition removes a single run condition if it exists
+func RemoveRunCondition(ctx context.Context, c client.Client,
+	run *backupoperatoriov1.BackupRun, ct backupoperatoriov1.BackupRunConditionType,
+) (err error) {
+	return retry.RetryOnConflict(retry.DefaultRetry, func() (err error) {
+		if err = c.Get(ctx, client.ObjectKeyFromObject(run), run); err != nil {
+			return err
+		}
+		if _, err = utils.GetConditionByType(&run.Status.Conditions, string(ct)); err != nil {
+			return nil
+		}
+		run.Status.Conditions = *utils.RemoveCondition(run.Status.Conditions, metav1.Condition{Type: string(ct)})
+		return c.Status().Update(ctx, run)
+	})
+}
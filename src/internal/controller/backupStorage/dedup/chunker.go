@@ -0,0 +1,113 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package dedup
+
+import (
+	"io"
+)
+
+// Gear hash table. It is derived from a constant seed, since changing it would change
+// chunk boundaries and break deduplication with the chunks stored before.
+var gear = func() (table [256]uint64) {
+	// splitmix64
+	state := uint64(0x6261636b75702d6f)
+	for i := range table {
+		state += 0x9e3779b97f4a7c15
+		z := state
+		z = (z ^ (z >> 30)) * 0xbf58476d1ce4e5b9
+		z = (z ^ (z >> 27)) * 0x94d049bb133111eb
+		table[i] = z ^ (z >> 31)
+	}
+	return
+}()
+
+// chunker Splits stream into content-defined chunks with gear rolling hash.
+// Boundaries depend on the content only, so data inserted in the middle changes a few chunks around it.
+type chunker struct {
+	reader io.Reader
+	// Pending data is buffer[start:end]
+	buffer     []byte
+	start, end int
+	eof        bool
+	// Chunk size limits and the mask cutting chunks on average
+	min, max int
+	mask     uint64
+}
+
+// newChunker Create chunker with the average chunk size, it is rounded down to a power of two
+func newChunker(reader io.Reader, average int) *chunker {
+	bits := 0
+	for 1<<(bits+1) <= average {
+		bits++
+	}
+	return &chunker{
+		reader: reader,
+		buffer: make([]byte, 4<<bits),
+		min:    1 << bits / 4,
+		max:    4 << bits,
+		mask:   1<<bits - 1,
+	}
+}
+
+// Next Get the next chunk, io.EOF is returned after the last one. Chunk is a new slice every time.
+func (c *chunker) Next() ([]byte, error) {
+	if c.end-c.start < c.max && !c.eof {
+		if err := c.fill(); err != nil {
+			return nil, err
+		}
+	}
+	data := c.buffer[c.start:c.end]
+	if len(data) == 0 {
+		return nil, io.EOF
+	}
+	chunk := make([]byte, c.cut(data))
+	copy(chunk, data)
+	c.start += len(chunk)
+	return chunk, nil
+}
+
+// fill Move pending data to the beginning of the buffer and read until it is full
+func (c *chunker) fill() error {
+	c.end = copy(c.buffer, c.buffer[c.start:c.end])
+	c.start = 0
+	for c.end < len(c.buffer) && !c.eof {
+		n, err := c.reader.Read(c.buffer[c.end:])
+		c.end += n
+		if err == io.EOF {
+			c.eof = true
+		} else if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// cut Find the chunk boundary in data
+func (c *chunker) cut(data []byte) int {
+	if len(data) <= c.min {
+		return len(data)
+	}
+	limit := min(len(data), c.max)
+	var hash uint64
+	for i := c.min; i < limit; i++ {
+		hash = hash<<1 + gear[data[i]]
+		if hash&c.mask == 0 {
+			return i + 1
+		}
+	}
+	return limit
+}
@@ -0,0 +1,122 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package dedup
+
+import (
+	"bytes"
+	"io"
+	"math/rand"
+	"testing"
+)
+
+// randomData Generate reproducible incompressible data
+func randomData(size int, seed int64) []byte {
+	data := make([]byte, size)
+	rand.New(rand.NewSource(seed)).Read(data)
+	return data
+}
+
+// split Split data into chunks
+func split(t *testing.T, data []byte, average int) (chunks [][]byte) {
+	t.Helper()
+	c := newChunker(bytes.NewReader(data), average)
+	for {
+		chunk, err := c.Next()
+		if err == io.EOF {
+			return
+		} else if err != nil {
+			t.Fatalf("failed to get chunk: %s", err)
+		}
+		chunks = append(chunks, chunk)
+	}
+}
+
+func TestChunkerReassemble(t *testing.T) {
+	for _, size := range []int{0, 1, 1000, 64 << 10, 1 << 20, 3<<20 + 17} {
+		data := randomData(size, int64(size))
+		chunks := split(t, data, 64<<10)
+		if joined := bytes.Join(chunks, nil); !bytes.Equal(joined, data) {
+			t.Errorf("%d bytes: chunks do not make the original data", size)
+		}
+		if size == 0 && len(chunks) != 0 {
+			t.Errorf("empty data: got %d chunks, expected none", len(chunks))
+		}
+	}
+}
+
+func TestChunkerSizeLimits(t *testing.T) {
+	const average = 64 << 10
+	c := newChunker(nil, average+1000)
+	if c.min != average/4 || c.max != average*4 || c.mask != average-1 {
+		t.Fatalf("average is not rounded down to a power of two: min %d, max %d, mask %x", c.min, c.max, c.mask)
+	}
+	data := randomData(8<<20, 1)
+	// Long runs of the same byte have no content-defined boundaries
+	data = append(data, make([]byte, 1<<20)...)
+	chunks := split(t, data, average)
+	total := 0
+	for i, chunk := range chunks {
+		total += len(chunk)
+		if len(chunk) > c.max || (len(chunk) < c.min && i != len(chunks)-1) {
+			t.Errorf("chunk %d has size %d out of [%d, %d]", i, len(chunk), c.min, c.max)
+		}
+	}
+	if mean := total / len(chunks); mean < average/2 || mean > average*2 {
+		t.Errorf("mean chunk size %d is too far from %d", mean, average)
+	}
+}
+
+func TestChunkerDeterministic(t *testing.T) {
+	data := randomData(2<<20, 2)
+	first, second := split(t, data, 64<<10), split(t, data, 64<<10)
+	if len(first) != len(second) {
+		t.Fatalf("got %d and %d chunks for the same data", len(first), len(second))
+	}
+	for i := range first {
+		if !bytes.Equal(first[i], second[i]) {
+			t.Fatalf("chunk %d differs for the same data", i)
+		}
+	}
+}
+
+func TestChunkerInsertion(t *testing.T) {
+	data := randomData(4<<20, 3)
+	// Insert a few bytes in the middle
+	changed := append(append(append([]byte{}, data[:2<<20]...), []byte("inserted")...), data[2<<20:]...)
+	original := map[string]bool{}
+	for _, chunk := range split(t, data, 64<<10) {
+		original[string(chunk)] = true
+	}
+	chunks := split(t, changed, 64<<10)
+	differ := 0
+	for _, chunk := range chunks {
+		if !original[string(chunk)] {
+			differ++
+		}
+	}
+	// Only chunks around the insertion change
+	if differ > 2 {
+		t.Errorf("%d of %d chunks have changed after insertion, expected at most 2", differ, len(chunks))
+	}
+}
+
+func TestChunkerReaderError(t *testing.T) {
+	c := newChunker(io.MultiReader(bytes.NewReader(randomData(1000, 4)), &failingReader{}), 64<<10)
+	if _, err := c.Next(); err != errFailingReader {
+		t.Errorf("got %v, expected reader error", err)
+	}
+}
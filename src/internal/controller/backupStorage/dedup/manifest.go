@@ -0,0 +1,218 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package dedup
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"strconv"
+	"strings"
+
+	backupstorage "backup-operator.io/internal/controller/backupStorage"
+)
+
+// Manifest is a text file with the header, random ID, total size and a line with hash and size for every chunk:
+//
+//	backup-operator.io/dedup-manifest/v1
+//	5d41402abc4b2a76b9719d911017c592
+//	1073741824
+//	9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08 4194304
+//	...
+const manifestHeader = "backup-operator.io/dedup-manifest/v1\n"
+
+// Chunks are kept by hash under this path, the first two hex digits make a directory
+var chunksPrefix = backupstorage.InternalPrefix + "chunks/"
+
+// Every manifest referencing a chunk has an empty file named by the manifest ID under this path of the chunk,
+// so the chunk is deleted once it has none. ID is kept when the manifest is moved.
+var refsPrefix = backupstorage.InternalPrefix + "refs/"
+
+// errNotManifest is returned for files uploaded without deduplication, they are served as is
+var errNotManifest = errors.New("file is not a deduplication manifest")
+
+// chunkRef Reference to the chunk in the manifest
+type chunkRef struct {
+	Hash string
+	Size int
+}
+
+// chunkPath Get path of the chunk by its hash
+func chunkPath(hash string) string {
+	return chunksPrefix + hash[:2] + "/" + hash
+}
+
+// refsPath Get path of the references to the chunk
+func refsPath(hash string) string {
+	return refsPrefix + hash[:2] + "/" + hash + "/"
+}
+
+// refPath Get path of the reference to the chunk from the manifest
+func refPath(hash, id string) string {
+	return refsPath(hash) + id
+}
+
+// newManifestID Generate random manifest ID
+func newManifestID() (string, error) {
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(id), nil
+}
+
+// encodeManifest Write manifest of the chunks
+func encodeManifest(id string, size uint64, chunks []chunkRef) *bytes.Buffer {
+	buffer := bytes.NewBufferString(manifestHeader)
+	fmt.Fprintf(buffer, "%s\n%d\n", id, size)
+	for _, chunk := range chunks {
+		fmt.Fprintf(buffer, "%s %d\n", chunk.Hash, chunk.Size)
+	}
+	return buffer
+}
+
+// manifestReader Reads manifest line by line, so large manifests are not loaded into memory
+type manifestReader struct {
+	lines  *bufio.Reader
+	closer io.Closer
+	// ID the manifest references chunks with
+	ID string
+	// Total size of the deduplicated file
+	Size uint64
+}
+
+// openManifest Open manifest and read its header. The reader is returned along with errNotManifest,
+// so the caller may serve the file as is.
+func openManifest(ctx context.Context, storage backupstorage.BackupStorageProvider, path string) (*manifestReader, error) {
+	file, err := storage.Get(ctx, path)
+	if err != nil {
+		return nil, err
+	}
+	manifest := &manifestReader{lines: bufio.NewReader(file), closer: file}
+	if header, err := manifest.lines.Peek(len(manifestHeader)); err != nil || string(header) != manifestHeader {
+		return manifest, errNotManifest
+	}
+	manifest.lines.Discard(len(manifestHeader))
+	if manifest.ID, err = manifest.readLine(); err == nil && !validManifestID(manifest.ID) {
+		err = fmt.Errorf("malformed ID %q", manifest.ID)
+	}
+	if err != nil {
+		manifest.Close()
+		return nil, fmt.Errorf("failed to read manifest ID: %s", err)
+	}
+	var line string
+	if line, err = manifest.readLine(); err == nil {
+		manifest.Size, err = strconv.ParseUint(line, 10, 64)
+	}
+	if err != nil {
+		manifest.Close()
+		return nil, fmt.Errorf("failed to read manifest size: %s", err)
+	}
+	return manifest, nil
+}
+
+// validManifestID Check the ID is a hex string, since it is a part of reference paths
+func validManifestID(id string) bool {
+	if _, err := hex.DecodeString(id); err != nil || len(id) == 0 {
+		return false
+	}
+	return true
+}
+
+// Next Read the next chunk reference, io.EOF is returned after the last one
+func (m *manifestReader) Next() (chunk chunkRef, err error) {
+	var line string
+	if line, err = m.readLine(); err != nil {
+		return
+	}
+	fields := strings.Fields(line)
+	if len(fields) != 2 || len(fields[0]) != sha256.Size*2 {
+		return chunk, fmt.Errorf("malformed manifest line: %q", line)
+	}
+	chunk.Hash = fields[0]
+	chunk.Size, err = strconv.Atoi(fields[1])
+	return
+}
+
+// Read Serve the file as is, it is used for files that are not manifests
+func (m *manifestReader) Read(p []byte) (int, error) {
+	return m.lines.Read(p)
+}
+
+func (m *manifestReader) Close() error {
+	return m.closer.Close()
+}
+
+func (m *manifestReader) readLine() (string, error) {
+	line, err := m.lines.ReadString('\n')
+	if err == io.EOF && len(line) > 0 {
+		return "", io.ErrUnexpectedEOF
+	}
+	return strings.TrimSuffix(line, "\n"), err
+}
+
+// assembler Reassembles the file from chunks listed in the manifest, verifying every chunk hash
+type assembler struct {
+	ctx      context.Context
+	storage  backupstorage.BackupStorageProvider
+	manifest *manifestReader
+	// Chunk being read
+	chunk  chunkRef
+	reader io.ReadCloser
+	hasher hash.Hash
+}
+
+func (a *assembler) Read(p []byte) (n int, err error) {
+	for {
+		if a.reader == nil {
+			if a.chunk, err = a.manifest.Next(); err != nil {
+				return
+			}
+			if a.reader, err = a.storage.Get(a.ctx, chunkPath(a.chunk.Hash)); err != nil {
+				return 0, fmt.Errorf("failed to get chunk %s: %s", a.chunk.Hash, err)
+			}
+			a.hasher.Reset()
+		}
+		n, err = a.reader.Read(p)
+		a.hasher.Write(p[:n])
+		if err != io.EOF {
+			return
+		}
+		a.reader.Close()
+		a.reader = nil
+		if sum := hex.EncodeToString(a.hasher.Sum(nil)); sum != a.chunk.Hash {
+			return n, fmt.Errorf("chunk %s is corrupted, its content hash is %s", a.chunk.Hash, sum)
+		}
+		if n > 0 {
+			return n, nil
+		}
+	}
+}
+
+func (a *assembler) Close() error {
+	if a.reader != nil {
+		a.reader.Close()
+	}
+	return a.manifest.Close()
+}
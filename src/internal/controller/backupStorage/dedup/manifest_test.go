@@ -0,0 +1,138 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package dedup
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestManifestRoundTrip(t *testing.T) {
+	storage := newMemoryStorage()
+	ctx := context.Background()
+	chunks := []chunkRef{
+		{Hash: strings.Repeat("ab", 32), Size: 100},
+		{Hash: strings.Repeat("cd", 32), Size: 200},
+		{Hash: strings.Repeat("ab", 32), Size: 100},
+	}
+	id, err := newManifestID()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = storage.Put(ctx, "/manifest", encodeManifest(id, 400, chunks), nil); err != nil {
+		t.Fatal(err)
+	}
+	manifest, err := openManifest(ctx, storage, "/manifest")
+	if err != nil {
+		t.Fatalf("failed to open manifest: %s", err)
+	}
+	defer manifest.Close()
+	if manifest.ID != id || manifest.Size != 400 {
+		t.Errorf("got ID %q and size %d, expected %q and 400", manifest.ID, manifest.Size, id)
+	}
+	for i, expected := range chunks {
+		chunk, err := manifest.Next()
+		if err != nil || chunk != expected {
+			t.Errorf("chunk %d: got %v and error %v, expected %v", i, chunk, err, expected)
+		}
+	}
+	if _, err = manifest.Next(); err != io.EOF {
+		t.Errorf("got %v after the last chunk, expected EOF", err)
+	}
+}
+
+func TestManifestIDs(t *testing.T) {
+	first, _ := newManifestID()
+	second, _ := newManifestID()
+	if first == second || !validManifestID(first) {
+		t.Errorf("IDs %q and %q must be unique hex strings", first, second)
+	}
+	for _, id := range []string{"", "../chunks", "xyz"} {
+		if validManifestID(id) {
+			t.Errorf("ID %q must be invalid", id)
+		}
+	}
+}
+
+func TestManifestNotManifest(t *testing.T) {
+	storage := newMemoryStorage()
+	ctx := context.Background()
+	for _, data := range []string{"", "plain backup", manifestHeader[:10]} {
+		storage.Put(ctx, "/plain", strings.NewReader(data), nil)
+		manifest, err := openManifest(ctx, storage, "/plain")
+		if !errors.Is(err, errNotManifest) {
+			t.Fatalf("%q: got %v, expected errNotManifest", data, err)
+		}
+		// The file is served as is
+		read, _ := io.ReadAll(manifest)
+		manifest.Close()
+		if string(read) != data {
+			t.Errorf("got %q, expected %q", read, data)
+		}
+	}
+}
+
+func TestManifestMalformed(t *testing.T) {
+	storage := newMemoryStorage()
+	ctx := context.Background()
+	id := strings.Repeat("0", 32)
+	hash := strings.Repeat("ab", 32)
+	for _, content := range []string{
+		manifestHeader,
+		manifestHeader + "../id\n1\n",
+		manifestHeader + id + "\n",
+		manifestHeader + id + "\nsize\n",
+	} {
+		storage.Put(ctx, "/broken", strings.NewReader(content), nil)
+		if manifest, err := openManifest(ctx, storage, "/broken"); err == nil || errors.Is(err, errNotManifest) {
+			manifest.Close()
+			t.Errorf("%q: got %v, expected error", content, err)
+		}
+	}
+	for _, line := range []string{"short 1", hash, hash + " size", hash + " 1 extra", hash + " 1"} {
+		storage.Put(ctx, "/broken", bytes.NewBufferString(manifestHeader+id+"\n1\n"+line), nil)
+		manifest, err := openManifest(ctx, storage, "/broken")
+		if err != nil {
+			t.Fatalf("%q: failed to open manifest: %s", line, err)
+		}
+		// The last line is not terminated, so it is truncated
+		if _, err = manifest.Next(); err == nil || err == io.EOF {
+			t.Errorf("%q: got %v, expected error", line, err)
+		}
+		manifest.Close()
+	}
+}
+
+func TestAssemblerCorruptedChunk(t *testing.T) {
+	p, storage := newTestProvider()
+	ctx := context.Background()
+	put(t, p, "/backup", randomData(256<<10, 13))
+	chunks, _ := storage.List(ctx, chunksPrefix)
+	storage.Put(ctx, chunks[0], strings.NewReader("corrupted"), nil)
+	reader, err := p.Get(ctx, "/backup")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reader.Close()
+	if _, err = io.ReadAll(reader); err == nil || !strings.Contains(err.Error(), "corrupted") {
+		t.Errorf("got %v, expected corrupted chunk error", err)
+	}
+}
@@ -0,0 +1,350 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package dedup
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+
+	"golang.org/x/sync/errgroup"
+
+	backupoperatoriov1 "backup-operator.io/api/v1"
+	backupstorage "backup-operator.io/internal/controller/backupStorage"
+)
+
+// Settings Deduplication settings of the storage
+type Settings struct {
+	AverageChunkSize int
+	Concurrency      int
+}
+
+// GetSettings Get deduplication settings of the storage, nil if it is disabled
+func GetSettings(storage *backupoperatoriov1.BackupStorage) *Settings {
+	if storage.Spec.Deduplication == nil {
+		return nil
+	}
+	return &Settings{
+		AverageChunkSize: int(storage.Spec.Deduplication.AverageChunkSize.Value()),
+		Concurrency:      max(int(storage.Spec.Deduplication.Concurrency), 1),
+	}
+}
+
+// Chunks referenced by uploads in progress, garbage collection never deletes them,
+// and chunks being collected, uploads wait for them. Storages may share a bucket, so it is common for all of them.
+var (
+	inUseMutex sync.Mutex
+	inUseCond  = sync.NewCond(&inUseMutex)
+	inUse      = map[string]int{}
+	collecting = map[string]bool{}
+)
+
+// Provider Stores files as content-defined chunks kept once by hash and manifests referencing them.
+// Files of the operator itself except staged backups are stored as is. So are files uploaded with extra options like
+// Object Lock retention, which can not apply to shared chunks, since OptionsPutter is taken
+// from the wrapped provider. Any file that is not a manifest is served as is, e.g. backups
+// made before deduplication has been enabled.
+type Provider struct {
+	backupstorage.BackupStorageProvider
+	settings Settings
+}
+
+// Wrap Wrap provider with deduplication if it is enabled for the storage
+func Wrap(provider backupstorage.BackupStorageProvider, storage *backupoperatoriov1.BackupStorage) backupstorage.BackupStorageProvider {
+	settings := GetSettings(storage)
+	if settings == nil {
+		return provider
+	}
+	return &Provider{BackupStorageProvider: provider, settings: *settings}
+}
+
+// Unwrap Get the wrapped provider
+func (p *Provider) Unwrap() backupstorage.BackupStorageProvider {
+	return p.BackupStorageProvider
+}
+
+// Put Split file into chunks, reference and upload the missing ones and upload manifest to the path.
+// References of the failed upload are deleted along with chunks no other file references.
+func (p *Provider) Put(ctx context.Context, path string, reader io.Reader, metadata map[string]string) (err error) {
+	if storedAsIs(path) {
+		return p.BackupStorageProvider.Put(ctx, path, reader, metadata)
+	}
+	var id string
+	if id, err = newManifestID(); err != nil {
+		return fmt.Errorf("failed to generate manifest ID: %s", err)
+	}
+	var chunks []chunkRef
+	var size uint64
+	var referenced sync.Map
+	acquired := map[string]bool{}
+	defer func() {
+		release(acquired)
+		if err == nil {
+			return
+		}
+		hashes := map[string]bool{}
+		referenced.Range(func(hash, _ any) bool {
+			hashes[hash.(string)] = true
+			return true
+		})
+		if gcErr := p.unreference(context.WithoutCancel(ctx), id, hashes); gcErr != nil {
+			err = fmt.Errorf("%s, failed to delete uploaded chunks: %s", err, gcErr)
+		}
+	}()
+	group, groupCtx := errgroup.WithContext(ctx)
+	group.SetLimit(p.settings.Concurrency)
+	chunker := newChunker(reader, p.settings.AverageChunkSize)
+	for groupCtx.Err() == nil {
+		var chunk []byte
+		if chunk, err = chunker.Next(); err == io.EOF {
+			break
+		} else if err != nil {
+			group.Wait()
+			return fmt.Errorf("failed to read file: %s", err)
+		}
+		sum := sha256.Sum256(chunk)
+		hash := hex.EncodeToString(sum[:])
+		chunks = append(chunks, chunkRef{Hash: hash, Size: len(chunk)})
+		size += uint64(len(chunk))
+		// The same chunk may repeat within the file
+		if acquired[hash] {
+			continue
+		}
+		acquire(hash)
+		acquired[hash] = true
+		group.Go(func() error {
+			// Reference is added before the chunk is checked, so deletion of other files keeps it from now on
+			if err := p.BackupStorageProvider.Put(groupCtx, refPath(hash, id), bytes.NewReader(nil), nil); err != nil {
+				return fmt.Errorf("failed to reference chunk %s: %s", hash, err)
+			}
+			referenced.Store(hash, true)
+			if _, err := p.BackupStorageProvider.Stat(groupCtx, chunkPath(hash)); err == nil {
+				return nil
+			}
+			if err := p.BackupStorageProvider.Put(groupCtx, chunkPath(hash), bytes.NewReader(chunk), nil); err != nil {
+				return fmt.Errorf("failed to upload chunk %s: %s", hash, err)
+			}
+			return nil
+		})
+	}
+	if err = group.Wait(); err != nil {
+		return
+	}
+	if err = ctx.Err(); err != nil {
+		return
+	}
+	return p.BackupStorageProvider.Put(ctx, path, encodeManifest(id, size, chunks), metadata)
+}
+
+// Promote Move manifest to the new path, chunks stay where they are.
+// With extra options the whole file is uploaded to the new path instead, like Put does.
+func (p *Provider) Promote(ctx context.Context, from, to string,
+	metadata map[string]string, options backupstorage.PutOptions,
+) error {
+	if options.IsEmpty() || storedAsIs(from) {
+		if promoter, ok := backupstorage.As[backupstorage.Promoter](p.BackupStorageProvider); ok {
+			return promoter.Promote(ctx, from, to, metadata, options)
+		}
+		return backupstorage.Copy(ctx, p.BackupStorageProvider, from, to, metadata, options)
+	}
+	putter, ok := backupstorage.As[backupstorage.OptionsPutter](p.BackupStorageProvider)
+	if !ok {
+		return fmt.Errorf("storage %s does not support extra upload options", p.GetObject().Name)
+	}
+	reader, err := p.Get(ctx, from)
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+	if err = putter.PutWithOptions(ctx, to, reader, metadata, options); err != nil {
+		return err
+	}
+	return p.Delete(ctx, from)
+}
+
+// Get Reassemble file from chunks
+func (p *Provider) Get(ctx context.Context, path string) (io.ReadCloser, error) {
+	if storedAsIs(path) {
+		return p.BackupStorageProvider.Get(ctx, path)
+	}
+	manifest, err := openManifest(ctx, p.BackupStorageProvider, path)
+	if errors.Is(err, errNotManifest) {
+		return manifest, nil
+	} else if err != nil {
+		return nil, err
+	}
+	return &assembler{ctx: ctx, storage: p.BackupStorageProvider, manifest: manifest, hasher: sha256.New()}, nil
+}
+
+// Stat Get attributes of the manifest with the size of the whole file
+func (p *Provider) Stat(ctx context.Context, path string) (info backupstorage.ObjectInfo, err error) {
+	if info, err = p.BackupStorageProvider.Stat(ctx, path); err != nil || storedAsIs(path) {
+		return
+	}
+	var manifest *manifestReader
+	if manifest, err = openManifest(ctx, p.BackupStorageProvider, path); errors.Is(err, errNotManifest) {
+		return info, manifest.Close()
+	} else if err != nil {
+		return
+	}
+	defer manifest.Close()
+	info.Size = uint(manifest.Size)
+	return
+}
+
+// Delete Remove manifest, its references and chunks no other file references.
+// Chunks of a broken manifest that could not be read are left behind.
+func (p *Provider) Delete(ctx context.Context, path string) error {
+	if storedAsIs(path) {
+		return p.BackupStorageProvider.Delete(ctx, path)
+	}
+	var id string
+	var hashes map[string]bool
+	// Missing file is not an error for deletion, the provider decides
+	if manifest, err := openManifest(ctx, p.BackupStorageProvider, path); err == nil {
+		id = manifest.ID
+		hashes, err = readChunks(manifest)
+		manifest.Close()
+		if err != nil {
+			return fmt.Errorf("failed to read manifest: %s", err)
+		}
+	} else if manifest != nil {
+		manifest.Close()
+	}
+	// Manifest goes first, so a locked one keeps its chunks
+	if err := p.BackupStorageProvider.Delete(ctx, path); err != nil {
+		return err
+	}
+	return p.unreference(ctx, id, hashes)
+}
+
+// unreference Delete references of the manifest to the chunks and the chunks that have no references left.
+// Only the references of these chunks are listed, so it does not depend on the count of files in the storage.
+func (p *Provider) unreference(ctx context.Context, id string, hashes map[string]bool) error {
+	var errsMutex sync.Mutex
+	var errs []error
+	group := &errgroup.Group{}
+	group.SetLimit(p.settings.Concurrency)
+	for hash := range hashes {
+		group.Go(func() error {
+			err := p.BackupStorageProvider.Delete(ctx, refPath(hash, id))
+			if err == nil {
+				err = p.collect(ctx, hash)
+			} else {
+				err = fmt.Errorf("failed to delete reference to chunk %s: %s", hash, err)
+			}
+			if err != nil {
+				errsMutex.Lock()
+				errs = append(errs, err)
+				errsMutex.Unlock()
+			}
+			return nil
+		})
+	}
+	group.Wait()
+	return errors.Join(errs...)
+}
+
+// collect Delete the chunk if it is not referenced by any manifest or upload in progress.
+// Uploads wait until it is done, so they can not reference the chunk being deleted.
+func (p *Provider) collect(ctx context.Context, hash string) error {
+	if !startCollecting(hash) {
+		return nil
+	}
+	defer stopCollecting(hash)
+	refs, err := p.BackupStorageProvider.List(ctx, refsPath(hash))
+	if err != nil {
+		return fmt.Errorf("failed to list references to chunk %s: %s", hash, err)
+	}
+	if len(refs) > 0 {
+		return nil
+	}
+	if err = p.BackupStorageProvider.Delete(ctx, chunkPath(hash)); err != nil {
+		return fmt.Errorf("failed to delete chunk %s: %s", hash, err)
+	}
+	return nil
+}
+
+// storedAsIs Check whether the file belongs to the operator itself and is not deduplicated.
+// Staged backups are deduplicated, so that they are promoted by moving the manifest only.
+func storedAsIs(path string) bool {
+	return strings.HasPrefix(path, backupstorage.InternalPrefix) && !strings.HasPrefix(path, backupstorage.StagingPrefix)
+}
+
+// readChunks Get the set of chunks of the manifest
+func readChunks(manifest *manifestReader) (map[string]bool, error) {
+	hashes := map[string]bool{}
+	for {
+		chunk, err := manifest.Next()
+		if err == io.EOF {
+			return hashes, nil
+		} else if err != nil {
+			return hashes, err
+		}
+		hashes[chunk.Hash] = true
+	}
+}
+
+// acquire Protect the chunk from garbage collection, it waits for the running one
+func acquire(hash string) {
+	inUseMutex.Lock()
+	defer inUseMutex.Unlock()
+	for collecting[hash] {
+		inUseCond.Wait()
+	}
+	inUse[hash]++
+}
+
+// release Let garbage collection delete the chunks
+func release(hashes map[string]bool) {
+	inUseMutex.Lock()
+	defer inUseMutex.Unlock()
+	for hash := range hashes {
+		if inUse[hash]--; inUse[hash] <= 0 {
+			delete(inUse, hash)
+		}
+	}
+}
+
+// startCollecting Mark the chunk as being collected, false if it is in use.
+// It waits for the running collection, since it could have listed the references before the last one is deleted.
+func startCollecting(hash string) bool {
+	inUseMutex.Lock()
+	defer inUseMutex.Unlock()
+	for collecting[hash] {
+		inUseCond.Wait()
+	}
+	if inUse[hash] > 0 {
+		return false
+	}
+	collecting[hash] = true
+	return true
+}
+
+// stopCollecting Let uploads waiting for the chunk continue
+func stopCollecting(hash string) {
+	inUseMutex.Lock()
+	defer inUseMutex.Unlock()
+	delete(collecting, hash)
+	inUseCond.Broadcast()
+}
@@ -0,0 +1,311 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package dedup
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+
+	backupoperatoriov1 "backup-operator.io/api/v1"
+	backupstorage "backup-operator.io/internal/controller/backupStorage"
+)
+
+var errFailingReader = errors.New("read failed")
+
+// failingReader Fails on every read
+type failingReader struct{}
+
+func (r *failingReader) Read([]byte) (int, error) {
+	return 0, errFailingReader
+}
+
+// memoryStorage Keeps files in memory and counts listings
+type memoryStorage struct {
+	mutex sync.Mutex
+	files map[string][]byte
+	lists []string
+}
+
+func newMemoryStorage() *memoryStorage {
+	return &memoryStorage{files: map[string][]byte{}}
+}
+
+func (m *memoryStorage) GetObject() *backupoperatoriov1.BackupStorage {
+	return &backupoperatoriov1.BackupStorage{}
+}
+
+func (m *memoryStorage) Constructor(*backupoperatoriov1.BackupStorage, map[string]string, map[string]string) error {
+	return nil
+}
+
+func (m *memoryStorage) Destructor() error {
+	return nil
+}
+
+func (m *memoryStorage) Put(_ context.Context, path string, reader io.Reader, _ map[string]string) error {
+	data, err := io.ReadAll(reader)
+	if err != nil {
+		return err
+	}
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.files[path] = data
+	return nil
+}
+
+func (m *memoryStorage) Get(_ context.Context, path string) (io.ReadCloser, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	data, ok := m.files[path]
+	if !ok {
+		return nil, fs.ErrNotExist
+	}
+	return io.NopCloser(bytes.NewReader(data)), nil
+}
+
+func (m *memoryStorage) List(_ context.Context, path string) (list []string, err error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.lists = append(m.lists, path)
+	for key := range m.files {
+		if strings.HasPrefix(key, path) {
+			list = append(list, key)
+		}
+	}
+	sort.Strings(list)
+	return
+}
+
+func (m *memoryStorage) Delete(_ context.Context, path string) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	delete(m.files, path)
+	return nil
+}
+
+func (m *memoryStorage) Stat(_ context.Context, path string) (info backupstorage.ObjectInfo, err error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	data, ok := m.files[path]
+	if !ok {
+		return info, fs.ErrNotExist
+	}
+	info.Size = uint(len(data))
+	return
+}
+
+// count Count files with the prefix
+func (m *memoryStorage) count(prefix string) (count int) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	for key := range m.files {
+		if strings.HasPrefix(key, prefix) {
+			count++
+		}
+	}
+	return
+}
+
+func newTestProvider() (*Provider, *memoryStorage) {
+	storage := newMemoryStorage()
+	return &Provider{BackupStorageProvider: storage, settings: Settings{AverageChunkSize: 64 << 10, Concurrency: 4}}, storage
+}
+
+func put(t *testing.T, p *Provider, path string, data []byte) {
+	t.Helper()
+	if err := p.Put(context.Background(), path, bytes.NewReader(data), nil); err != nil {
+		t.Fatalf("failed to put %s: %s", path, err)
+	}
+}
+
+func get(t *testing.T, p *Provider, path string) []byte {
+	t.Helper()
+	reader, err := p.Get(context.Background(), path)
+	if err != nil {
+		t.Fatalf("failed to get %s: %s", path, err)
+	}
+	defer reader.Close()
+	data, err := io.ReadAll(reader)
+	if err != nil {
+		t.Fatalf("failed to read %s: %s", path, err)
+	}
+	return data
+}
+
+func TestProviderRoundTrip(t *testing.T) {
+	p, _ := newTestProvider()
+	data := randomData(1<<20, 5)
+	put(t, p, "/backup", data)
+	if !bytes.Equal(get(t, p, "/backup"), data) {
+		t.Error("file differs from the uploaded one")
+	}
+	info, err := p.Stat(context.Background(), "/backup")
+	if err != nil || info.Size != uint(len(data)) {
+		t.Errorf("got size %d and error %v, expected size %d", info.Size, err, len(data))
+	}
+}
+
+func TestProviderReferenceCounting(t *testing.T) {
+	p, storage := newTestProvider()
+	ctx := context.Background()
+	base := randomData(2<<20, 6)
+	// The second file shares most of the chunks with the first one
+	changed := append(append([]byte{}, base...), randomData(256<<10, 7)...)
+	put(t, p, "/first", base)
+	firstChunks := storage.count(chunksPrefix)
+	put(t, p, "/second", changed)
+	bothChunks := storage.count(chunksPrefix)
+	if bothChunks <= firstChunks || bothChunks >= 2*firstChunks {
+		t.Fatalf("got %d chunks for the first file and %d for both, expected shared ones", firstChunks, bothChunks)
+	}
+
+	storage.lists = nil
+	if err := p.Delete(ctx, "/first"); err != nil {
+		t.Fatalf("failed to delete: %s", err)
+	}
+	if !bytes.Equal(get(t, p, "/second"), changed) {
+		t.Error("the second file is broken after deletion of the first one")
+	}
+	// Only references of the deleted file chunks are listed, never the whole storage
+	for _, path := range storage.lists {
+		if !strings.HasPrefix(path, refsPrefix) {
+			t.Errorf("deletion has listed %s", path)
+		}
+	}
+
+	if err := p.Delete(ctx, "/second"); err != nil {
+		t.Fatalf("failed to delete: %s", err)
+	}
+	if left := storage.count(backupstorage.InternalPrefix); left != 0 {
+		t.Errorf("%d chunks and references are left after deletion of all files", left)
+	}
+}
+
+func TestProviderRepeatedChunks(t *testing.T) {
+	p, storage := newTestProvider()
+	block := randomData(512<<10, 8)
+	put(t, p, "/repeated", bytes.Repeat(block, 4))
+	if err := p.Delete(context.Background(), "/repeated"); err != nil {
+		t.Fatalf("failed to delete: %s", err)
+	}
+	if left := storage.count(backupstorage.InternalPrefix); left != 0 {
+		t.Errorf("%d chunks and references are left after deletion", left)
+	}
+}
+
+func TestProviderPromote(t *testing.T) {
+	p, storage := newTestProvider()
+	ctx := context.Background()
+	data := randomData(1<<20, 9)
+	staging := backupstorage.StagingPrefix + "uid"
+	put(t, p, staging, data)
+	chunks := storage.count(chunksPrefix)
+	if err := p.Promote(ctx, staging, "/backup", nil, backupstorage.PutOptions{}); err != nil {
+		t.Fatalf("failed to promote: %s", err)
+	}
+	if storage.count(chunksPrefix) != chunks || !bytes.Equal(get(t, p, "/backup"), data) {
+		t.Fatal("chunks have changed after promotion")
+	}
+	// References are kept by manifest ID, so they are valid after the move
+	if err := p.Delete(ctx, "/backup"); err != nil {
+		t.Fatalf("failed to delete: %s", err)
+	}
+	if left := storage.count(backupstorage.InternalPrefix); left != 0 {
+		t.Errorf("%d chunks and references are left after deletion", left)
+	}
+}
+
+func TestProviderFailedPut(t *testing.T) {
+	p, storage := newTestProvider()
+	ctx := context.Background()
+	put(t, p, "/existing", randomData(1<<20, 10))
+	existing := storage.count(backupstorage.InternalPrefix)
+	// Upload shares the first chunks with the existing file and fails in the middle
+	reader := io.MultiReader(bytes.NewReader(randomData(1<<20, 10)), bytes.NewReader(randomData(1<<20, 11)), &failingReader{})
+	if err := p.Put(ctx, "/failed", reader, nil); err == nil {
+		t.Fatal("upload has not failed")
+	}
+	if _, err := storage.Stat(ctx, "/failed"); err == nil {
+		t.Error("manifest of the failed upload exists")
+	}
+	if left := storage.count(backupstorage.InternalPrefix); left != existing {
+		t.Errorf("got %d chunks and references after the failed upload, expected %d", left, existing)
+	}
+	if len(get(t, p, "/existing")) != 1<<20 {
+		t.Error("the existing file is broken after the failed upload")
+	}
+}
+
+func TestProviderConcurrent(t *testing.T) {
+	p, storage := newTestProvider()
+	ctx := context.Background()
+	data := randomData(1<<20, 12)
+	put(t, p, "/0", data)
+	// Files with the same chunks are uploaded and deleted at the same time
+	var group sync.WaitGroup
+	for i := 1; i <= 8; i++ {
+		group.Add(2)
+		go func() {
+			defer group.Done()
+			if err := p.Put(ctx, fmt.Sprintf("/%d", i), bytes.NewReader(data), nil); err != nil {
+				t.Errorf("failed to put: %s", err)
+			}
+		}()
+		go func() {
+			defer group.Done()
+			if err := p.Delete(ctx, fmt.Sprintf("/%d", i-1)); err != nil {
+				t.Errorf("failed to delete: %s", err)
+			}
+		}()
+	}
+	group.Wait()
+	for i := 1; i <= 8; i++ {
+		path := fmt.Sprintf("/%d", i)
+		if _, err := storage.Stat(ctx, path); err == nil && !bytes.Equal(get(t, p, path), data) {
+			t.Errorf("%s is broken", path)
+		}
+	}
+	if _, err := storage.Stat(ctx, "/8"); err != nil {
+		t.Error("the last file has been deleted")
+	}
+}
+
+func TestProviderNotManifest(t *testing.T) {
+	p, storage := newTestProvider()
+	ctx := context.Background()
+	data := []byte("plain backup")
+	if err := storage.Put(ctx, "/plain", bytes.NewReader(data), nil); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(get(t, p, "/plain"), data) {
+		t.Error("file is not served as is")
+	}
+	if err := p.Delete(ctx, "/plain"); err != nil {
+		t.Fatalf("failed to delete: %s", err)
+	}
+	if storage.count("/") != 0 {
+		t.Error("file is not deleted")
+	}
+}
@@ -0,0 +1,132 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package backupstorage
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	backupoperatoriov1 "backup-operator.io/api/v1"
+)
+
+// InternalPrefix Files of the operator itself are kept under this path, they do not belong to any run
+const InternalPrefix = "/.backup-operator/"
+
+const (
+	// Used if .spec.healthCheck has no interval or timeout, they are defaulted by the API server otherwise
+	defaultHealthCheckInterval = 5 * time.Minute
+	defaultHealthCheckTimeout  = 30 * time.Second
+	// Failed health check is repeated sooner than the interval
+	healthCheckRetryInterval = time.Minute
+	// Size of the probe file in bytes
+	healthCheckProbeSize = 1024
+)
+
+// healthState Result of the last health check
+type healthState struct {
+	checkedAt time.Time
+	err       error
+}
+
+// Last health check results by storage UID
+var healthStates = &sync.Map{}
+
+// HealthCheckEnabled Check whether the storage has to be probed. It is opt-in, since every probe writes
+// to the storage, which leaves locked object versions behind on buckets with default Object Lock retention.
+func HealthCheckEnabled(storage *backupoperatoriov1.BackupStorage) bool {
+	return storage.Spec.HealthCheck != nil && storage.Spec.HealthCheck.Enabled
+}
+
+// UntilNextHealthCheck Get time left until the next health check of the storage, it is due if not positive
+func UntilNextHealthCheck(storage *backupoperatoriov1.BackupStorage) time.Duration {
+	value, checked := healthStates.Load(storage.UID)
+	if !checked {
+		return 0
+	}
+	state := value.(healthState)
+	interval := defaultHealthCheckInterval
+	if storage.Spec.HealthCheck != nil && storage.Spec.HealthCheck.Interval.Duration > 0 {
+		interval = storage.Spec.HealthCheck.Interval.Duration
+	}
+	if state.err != nil {
+		interval = min(interval, healthCheckRetryInterval)
+	}
+	return time.Until(state.checkedAt.Add(interval))
+}
+
+// GetHealthError Get error of the last health check, nil if it has passed or has not been done yet
+func GetHealthError(storage *backupoperatoriov1.BackupStorage) error {
+	if value, checked := healthStates.Load(storage.UID); checked {
+		return value.(healthState).err
+	}
+	return nil
+}
+
+// ForgetHealth Drop health state of the storage, so it is checked again right away
+func ForgetHealth(storage *backupoperatoriov1.BackupStorage) {
+	healthStates.Delete(storage.UID)
+}
+
+// CheckHealth Put, get and delete a small probe file and remember the result
+func CheckHealth(ctx context.Context, storage *backupoperatoriov1.BackupStorage, provider BackupStorageProvider) error {
+	timeout := defaultHealthCheckTimeout
+	if storage.Spec.HealthCheck != nil && storage.Spec.HealthCheck.Timeout.Duration > 0 {
+		timeout = storage.Spec.HealthCheck.Timeout.Duration
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	err := probe(ctx, provider, InternalPrefix+"health/"+storage.Name)
+	healthStates.Store(storage.UID, healthState{checkedAt: time.Now(), err: err})
+	return err
+}
+
+// probe Make the round trip with random content
+func probe(ctx context.Context, provider BackupStorageProvider, path string) (err error) {
+	content := make([]byte, healthCheckProbeSize)
+	if _, err = rand.Read(content); err != nil {
+		return
+	}
+	if err = provider.Put(ctx, path, bytes.NewReader(content), nil); err != nil {
+		return fmt.Errorf("failed to put probe file: %s", err)
+	}
+	// Probe file is deleted even if it could not be read. It may be locked by bucket default retention,
+	// which is not a failure, the same probe file is overwritten next time anyway
+	defer func() {
+		if deleteErr := provider.Delete(ctx, path); deleteErr != nil && !errors.Is(deleteErr, ErrObjectLocked) && err == nil {
+			err = fmt.Errorf("failed to delete probe file: %s", deleteErr)
+		}
+	}()
+	var reader io.ReadCloser
+	if reader, err = provider.Get(ctx, path); err != nil {
+		return fmt.Errorf("failed to get probe file: %s", err)
+	}
+	defer reader.Close()
+	var downloaded []byte
+	if downloaded, err = io.ReadAll(reader); err != nil {
+		return fmt.Errorf("failed to read probe file: %s", err)
+	}
+	if !bytes.Equal(content, downloaded) {
+		return errors.New("probe file content has changed")
+	}
+	return
+}
@@ -0,0 +1,149 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package backupstorage
+
+import (
+	"context"
+	"io"
+	"sync"
+
+	"backup-operator.io/internal/monitoring"
+)
+
+// Operations measured by instrumentedProvider
+var instrumentedOperations = []string{"put", "get", "list", "delete", "stat", "promote"}
+
+// instrumentedProvider Wraps provider to measure duration and count errors of its operations.
+// It also counts running operations, so a replaced provider is destroyed only when they are over.
+// Optional interfaces are not implemented, use As to get them from the wrapped provider.
+type instrumentedProvider struct {
+	BackupStorageProvider
+	name string
+
+	mutex sync.Mutex
+	// Count of running operations and holds
+	active int
+	// Called once there are no running operations, set when the provider is replaced
+	onIdle func()
+}
+
+// instrument Wrap provider unless it has been wrapped already
+func instrument(name string, provider BackupStorageProvider) BackupStorageProvider {
+	if _, ok := provider.(*instrumentedProvider); ok {
+		return provider
+	}
+	// Export zero errors, so rates are defined before the first failure
+	for _, operation := range instrumentedOperations {
+		monitoring.BackupOperatorStorageOperationErrorsTotal.WithLabelValues(name, operation)
+	}
+	return &instrumentedProvider{BackupStorageProvider: provider, name: name}
+}
+
+// Unwrap Get the wrapped provider
+func (p *instrumentedProvider) Unwrap() BackupStorageProvider {
+	return p.BackupStorageProvider
+}
+
+// hold Count operation as running until the returned function is called
+func (p *instrumentedProvider) hold() (release func()) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	p.active++
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			p.mutex.Lock()
+			defer p.mutex.Unlock()
+			if p.active--; p.active == 0 && p.onIdle != nil {
+				go p.onIdle()
+				p.onIdle = nil
+			}
+		})
+	}
+}
+
+// retire Call the function once there are no running operations
+func (p *instrumentedProvider) retire(onIdle func()) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	if p.active == 0 {
+		go onIdle()
+		return
+	}
+	p.onIdle = onIdle
+}
+
+// Put Upload file.
+func (p *instrumentedProvider) Put(ctx context.Context, path string, reader io.Reader, metadata map[string]string) error {
+	defer p.hold()()
+	return observeOperation(p.name, "put", func() error {
+		return p.BackupStorageProvider.Put(ctx, path, reader, metadata)
+	})
+}
+
+// Get Download file. Only the time to open the file is measured.
+func (p *instrumentedProvider) Get(ctx context.Context, path string) (reader io.ReadCloser, err error) {
+	release := p.hold()
+	if err = observeOperation(p.name, "get", func() (err error) {
+		reader, err = p.BackupStorageProvider.Get(ctx, path)
+		return
+	}); err != nil {
+		release()
+		return
+	}
+	// Download is running until the reader is closed
+	return &heldReader{ReadCloser: reader, release: release}, nil
+}
+
+// List path.
+func (p *instrumentedProvider) List(ctx context.Context, path string) (list []string, err error) {
+	defer p.hold()()
+	err = observeOperation(p.name, "list", func() (err error) {
+		list, err = p.BackupStorageProvider.List(ctx, path)
+		return
+	})
+	return
+}
+
+// Delete Remove path.
+func (p *instrumentedProvider) Delete(ctx context.Context, path string) error {
+	defer p.hold()()
+	return observeOperation(p.name, "delete", func() error {
+		return p.BackupStorageProvider.Delete(ctx, path)
+	})
+}
+
+// Stat Get file attributes
+func (p *instrumentedProvider) Stat(ctx context.Context, path string) (info ObjectInfo, err error) {
+	defer p.hold()()
+	err = observeOperation(p.name, "stat", func() (err error) {
+		info, err = p.BackupStorageProvider.Stat(ctx, path)
+		return
+	})
+	return
+}
+
+// heldReader Releases the provider when the download is closed
+type heldReader struct {
+	io.ReadCloser
+	release func()
+}
+
+func (r *heldReader) Close() error {
+	defer r.release()
+	return r.ReadCloser.Close()
+}
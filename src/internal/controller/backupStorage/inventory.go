@@ -0,0 +1,192 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package backupstorage
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/util/retry"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	backupoperatoriov1 "backup-operator.io/api/v1"
+	"backup-operator.io/internal/controller/utils"
+)
+
+// Maximum count of paths kept in inventory status lists, counters are not limited
+const inventoryStatusLimit = 500
+
+// Failed inventory is retried sooner than the interval, but not on every reconciliation
+const inventoryRetryInterval = 10 * time.Minute
+
+// Time of the last failed inventory by storage UID
+var failedInventories = &sync.Map{}
+
+// InventoryResult holds changes found by the inventory
+type InventoryResult struct {
+	// Files that have become orphaned since the previous inventory
+	NewOrphans []string
+	// Runs in namespace/name format whose files have disappeared since the previous inventory
+	NewMissing []string
+	// Orphaned files deleted according to the orphan policy
+	Deleted []string
+	// Errors of orphaned files deletion by path
+	FailedDeletions map[string]error
+}
+
+// UntilNextInventory Get time left until the next inventory of the storage, it is due if not positive
+func UntilNextInventory(storage *backupoperatoriov1.BackupStorage) time.Duration {
+	interval := storage.Spec.Inventory.Interval.Duration
+	if failedAt, failed := failedInventories.Load(storage.UID); failed {
+		return time.Until(failedAt.(time.Time).Add(min(interval, inventoryRetryInterval)))
+	}
+	if storage.Status.Inventory == nil || storage.Status.Inventory.LastInventoryTime == nil {
+		return 0
+	}
+	return time.Until(storage.Status.Inventory.LastInventoryTime.Add(interval))
+}
+
+// ForgetInventory Drop inventory state of the deleted storage
+func ForgetInventory(storage *backupoperatoriov1.BackupStorage) {
+	failedInventories.Delete(storage.UID)
+}
+
+// TakeInventory Compare files in the storage with BackupRuns pointing at it, delete orphaned files
+// if the orphan policy says so and save the result into the storage status.
+// Files of runs of other storages are not orphaned, since they may point at the same bucket.
+func TakeInventory(ctx context.Context, c client.Client, storage *backupoperatoriov1.BackupStorage,
+	provider BackupStorageProvider,
+) (result InventoryResult, err error) {
+	defer func() {
+		if err != nil {
+			failedInventories.Store(storage.UID, time.Now())
+		} else {
+			failedInventories.Delete(storage.UID)
+		}
+	}()
+	inventory := storage.Spec.Inventory
+	// Files are listed before runs, so a file can not appear after its run has been missed
+	var paths []string
+	if paths, err = provider.List(ctx, inventory.Prefix); err != nil {
+		return result, fmt.Errorf("could not list files: %s", err)
+	}
+	// Runs of all storages own their paths, since storages may share a bucket
+	runs := &backupoperatoriov1.BackupRunList{}
+	if err = c.List(ctx, runs); err != nil {
+		return result, fmt.Errorf("could not list runs: %s", err)
+	}
+	objects := make(map[string]bool, len(paths))
+	for _, path := range paths {
+		// Skip directory markers and files of the operator itself
+		if !strings.HasSuffix(path, "/") && !strings.HasPrefix(path, InternalPrefix) {
+			objects[path] = true
+		}
+	}
+	// Find runs without files, any run owns its path even if it has not uploaded anything yet
+	owned := make(map[string]bool, len(runs.Items))
+	var missing []string
+	for _, run := range runs.Items {
+		owned[run.Spec.Storage.Path] = true
+		if run.Spec.Storage.Name != storage.Name || !strings.HasPrefix(run.Spec.Storage.Path, inventory.Prefix) ||
+			!run.DeletionTimestamp.IsZero() {
+			continue
+		}
+		// File must exist for successful backups and for restore-only runs
+		successful, _ := utils.GetConditionByType(&run.Status.Conditions,
+			string(backupoperatoriov1.BackupRunConditionTypeSuccessful))
+		if (run.Spec.Backup == nil || (successful != nil && successful.Status == metav1.ConditionTrue)) &&
+			!objects[run.Spec.Storage.Path] {
+			missing = append(missing, fmt.Sprintf("%s/%s", run.Namespace, run.Name))
+		}
+	}
+	sort.Strings(missing)
+	// Find orphaned files keeping the time they have been found for the first time
+	now := metav1.Now()
+	previousOrphans := make(map[string]metav1.Time)
+	previousMissing := make(map[string]bool)
+	if storage.Status.Inventory != nil {
+		for _, orphan := range storage.Status.Inventory.OrphanedObjects {
+			previousOrphans[orphan.Path] = orphan.Since
+		}
+		for _, run := range storage.Status.Inventory.MissingObjects {
+			previousMissing[run] = true
+		}
+	}
+	var orphans []backupoperatoriov1.OrphanedObject
+	for path := range objects {
+		if owned[path] {
+			continue
+		}
+		since, known := previousOrphans[path]
+		if !known {
+			since = now
+			result.NewOrphans = append(result.NewOrphans, path)
+		}
+		// Delete the file if it has been orphaned long enough
+		if inventory.OrphanPolicy == backupoperatoriov1.OrphanPolicyDelete &&
+			now.Sub(since.Time) >= inventory.GracePeriod.Duration {
+			if deleteErr := provider.Delete(ctx, path); deleteErr != nil {
+				if result.FailedDeletions == nil {
+					result.FailedDeletions = make(map[string]error)
+				}
+				result.FailedDeletions[path] = deleteErr
+			} else {
+				result.Deleted = append(result.Deleted, path)
+				continue
+			}
+		}
+		orphans = append(orphans, backupoperatoriov1.OrphanedObject{Path: path, Since: since})
+	}
+	// The oldest orphans are kept in status if the list is truncated, so their grace period is not reset
+	sort.Slice(orphans, func(i, j int) bool {
+		if !orphans[i].Since.Equal(&orphans[j].Since) {
+			return orphans[i].Since.Before(&orphans[j].Since)
+		}
+		return orphans[i].Path < orphans[j].Path
+	})
+	for _, run := range missing {
+		if !previousMissing[run] {
+			result.NewMissing = append(result.NewMissing, run)
+		}
+	}
+	sort.Strings(result.NewOrphans)
+	sort.Strings(result.Deleted)
+	status := &backupoperatoriov1.StorageInventoryStatus{
+		LastInventoryTime:    &now,
+		Objects:              uint(len(objects) - len(result.Deleted)),
+		OrphanedObjectsCount: uint(len(orphans)),
+		OrphanedObjects:      orphans[:min(len(orphans), inventoryStatusLimit)],
+		MissingObjectsCount:  uint(len(missing)),
+		MissingObjects:       missing[:min(len(missing), inventoryStatusLimit)],
+	}
+	if err = retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		if err = c.Get(ctx, client.ObjectKeyFromObject(storage), storage); err != nil {
+			return err
+		}
+		storage.Status.Inventory = status
+		return c.Status().Update(ctx, storage)
+	}); err != nil {
+		return result, fmt.Errorf("could not save inventory: %s", err)
+	}
+	UpdateInventoryMetric(storage)
+	return
+}
@@ -17,16 +17,59 @@ limitations under the License.
 package backupstorage
 
 import (
+	"time"
+
 	backupoperatoriov1 "backup-operator.io/api/v1"
+	"backup-operator.io/internal/controller/utils"
 	"backup-operator.io/internal/monitoring"
 	"github.com/prometheus/client_golang/prometheus"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
 func UpdateMetric(storage *backupoperatoriov1.BackupStorage) {
 	DeleteMetric(storage)
-	monitoring.BackupOperatorStorageStatus.WithLabelValues(
-		storage.Name, string(storage.Spec.Type),
-	).SetToCurrentTime()
+	gauge := monitoring.BackupOperatorStorageStatus.WithLabelValues(storage.Name, string(storage.Spec.Type))
+	// Not ready storage, e.g. failing its health check, has zero status
+	if ready, err := utils.GetConditionByType(&storage.Status.Conditions,
+		backupoperatoriov1.ConditionTypeReady); err != nil || ready.Status != metav1.ConditionTrue {
+		gauge.Set(0)
+		return
+	}
+	gauge.SetToCurrentTime()
+}
+
+func UpdateInventoryMetric(storage *backupoperatoriov1.BackupStorage) {
+	if storage.Status.Inventory == nil {
+		return
+	}
+	monitoring.BackupOperatorStorageOrphanedObjects.WithLabelValues(storage.Name).
+		Set(float64(storage.Status.Inventory.OrphanedObjectsCount))
+	monitoring.BackupOperatorStorageMissingObjects.WithLabelValues(storage.Name).
+		Set(float64(storage.Status.Inventory.MissingObjectsCount))
+}
+
+func UpdateQuotaMetric(storage *backupoperatoriov1.BackupStorage, exceeded bool) {
+	if storage.Spec.Quota == nil {
+		DeleteQuotaMetric(storage)
+		return
+	}
+	var value float64
+	if exceeded {
+		value = 1
+	}
+	monitoring.BackupOperatorStorageQuotaExceeded.WithLabelValues(storage.Name).Set(value)
+}
+
+// observeOperation Call the storage operation measuring its duration and counting its errors
+func observeOperation(name, operation string, call func() error) error {
+	start := time.Now()
+	err := call()
+	monitoring.BackupOperatorStorageOperationDurationSeconds.WithLabelValues(name, operation).
+		Observe(time.Since(start).Seconds())
+	if err != nil {
+		monitoring.BackupOperatorStorageOperationErrorsTotal.WithLabelValues(name, operation).Inc()
+	}
+	return err
 }
 
 func DeleteMetric(storage *backupoperatoriov1.BackupStorage) {
@@ -34,3 +77,21 @@ func DeleteMetric(storage *backupoperatoriov1.BackupStorage) {
 		"name": storage.Name,
 	})
 }
+
+func DeleteInventoryMetric(storage *backupoperatoriov1.BackupStorage) {
+	labels := prometheus.Labels{"name": storage.Name}
+	monitoring.BackupOperatorStorageOrphanedObjects.DeletePartialMatch(labels)
+	monitoring.BackupOperatorStorageMissingObjects.DeletePartialMatch(labels)
+}
+
+func DeleteQuotaMetric(storage *backupoperatoriov1.BackupStorage) {
+	monitoring.BackupOperatorStorageQuotaExceeded.DeletePartialMatch(prometheus.Labels{
+		"name": storage.Name,
+	})
+}
+
+func DeleteOperationMetric(storage *backupoperatoriov1.BackupStorage) {
+	labels := prometheus.Labels{"name": storage.Name}
+	monitoring.BackupOperatorStorageOperationDurationSeconds.DeletePartialMatch(labels)
+	monitoring.BackupOperatorStorageOperationErrorsTotal.DeletePartialMatch(labels)
+}
@@ -0,0 +1,222 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package storageplugin
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"sync"
+
+	"github.com/creasty/defaults"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	storagepluginv1 "backup-operator.io/api/storageplugin/v1"
+	backupoperatoriov1 "backup-operator.io/api/v1"
+	backupstorage "backup-operator.io/internal/controller/backupStorage"
+)
+
+// Server serves any BackupStorageProvider implementation over the plugin protocol.
+// Every BackupStorage gets its own provider object created with Factory.
+type Server struct {
+	storagepluginv1.UnimplementedStorageProviderServer
+
+	// Creates a new provider object, it is configured later with Constructor
+	Factory func() backupstorage.BackupStorageProvider
+
+	// Configured providers by storage name
+	providers sync.Map
+}
+
+// NewServer Create plugin server for the provider implementation
+func NewServer(factory func() backupstorage.BackupStorageProvider) *Server {
+	return &Server{Factory: factory}
+}
+
+// Configure Create and configure provider for the storage
+func (s *Server) Configure(_ context.Context, request *storagepluginv1.ConfigureRequest) (*storagepluginv1.ConfigureResponse, error) {
+	if len(request.GetStorage()) == 0 {
+		return nil, status.Error(codes.InvalidArgument, "storage name is mandatory")
+	}
+	provider := s.Factory()
+	if err := defaults.Set(provider); err != nil {
+		return nil, status.Errorf(codes.Internal, "could not set default provider settings: %s", err)
+	}
+	object := &backupoperatoriov1.BackupStorage{ObjectMeta: metav1.ObjectMeta{Name: request.GetStorage()}}
+	if err := provider.Constructor(object, request.GetParameters(), request.GetCredentials()); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	// Replace the old provider if any
+	if old, loaded := s.providers.Swap(request.GetStorage(), provider); loaded {
+		old.(backupstorage.BackupStorageProvider).Destructor()
+	}
+	return &storagepluginv1.ConfigureResponse{}, nil
+}
+
+// Release Destroy provider of the storage
+func (s *Server) Release(_ context.Context, request *storagepluginv1.ReleaseRequest) (*storagepluginv1.ReleaseResponse, error) {
+	if provider, loaded := s.providers.LoadAndDelete(request.GetStorage()); loaded {
+		if err := provider.(backupstorage.BackupStorageProvider).Destructor(); err != nil {
+			return nil, status.Error(codes.Internal, err.Error())
+		}
+	}
+	return &storagepluginv1.ReleaseResponse{}, nil
+}
+
+// Put Receive file in chunks and pass it to the provider as a stream
+func (s *Server) Put(stream storagepluginv1.StorageProvider_PutServer) (err error) {
+	var request *storagepluginv1.PutRequest
+	if request, err = stream.Recv(); err != nil {
+		return
+	}
+	header := request.GetHeader()
+	if header == nil {
+		return status.Error(codes.InvalidArgument, "the first message must be a header")
+	}
+	var provider backupstorage.BackupStorageProvider
+	if provider, err = s.getProvider(header.GetStorage()); err != nil {
+		return
+	}
+	reader, writer := io.Pipe()
+	go func() {
+		for {
+			request, err := stream.Recv()
+			if err == io.EOF {
+				writer.Close()
+				return
+			} else if err != nil {
+				// Provider must not finalize the file if the client has gone
+				writer.CloseWithError(err)
+				return
+			}
+			if _, err = writer.Write(request.GetData()); err != nil {
+				return
+			}
+		}
+	}()
+	err = provider.Put(stream.Context(), header.GetPath(), reader, header.GetMetadata())
+	// Unblock the receiving goroutine if the provider has stopped reading
+	reader.CloseWithError(errors.New("provider has stopped reading"))
+	if err != nil {
+		return toStatus(err)
+	}
+	return stream.SendAndClose(&storagepluginv1.PutResponse{})
+}
+
+// Get Send file in chunks
+func (s *Server) Get(request *storagepluginv1.GetRequest, stream storagepluginv1.StorageProvider_GetServer) (err error) {
+	var provider backupstorage.BackupStorageProvider
+	if provider, err = s.getProvider(request.GetStorage()); err != nil {
+		return
+	}
+	var reader io.ReadCloser
+	if reader, err = provider.Get(stream.Context(), request.GetPath()); err != nil {
+		return toStatus(err)
+	}
+	defer reader.Close()
+	buffer := make([]byte, storagepluginv1.ChunkSize)
+	for {
+		n, readErr := reader.Read(buffer)
+		if n > 0 {
+			if err = stream.Send(&storagepluginv1.GetResponse{Data: buffer[:n]}); err != nil {
+				return
+			}
+		}
+		if readErr == io.EOF {
+			return nil
+		} else if readErr != nil {
+			return toStatus(readErr)
+		}
+	}
+}
+
+// List List files with path prefix
+func (s *Server) List(ctx context.Context, request *storagepluginv1.ListRequest) (*storagepluginv1.ListResponse, error) {
+	provider, err := s.getProvider(request.GetStorage())
+	if err != nil {
+		return nil, err
+	}
+	var list []string
+	if list, err = provider.List(ctx, request.GetPath()); err != nil {
+		return nil, toStatus(err)
+	}
+	return &storagepluginv1.ListResponse{Paths: list}, nil
+}
+
+// Delete Remove file
+func (s *Server) Delete(ctx context.Context, request *storagepluginv1.DeleteRequest) (*storagepluginv1.DeleteResponse, error) {
+	provider, err := s.getProvider(request.GetStorage())
+	if err != nil {
+		return nil, err
+	}
+	if err = provider.Delete(ctx, request.GetPath()); err != nil {
+		return nil, toStatus(err)
+	}
+	return &storagepluginv1.DeleteResponse{}, nil
+}
+
+// Stat Get file attributes
+func (s *Server) Stat(ctx context.Context, request *storagepluginv1.StatRequest) (*storagepluginv1.StatResponse, error) {
+	provider, err := s.getProvider(request.GetStorage())
+	if err != nil {
+		return nil, err
+	}
+	var info backupstorage.ObjectInfo
+	if info, err = provider.Stat(ctx, request.GetPath()); err != nil {
+		return nil, toStatus(err)
+	}
+	response := &storagepluginv1.StatResponse{
+		Size:     uint64(info.Size),
+		Etag:     info.ETag,
+		Metadata: info.Metadata,
+	}
+	if !info.ModTime.IsZero() {
+		response.ModTime = timestamppb.New(info.ModTime)
+	}
+	return response, nil
+}
+
+// getProvider Get configured provider of the storage.
+// FailedPrecondition tells the operator to call Configure again, e.g. after the plugin restart.
+func (s *Server) getProvider(storage string) (backupstorage.BackupStorageProvider, error) {
+	provider, ok := s.providers.Load(storage)
+	if !ok {
+		return nil, status.Error(codes.FailedPrecondition, fmt.Sprintf("storage %s is not configured", storage))
+	}
+	return provider.(backupstorage.BackupStorageProvider), nil
+}
+
+// toStatus Convert provider error to gRPC status.
+// Only missing files are reported as NotFound, so the operator does not take other failures for a missing file.
+func toStatus(err error) error {
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		return status.Error(codes.NotFound, err.Error())
+	case errors.Is(err, context.Canceled):
+		return status.Error(codes.Canceled, err.Error())
+	case errors.Is(err, context.DeadlineExceeded):
+		return status.Error(codes.DeadlineExceeded, err.Error())
+	case errors.Is(err, backupstorage.ErrObjectLocked):
+		return status.Error(codes.PermissionDenied, err.Error())
+	}
+	return status.Error(codes.Internal, err.Error())
+}
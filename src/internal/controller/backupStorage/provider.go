@@ -18,8 +18,11 @@ package backupstorage
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"io"
 	"sync"
+	"time"
 
 	backupoperatoriov1 "backup-operator.io/api/v1"
 )
@@ -28,20 +31,203 @@ import (
 type BackupStorageProvider interface {
 	// Get underlying Kubernetes object
 	GetObject() *backupoperatoriov1.BackupStorage
-	// Read parameters from backup storage and configure provider.
+	// Read parameters from backup storage and configure provider. It is called once on a new object with
+	// defaults set, every configuration change gets a new object, so operations of the old one are not affected.
 	Constructor(object *backupoperatoriov1.BackupStorage, parameters map[string]string, credentials map[string]string) error
-	// Actions to make before object destruction.
+	// Actions to make before object destruction. It is called when operations of the object are over.
 	Destructor() error
-	// Upload file.
-	Put(ctx context.Context, path string, reader io.Reader) error
+	// Upload file with custom metadata. Providers that can not keep metadata ignore it.
+	Put(ctx context.Context, path string, reader io.Reader, metadata map[string]string) error
 	// Download file.
 	Get(ctx context.Context, path string) (io.ReadCloser, error)
 	// List path.
 	List(ctx context.Context, path string) ([]string, error)
 	// Remove path.
 	Delete(ctx context.Context, path string) error
-	// Get file size in bytes
-	GetSize(ctx context.Context, path string) (uint, error)
+	// Get file size, modification time, ETag and custom metadata
+	Stat(ctx context.Context, path string) (ObjectInfo, error)
+}
+
+// ObjectInfo File attributes returned by Stat
+type ObjectInfo struct {
+	// Size in bytes
+	Size uint
+	// Last modification time
+	ModTime time.Time
+	// ETag or checksum reported by the storage, empty if it has none
+	ETag string
+	// Custom metadata passed to Put, empty if the provider does not keep it
+	Metadata map[string]string
+}
+
+// Metadata keys of backup files
+const (
+	MetadataRunUID          = "run-uid"
+	MetadataRun             = "run"
+	MetadataPath            = "path"
+	MetadataSchedule        = "schedule"
+	MetadataCompression     = "compression"
+	MetadataEncryption      = "encryption"
+	MetadataOperatorVersion = "operator-version"
+	MetadataRawSHA256       = "raw-sha256"
+	MetadataSHA256          = "sha256"
+)
+
+// ErrObjectLocked is returned by providers if the object can not be deleted because of Object Lock
+var ErrObjectLocked = errors.New("object is locked")
+
+// PutOptions Extra upload options, they are supported by some providers only
+type PutOptions struct {
+	// Object Lock retention, nil if no retention is requested
+	Retention *ObjectRetention
+	// Storage class overriding the storage default, empty if not requested
+	StorageClass string
+}
+
+// IsEmpty Check whether no extra option is set
+func (o PutOptions) IsEmpty() bool {
+	return o.Retention == nil && len(o.StorageClass) == 0
+}
+
+// ObjectRetention Object Lock retention of a file
+type ObjectRetention struct {
+	// GOVERNANCE or COMPLIANCE
+	Mode string
+	// File can not be deleted or overwritten until then
+	RetainUntil time.Time
+}
+
+// ObjectLockStatus Current Object Lock state of a file
+type ObjectLockStatus struct {
+	// Retention, nil if there is none
+	Retention *ObjectRetention
+	// Whether legal hold is on
+	LegalHold bool
+}
+
+// Locked Check the file can not be deleted now because of legal hold or retention
+func (s ObjectLockStatus) Locked() bool {
+	return s.LegalHold || (s.Retention != nil && s.Retention.RetainUntil.After(time.Now()))
+}
+
+// OptionsPutter is implemented by providers supporting extra upload options
+type OptionsPutter interface {
+	// Upload file with custom metadata and extra options.
+	PutWithOptions(ctx context.Context, path string, reader io.Reader, metadata map[string]string, options PutOptions) error
+}
+
+// ObjectLocker is implemented by providers supporting Object Lock (WORM) retention and legal hold
+type ObjectLocker interface {
+	// Put or release legal hold.
+	SetLegalHold(ctx context.Context, path string, hold bool) error
+	// Get current retention and legal hold of a file.
+	GetObjectLock(ctx context.Context, path string) (ObjectLockStatus, error)
+}
+
+// Rehydrator is implemented by providers with archive storage classes, where files have to be restored
+// before they can be downloaded, e.g. S3 Glacier
+type Rehydrator interface {
+	// Check whether the file is available for download, and request its restoration from archive if not.
+	// Returns true while the file is not available yet.
+	Rehydrate(ctx context.Context, path string) (pending bool, err error)
+}
+
+// Promoter is implemented by providers that can move a file within the storage without downloading it
+type Promoter interface {
+	// Move file to the new path replacing the existing one. Metadata replaces the one of the file,
+	// since it may be known only after the upload, e.g. checksums. Extra upload options are applied
+	// to the new file, they are set only if the provider is OptionsPutter.
+	Promote(ctx context.Context, from, to string, metadata map[string]string, options PutOptions) error
+}
+
+// Put Upload file with custom metadata and extra options, if any.
+// It fails if options are set, but the provider does not support them.
+func Put(ctx context.Context, provider BackupStorageProvider, path string, reader io.Reader,
+	metadata map[string]string, options PutOptions,
+) error {
+	if options.IsEmpty() {
+		return provider.Put(ctx, path, reader, metadata)
+	}
+	defer Hold(provider)()
+	if err := CheckPutOptions(provider, options); err != nil {
+		return err
+	}
+	putter, _ := As[OptionsPutter](provider)
+	return observeOperation(provider.GetObject().Name, "put", func() error {
+		return putter.PutWithOptions(ctx, path, reader, metadata, options)
+	})
+}
+
+// CheckPutOptions Check the provider supports extra upload options, if any
+func CheckPutOptions(provider BackupStorageProvider, options PutOptions) error {
+	if options.IsEmpty() {
+		return nil
+	}
+	if _, ok := As[OptionsPutter](provider); !ok {
+		return fmt.Errorf("storage %s does not support extra upload options", provider.GetObject().Name)
+	}
+	if _, ok := As[ObjectLocker](provider); options.Retention != nil && !ok {
+		return fmt.Errorf("storage %s does not support Object Lock", provider.GetObject().Name)
+	}
+	return nil
+}
+
+// Promote Move file, e.g. from the staging path to the final one, with new metadata and extra upload options if any.
+// Providers that are not Promoter get the file copied through the operator.
+func Promote(ctx context.Context, provider BackupStorageProvider, from, to string,
+	metadata map[string]string, options PutOptions,
+) error {
+	defer Hold(provider)()
+	if err := CheckPutOptions(provider, options); err != nil {
+		return err
+	}
+	if promoter, ok := As[Promoter](provider); ok {
+		return observeOperation(provider.GetObject().Name, "promote", func() error {
+			return promoter.Promote(ctx, from, to, metadata, options)
+		})
+	}
+	return Copy(ctx, provider, from, to, metadata, options)
+}
+
+// Copy Move file by downloading and uploading it again, e.g. when the provider is not Promoter
+func Copy(ctx context.Context, provider BackupStorageProvider, from, to string,
+	metadata map[string]string, options PutOptions,
+) error {
+	reader, err := provider.Get(ctx, from)
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+	if err = Put(ctx, provider, to, reader, metadata, options); err != nil {
+		return err
+	}
+	return provider.Delete(ctx, from)
+}
+
+// As Get optional interface like ObjectLocker of the provider, looking through its wrappers
+func As[T any](provider BackupStorageProvider) (capability T, ok bool) {
+	for provider != nil {
+		if capability, ok = provider.(T); ok {
+			return
+		}
+		wrapper, isWrapper := provider.(interface{ Unwrap() BackupStorageProvider })
+		if !isWrapper {
+			return
+		}
+		provider = wrapper.Unwrap()
+	}
+	return
+}
+
+// Unwrap Get the provider under all wrappers
+func Unwrap(provider BackupStorageProvider) BackupStorageProvider {
+	for {
+		wrapper, ok := provider.(interface{ Unwrap() BackupStorageProvider })
+		if !ok {
+			return provider
+		}
+		provider = wrapper.Unwrap()
+	}
 }
 
 // All initialized backup storage providers objects
@@ -56,9 +242,33 @@ func GetBackupStorageProvider(name string) (storage BackupStorageProvider, ok bo
 	return
 }
 
-// AddBackupStorageProvider Add backup storage provider by name
+// AddBackupStorageProvider Add backup storage provider by name. Its operations are measured from now on.
 func AddBackupStorageProvider(name string, storage BackupStorageProvider) {
-	backupStorageProviders.Store(name, storage)
+	backupStorageProviders.Store(name, instrument(name, storage))
+}
+
+// ReplaceBackupStorageProvider Add backup storage provider by name instead of the old one, if any.
+// The old provider is destroyed once its running operations are over, onDestroyed gets the Destructor error.
+func ReplaceBackupStorageProvider(name string, storage BackupStorageProvider, onDestroyed func(error)) {
+	old, loaded := backupStorageProviders.Swap(name, instrument(name, storage))
+	if !loaded {
+		return
+	}
+	destroy := func() { onDestroyed(old.(BackupStorageProvider).Destructor()) }
+	if instrumented, ok := old.(*instrumentedProvider); ok {
+		instrumented.retire(destroy)
+	} else {
+		destroy()
+	}
+}
+
+// Hold Keep provider from being destroyed after replacement until the returned function is called.
+// Operations are held while they are running, so it is meant for a sequence of them, e.g. the whole backup.
+func Hold(provider BackupStorageProvider) (release func()) {
+	if instrumented, ok := provider.(*instrumentedProvider); ok {
+		return instrumented.hold()
+	}
+	return func() {}
 }
 
 // RemoveBackupStorageProvider Remove backup storage provider by name
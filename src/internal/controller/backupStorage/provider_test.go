@@ -0,0 +1,121 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package backupstorage
+
+import (
+	"context"
+	"io"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	backupoperatoriov1 "backup-operator.io/api/v1"
+)
+
+// fakeProvider Serves the same content for every path and records destruction
+type fakeProvider struct {
+	object    *backupoperatoriov1.BackupStorage
+	destroyed atomic.Bool
+}
+
+func (f *fakeProvider) GetObject() *backupoperatoriov1.BackupStorage { return f.object }
+func (f *fakeProvider) Constructor(*backupoperatoriov1.BackupStorage, map[string]string, map[string]string) error {
+	return nil
+}
+func (f *fakeProvider) Destructor() error { f.destroyed.Store(true); return nil }
+func (f *fakeProvider) Put(context.Context, string, io.Reader, map[string]string) error {
+	return nil
+}
+func (f *fakeProvider) Get(context.Context, string) (io.ReadCloser, error) {
+	return io.NopCloser(strings.NewReader("backup")), nil
+}
+func (f *fakeProvider) List(context.Context, string) ([]string, error) { return nil, nil }
+func (f *fakeProvider) Delete(context.Context, string) error           { return nil }
+func (f *fakeProvider) Stat(context.Context, string) (ObjectInfo, error) {
+	return ObjectInfo{}, nil
+}
+
+func newFakeProvider(name string) *fakeProvider {
+	return &fakeProvider{object: &backupoperatoriov1.BackupStorage{ObjectMeta: metav1.ObjectMeta{Name: name}}}
+}
+
+// replace Replace provider and return a channel closed on destruction of the old one
+func replace(name string, provider BackupStorageProvider) <-chan struct{} {
+	destroyed := make(chan struct{})
+	ReplaceBackupStorageProvider(name, provider, func(error) { close(destroyed) })
+	return destroyed
+}
+
+func isClosed(c <-chan struct{}) bool {
+	select {
+	case <-c:
+		return true
+	case <-time.After(100 * time.Millisecond):
+		return false
+	}
+}
+
+func TestReplaceIdleProvider(t *testing.T) {
+	const name = "replace-idle"
+	defer RemoveBackupStorageProvider(name)
+	old := newFakeProvider(name)
+	AddBackupStorageProvider(name, old)
+	if !isClosed(replace(name, newFakeProvider(name))) || !old.destroyed.Load() {
+		t.Error("idle provider has not been destroyed after replacement")
+	}
+}
+
+func TestReplaceProviderWithRunningOperations(t *testing.T) {
+	const name = "replace-running"
+	defer RemoveBackupStorageProvider(name)
+	old := newFakeProvider(name)
+	AddBackupStorageProvider(name, old)
+	current, _ := GetBackupStorageProvider(name)
+	// Download and a held backup are running on the old provider
+	reader, err := current.Get(context.Background(), "/backup")
+	if err != nil {
+		t.Fatal(err)
+	}
+	release := Hold(current)
+	next := newFakeProvider(name)
+	destroyed := replace(name, next)
+	if replaced, _ := GetBackupStorageProvider(name); Unwrap(replaced) != next {
+		t.Fatal("provider has not been replaced")
+	}
+	if isClosed(destroyed) {
+		t.Fatal("provider has been destroyed during the download")
+	}
+	if data, _ := io.ReadAll(reader); string(data) != "backup" {
+		t.Errorf("got %q from the old provider", data)
+	}
+	reader.Close()
+	// Closing twice must not release the provider twice
+	reader.Close()
+	if isClosed(destroyed) {
+		t.Fatal("provider has been destroyed while it is held")
+	}
+	release()
+	if !isClosed(destroyed) || !old.destroyed.Load() {
+		t.Error("provider has not been destroyed after its operations")
+	}
+	if next.destroyed.Load() {
+		t.Error("the new provider has been destroyed")
+	}
+}
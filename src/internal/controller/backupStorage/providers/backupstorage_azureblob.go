@@ -0,0 +1,295 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package backupstorageproviders
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/blob"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/bloberror"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/utils/ptr"
+
+	backupoperatoriov1 "backup-operator.io/api/v1"
+	backupstorage "backup-operator.io/internal/controller/backupStorage"
+)
+
+// AzureBlobStorage is an implementation of BackupStorage for Azure Blob Storage.
+type AzureBlobStorage struct {
+	Endpoint    string
+	AccountName string
+	Container   string
+	// Size of a block for streaming uploads, each concurrent upload buffers one block
+	BlockSize   int64 `default:"8388608"`
+	Concurrency int   `default:"4"`
+
+	client *azblob.Client
+	// Underlying Kubernetes object
+	object *backupoperatoriov1.BackupStorage
+}
+
+func init() {
+	backupstorage.RegisterProvider(backupstorage.ProviderRegistration{
+		Type: "azureblob",
+		Name: "Azure Blob Storage",
+		Parameters: []backupstorage.ProviderParameter{
+			{Name: "accountName", Description: "Storage account name", Required: true},
+			{Name: "container", Description: "Name of the container", Required: true},
+			{Name: "endpoint", Description: "Custom service URL, defaults to https://<accountName>.blob.core.windows.net/"},
+			{Name: "blockSize", Description: "Size of a block for uploads, defaults to 8Mi"},
+			{Name: "concurrency", Description: "Count of blocks uploaded in parallel, defaults to 4"},
+			{Name: "accountKey", Description: "Key in credentials secret with shared key, defaults to AZURE_STORAGE_KEY"},
+			{Name: "sasToken", Description: "Key in credentials secret with SAS token, defaults to AZURE_STORAGE_SAS_TOKEN"},
+		},
+		Factory: func() backupstorage.BackupStorageProvider { return &AzureBlobStorage{} },
+	})
+}
+
+// Constructor Configure Azure Blob Storage
+func (a *AzureBlobStorage) Constructor(object *backupoperatoriov1.BackupStorage, parameters map[string]string, creds map[string]string) (err error) {
+	// Set object field
+	if object == nil {
+		return errors.New("object is mandatory but nil")
+	}
+	a.object = object
+	// Check parameters have all mandatory keys
+	for _, key := range []string{"accountName", "container"} {
+		if _, ok := parameters[key]; !ok {
+			return fmt.Errorf("mandatory %s parameter is not defined", key)
+		}
+	}
+	if err = a.parseParameters(parameters); err != nil {
+		return
+	}
+	// Try to load shared key or SAS token from credentials
+	keyName := func(parameter, fallback string) string {
+		if name, ok := parameters[parameter]; ok {
+			return name
+		}
+		return fallback
+	}
+	if accountKey, ok := creds[keyName("accountKey", "AZURE_STORAGE_KEY")]; ok {
+		var credential *azblob.SharedKeyCredential
+		if credential, err = azblob.NewSharedKeyCredential(a.AccountName, accountKey); err != nil {
+			return fmt.Errorf("failed to create shared key credential: %s", err)
+		}
+		if a.client, err = azblob.NewClientWithSharedKeyCredential(a.Endpoint, credential, nil); err != nil {
+			return fmt.Errorf("failed to create Azure Blob client: %s", err)
+		}
+	} else if sasToken, ok := creds[keyName("sasToken", "AZURE_STORAGE_SAS_TOKEN")]; ok {
+		serviceURL := a.Endpoint + "?" + strings.TrimPrefix(sasToken, "?")
+		if a.client, err = azblob.NewClientWithNoCredential(serviceURL, nil); err != nil {
+			return fmt.Errorf("failed to create Azure Blob client: %s", err)
+		}
+	} else {
+		return errors.New("neither shared key nor SAS token is found in credentials")
+	}
+	// Check connection
+	pager := a.client.NewListBlobsFlatPager(a.Container, &azblob.ListBlobsFlatOptions{
+		MaxResults: ptr.To[int32](1),
+	})
+	if _, err = pager.NextPage(context.Background()); err != nil {
+		return fmt.Errorf("failed to test Azure Blob connection: %s", err)
+	}
+	return
+}
+
+// parseParameters Read connection and upload parameters, the endpoint defaults to the public one of the account
+func (a *AzureBlobStorage) parseParameters(parameters map[string]string) (err error) {
+	for key, value := range parameters {
+		switch key {
+		case "endpoint":
+			// Azurite endpoint looks like http://azurite:10000/devstoreaccount1
+			var u *url.URL
+			if u, err = url.Parse(value); err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+				return fmt.Errorf("endpoint must be a valid http(s) URL: %s", value)
+			}
+			a.Endpoint = strings.TrimSuffix(value, "/") + "/"
+		case "accountName":
+			a.AccountName = value
+		case "container":
+			a.Container = value
+		case "blockSize":
+			var q resource.Quantity
+			if q, err = resource.ParseQuantity(value); err != nil {
+				return fmt.Errorf("failed to parse block size: %s", err)
+			}
+			a.BlockSize = q.Value()
+		case "concurrency":
+			if a.Concurrency, err = strconv.Atoi(value); err != nil {
+				return fmt.Errorf("failed to parse concurrency: %s", err)
+			}
+		}
+	}
+	if a.BlockSize < 1<<20 {
+		return errors.New("block size must be at least 1Mi")
+	}
+	if a.Concurrency < 1 {
+		return errors.New("concurrency must be positive")
+	}
+	if len(a.Endpoint) == 0 {
+		a.Endpoint = fmt.Sprintf("https://%s.blob.core.windows.net/", a.AccountName)
+	}
+	return
+}
+
+// Destructor Azure Blob storage object destructor.
+func (a *AzureBlobStorage) Destructor() error {
+	return nil
+}
+
+// Put Upload file. Data is streamed with block blobs, so only Concurrency blocks are buffered at once.
+// Metadata is kept as blob metadata, dashes in keys are replaced with underscores since keys must be C# identifiers.
+func (a *AzureBlobStorage) Put(ctx context.Context, path string, reader io.Reader, metadata map[string]string) error {
+	options := &azblob.UploadStreamOptions{
+		BlockSize:   a.BlockSize,
+		Concurrency: a.Concurrency,
+	}
+	options.Metadata = azureMetadata(metadata)
+	_, err := a.client.UploadStream(ctx, a.Container, blobName(path), reader, options)
+	return err
+}
+
+// Get Download file.
+func (a *AzureBlobStorage) Get(ctx context.Context, path string) (io.ReadCloser, error) {
+	response, err := a.client.DownloadStream(ctx, a.Container, blobName(path), nil)
+	if err != nil {
+		return nil, err
+	}
+	// Retry reader resumes the download on transient network failures
+	return response.NewRetryReader(ctx, &azblob.RetryReaderOptions{}), nil
+}
+
+// List path.
+func (a *AzureBlobStorage) List(ctx context.Context, path string) (list []string, err error) {
+	pager := a.client.NewListBlobsFlatPager(a.Container, &azblob.ListBlobsFlatOptions{
+		Prefix: ptr.To(blobName(path)),
+	})
+	for pager.More() {
+		var page azblob.ListBlobsFlatResponse
+		if page, err = pager.NextPage(ctx); err != nil {
+			return
+		}
+		for _, item := range page.Segment.BlobItems {
+			list = append(list, "/"+*item.Name)
+		}
+	}
+	return
+}
+
+// Delete Remove path
+func (a *AzureBlobStorage) Delete(ctx context.Context, path string) error {
+	_, err := a.client.DeleteBlob(ctx, a.Container, blobName(path), nil)
+	if bloberror.HasCode(err, bloberror.BlobNotFound) {
+		return nil
+	}
+	return err
+}
+
+// Interval of copy status checks while the service copies the blob
+const azureCopyPollInterval = 5 * time.Second
+
+// Promote Copy blob to the new name server-side with new metadata and delete the source.
+// Copy within the account is authorized with the same credentials, but it is asynchronous,
+// so its status is polled until it is done.
+func (a *AzureBlobStorage) Promote(ctx context.Context, from, to string,
+	metadata map[string]string, _ backupstorage.PutOptions,
+) (err error) {
+	container := a.client.ServiceClient().NewContainerClient(a.Container)
+	source, target := container.NewBlobClient(blobName(from)), container.NewBlobClient(blobName(to))
+	var started blob.StartCopyFromURLResponse
+	if started, err = target.StartCopyFromURL(ctx, source.URL(), &blob.StartCopyFromURLOptions{
+		Metadata: azureMetadata(metadata),
+	}); err != nil {
+		return
+	}
+	status, description := ptr.Deref(started.CopyStatus, blob.CopyStatusTypePending), ""
+	for status == blob.CopyStatusTypePending {
+		select {
+		case <-ctx.Done():
+			// Copy goes on without the operator, so it is aborted
+			target.AbortCopyFromURL(context.WithoutCancel(ctx), ptr.Deref(started.CopyID, ""), nil)
+			return ctx.Err()
+		case <-time.After(azureCopyPollInterval):
+		}
+		var properties blob.GetPropertiesResponse
+		if properties, err = target.GetProperties(ctx, nil); err != nil {
+			return
+		}
+		status = ptr.Deref(properties.CopyStatus, blob.CopyStatusTypePending)
+		description = ptr.Deref(properties.CopyStatusDescription, "")
+	}
+	if status != blob.CopyStatusTypeSuccess {
+		return fmt.Errorf("copy has %s: %s", status, description)
+	}
+	return a.Delete(ctx, from)
+}
+
+// Get underlying Kubernetes object
+func (a *AzureBlobStorage) GetObject() *backupoperatoriov1.BackupStorage {
+	return a.object
+}
+
+// Stat Get blob properties and metadata
+func (a *AzureBlobStorage) Stat(ctx context.Context, path string) (info backupstorage.ObjectInfo, err error) {
+	var properties blob.GetPropertiesResponse
+	if properties, err = a.client.ServiceClient().NewContainerClient(a.Container).
+		NewBlobClient(blobName(path)).GetProperties(ctx, nil); err != nil {
+		return
+	}
+	if properties.ContentLength != nil && *properties.ContentLength > 0 {
+		info.Size = uint(*properties.ContentLength)
+	}
+	if properties.LastModified != nil {
+		info.ModTime = *properties.LastModified
+	}
+	if properties.ETag != nil {
+		info.ETag = strings.Trim(string(*properties.ETag), `"`)
+	}
+	// Service may return keys in other case than they have been set
+	info.Metadata = make(map[string]string, len(properties.Metadata))
+	for key, value := range properties.Metadata {
+		info.Metadata[strings.ReplaceAll(strings.ToLower(key), "_", "-")] = ptr.Deref(value, "")
+	}
+	return
+}
+
+// azureMetadata converts metadata to blob metadata, keys must be C# identifiers, so dashes are replaced
+func azureMetadata(metadata map[string]string) map[string]*string {
+	if len(metadata) == 0 {
+		return nil
+	}
+	converted := make(map[string]*string, len(metadata))
+	for key, value := range metadata {
+		converted[strings.ReplaceAll(key, "-", "_")] = ptr.To(value)
+	}
+	return converted
+}
+
+// blobName converts backup path to the blob name.
+// Blob names must not start with slash, while backup paths always do.
+func blobName(path string) string {
+	return strings.TrimPrefix(path, "/")
+}
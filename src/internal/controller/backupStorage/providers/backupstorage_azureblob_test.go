@@ -0,0 +1,162 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package backupstorageproviders
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob"
+	"github.com/creasty/defaults"
+
+	backupstorage "backup-operator.io/internal/controller/backupStorage"
+)
+
+// Well-known account of Azurite emulator
+const (
+	azuriteAccountName = "devstoreaccount1"
+	azuriteAccountKey  = "Eby8vdM02xNOcqFlqUwJPLlmEtlCDXJ1OUzFT50uSRZ6IFsuFq2UVErCz4I6tq/K1SZFPTOtr/KBHBeksoGMGw=="
+)
+
+func TestAzureBlobParameters(t *testing.T) {
+	for _, c := range []struct {
+		parameters  map[string]string
+		endpoint    string
+		blockSize   int64
+		concurrency int
+	}{
+		{
+			map[string]string{"accountName": "first", "container": "backups"},
+			"https://first.blob.core.windows.net/", 8 << 20, 4,
+		},
+		{
+			map[string]string{"accountName": "first", "container": "backups", "endpoint": "http://azurite:10000/devstoreaccount1",
+				"blockSize": "4Mi", "concurrency": "8"},
+			"http://azurite:10000/devstoreaccount1/", 4 << 20, 8,
+		},
+		// Every configuration starts from defaults, so removed parameters and the old account are not kept
+		{
+			map[string]string{"accountName": "second", "container": "backups"},
+			"https://second.blob.core.windows.net/", 8 << 20, 4,
+		},
+	} {
+		a := &AzureBlobStorage{}
+		if err := defaults.Set(a); err != nil {
+			t.Fatal(err)
+		}
+		if err := a.parseParameters(c.parameters); err != nil {
+			t.Errorf("%v: failed to parse parameters: %s", c.parameters, err)
+			continue
+		}
+		if a.Endpoint != c.endpoint || a.BlockSize != c.blockSize || a.Concurrency != c.concurrency {
+			t.Errorf("%v: got endpoint %s, block size %d and concurrency %d", c.parameters, a.Endpoint, a.BlockSize, a.Concurrency)
+		}
+	}
+}
+
+func TestAzureBlobInvalidConfiguration(t *testing.T) {
+	for _, parameters := range []map[string]string{
+		{"container": "backups"},
+		{"accountName": "account"},
+		{"accountName": "account", "container": "backups", "endpoint": "ftp://azurite"},
+		{"accountName": "account", "container": "backups", "blockSize": "512Ki"},
+		{"accountName": "account", "container": "backups", "concurrency": "0"},
+		// No credentials
+		{"accountName": "account", "container": "backups"},
+	} {
+		if provider, err := configureProvider("azureblob", "azureblob", parameters, nil); err == nil {
+			provider.Destructor()
+			t.Errorf("%v: provider has been configured", parameters)
+		}
+	}
+}
+
+// TestAzureBlobAzurite Run the provider against Azurite, e.g. started with
+// docker run -p 10000:10000 mcr.microsoft.com/azure-storage/azurite azurite-blob --blobHost 0.0.0.0 --loose
+// and AZURITE_ENDPOINT=http://127.0.0.1:10000/devstoreaccount1
+func TestAzureBlobAzurite(t *testing.T) {
+	endpoint := os.Getenv("AZURITE_ENDPOINT")
+	if len(endpoint) == 0 {
+		t.Skip("AZURITE_ENDPOINT is not set")
+	}
+	ctx := context.Background()
+	// Container has to be created beforehand
+	credential, err := azblob.NewSharedKeyCredential(azuriteAccountName, azuriteAccountKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client, err := azblob.NewClientWithSharedKeyCredential(endpoint, credential, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	container := fmt.Sprintf("test-%d", time.Now().UnixNano())
+	if _, err = client.CreateContainer(ctx, container, nil); err != nil {
+		t.Fatalf("failed to create container: %s", err)
+	}
+	defer client.DeleteContainer(ctx, container, nil)
+
+	a := newConfiguredProvider(t, "azureblob", "azureblob", map[string]string{
+		"endpoint": endpoint, "accountName": azuriteAccountName, "container": container, "blockSize": "1Mi",
+	}, map[string]string{"AZURE_STORAGE_KEY": azuriteAccountKey}).(*AzureBlobStorage)
+	defer a.Destructor()
+	// Several blocks are uploaded
+	data := bytes.Repeat([]byte("backup-operator"), 300000)
+	metadata := map[string]string{backupstorage.MetadataRun: "default/mysql", backupstorage.MetadataRunUID: "uid"}
+	if err = a.Put(ctx, "/mysql/backup.sql", bytes.NewReader(data), metadata); err != nil {
+		t.Fatalf("failed to put: %s", err)
+	}
+	reader, err := a.Get(ctx, "/mysql/backup.sql")
+	if err != nil {
+		t.Fatalf("failed to get: %s", err)
+	}
+	read, err := io.ReadAll(reader)
+	reader.Close()
+	if err != nil || !bytes.Equal(read, data) {
+		t.Errorf("downloaded file differs from the uploaded one, error %v", err)
+	}
+	info, err := a.Stat(ctx, "/mysql/backup.sql")
+	if err != nil || info.Size != uint(len(data)) || info.Metadata[backupstorage.MetadataRunUID] != "uid" ||
+		info.Metadata[backupstorage.MetadataRun] != "default/mysql" {
+		t.Errorf("got %+v and error %v", info, err)
+	}
+	if err = a.Promote(ctx, "/mysql/backup.sql", "/mysql/promoted.sql", map[string]string{backupstorage.MetadataSHA256: "sum"},
+		backupstorage.PutOptions{}); err != nil {
+		t.Fatalf("failed to promote: %s", err)
+	}
+	if info, err = a.Stat(ctx, "/mysql/promoted.sql"); err != nil || info.Metadata[backupstorage.MetadataSHA256] != "sum" {
+		t.Errorf("got %+v and error %v after promotion", info, err)
+	}
+	if list, err := a.List(ctx, "/mysql/"); err != nil || !slices.Equal(list, []string{"/mysql/promoted.sql"}) {
+		t.Errorf("got list %v and error %v", list, err)
+	}
+	if err = a.Delete(ctx, "/mysql/promoted.sql"); err != nil {
+		t.Fatalf("failed to delete: %s", err)
+	}
+	// Removing missing file is not an error
+	if err = a.Delete(ctx, "/mysql/promoted.sql"); err != nil {
+		t.Errorf("failed to delete missing file: %s", err)
+	}
+	if list, err := a.List(ctx, "/"); err != nil || len(list) != 0 {
+		t.Errorf("got list %v and error %v after deletion", list, err)
+	}
+}
@@ -0,0 +1,202 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package backupstorageproviders
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	backupoperatoriov1 "backup-operator.io/api/v1"
+	backupstorage "backup-operator.io/internal/controller/backupStorage"
+	"backup-operator.io/internal/controller/utils"
+)
+
+// FilesystemStorage is an implementation of BackupStorage for a directory mounted into the operator,
+// e.g. NFS-backed PersistentVolumeClaim or hostPath.
+type FilesystemStorage struct {
+	// Root directory where all backups are stored
+	Path string
+
+	// Underlying Kubernetes object
+	object *backupoperatoriov1.BackupStorage
+}
+
+func init() {
+	backupstorage.RegisterProvider(backupstorage.ProviderRegistration{
+		Type: "filesystem",
+		Name: "Filesystem",
+		Parameters: []backupstorage.ProviderParameter{
+			{Name: "path", Description: "Absolute path to the mounted directory", Required: true},
+		},
+		Factory: func() backupstorage.BackupStorageProvider { return &FilesystemStorage{} },
+	})
+}
+
+// Constructor Configure filesystem storage
+func (f *FilesystemStorage) Constructor(object *backupoperatoriov1.BackupStorage, parameters map[string]string, _ map[string]string) (err error) {
+	// Set object field
+	if object == nil {
+		return errors.New("object is mandatory but nil")
+	}
+	f.object = object
+	// Check parameters have all mandatory keys
+	var ok bool
+	if f.Path, ok = parameters["path"]; !ok {
+		return errors.New("mandatory path parameter is not defined")
+	}
+	if !filepath.IsAbs(f.Path) {
+		return fmt.Errorf("path must be absolute: %s", f.Path)
+	}
+	f.Path = filepath.Clean(f.Path)
+	// Check that directory exists...
+	var info fs.FileInfo
+	if info, err = os.Stat(f.Path); err != nil {
+		return fmt.Errorf("failed to stat the directory: %s", err)
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("path is not a directory: %s", f.Path)
+	}
+	// ...and is writable
+	var probe *os.File
+	if probe, err = os.CreateTemp(f.Path, ".backup-operator-probe-*"); err != nil {
+		return fmt.Errorf("failed to test directory is writable: %s", err)
+	}
+	probe.Close()
+	if err = os.Remove(probe.Name()); err != nil {
+		return fmt.Errorf("failed to remove probe file: %s", err)
+	}
+	return
+}
+
+// Destructor Filesystem storage object destructor.
+func (f *FilesystemStorage) Destructor() error {
+	return nil
+}
+
+// Put Upload file. Metadata is not kept.
+func (f *FilesystemStorage) Put(ctx context.Context, path string, reader io.Reader, _ map[string]string) (err error) {
+	fullPath := f.fullPath(path)
+	if err = os.MkdirAll(filepath.Dir(fullPath), 0o750); err != nil {
+		return
+	}
+	var file *os.File
+	if file, err = os.OpenFile(fullPath, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0o640); err != nil {
+		return
+	}
+	if err = utils.PipeCopy(ctx, reader, file); err != nil {
+		// Do not leave truncated files behind
+		file.Close()
+		os.Remove(fullPath)
+		return
+	}
+	return file.Close()
+}
+
+// Get Download file.
+func (f *FilesystemStorage) Get(_ context.Context, path string) (io.ReadCloser, error) {
+	return os.Open(f.fullPath(path))
+}
+
+// List path. Only the directory of the path is walked, missing one has no files.
+func (f *FilesystemStorage) List(_ context.Context, path string) (list []string, err error) {
+	root := f.fullPath(path)
+	if !strings.HasSuffix(path, "/") && root != f.Path {
+		root = filepath.Dir(root)
+	}
+	err = filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+		if errors.Is(err, fs.ErrNotExist) && p == root {
+			return fs.SkipAll
+		}
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+		// Keys are slash separated and start with slash like in run .spec.storage.path
+		var rel string
+		if rel, err = filepath.Rel(f.Path, p); err != nil {
+			return err
+		}
+		key := "/" + filepath.ToSlash(rel)
+		if strings.HasPrefix(key, path) {
+			list = append(list, key)
+		}
+		return nil
+	})
+	return
+}
+
+// Delete Remove path
+func (f *FilesystemStorage) Delete(_ context.Context, path string) (err error) {
+	fullPath := f.fullPath(path)
+	if err = os.Remove(fullPath); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return
+	}
+	f.removeEmptyParents(fullPath)
+	return nil
+}
+
+// Promote Rename file, which replaces the existing one atomically. Metadata is not kept.
+func (f *FilesystemStorage) Promote(_ context.Context, from, to string, _ map[string]string, _ backupstorage.PutOptions) (err error) {
+	fromPath, toPath := f.fullPath(from), f.fullPath(to)
+	if err = os.MkdirAll(filepath.Dir(toPath), 0o750); err != nil {
+		return
+	}
+	if err = os.Rename(fromPath, toPath); err != nil {
+		return
+	}
+	f.removeEmptyParents(fromPath)
+	return nil
+}
+
+// removeEmptyParents Clean up empty parent directories, but never the root one
+func (f *FilesystemStorage) removeEmptyParents(fullPath string) {
+	for dir := filepath.Dir(fullPath); dir != f.Path && strings.HasPrefix(dir, f.Path); dir = filepath.Dir(dir) {
+		if os.Remove(dir) != nil {
+			break
+		}
+	}
+}
+
+// Get underlying Kubernetes object
+func (f *FilesystemStorage) GetObject() *backupoperatoriov1.BackupStorage {
+	return f.object
+}
+
+// Stat Get file size and modification time
+func (f *FilesystemStorage) Stat(_ context.Context, path string) (info backupstorage.ObjectInfo, err error) {
+	var fileInfo fs.FileInfo
+	if fileInfo, err = os.Stat(f.fullPath(path)); err != nil {
+		return
+	}
+	info.Size = uint(fileInfo.Size())
+	info.ModTime = fileInfo.ModTime()
+	return
+}
+
+// fullPath converts backup path to the path on filesystem.
+// Path is cleaned as an absolute one first, so it never escapes the root directory.
+func (f *FilesystemStorage) fullPath(path string) string {
+	return filepath.Join(f.Path, filepath.FromSlash(filepath.Clean("/"+path)))
+}
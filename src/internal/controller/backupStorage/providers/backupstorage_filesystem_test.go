@@ -0,0 +1,218 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package backupstorageproviders
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	backupstorage "backup-operator.io/internal/controller/backupStorage"
+)
+
+// newFilesystemStorage Configure filesystem storage in a temporary directory
+func newFilesystemStorage(t *testing.T) (*FilesystemStorage, string) {
+	t.Helper()
+	dir := t.TempDir()
+	return newConfiguredProvider(t, "filesystem", "filesystem", map[string]string{"path": dir}, nil).(*FilesystemStorage), dir
+}
+
+// putFiles Put files with their paths as content
+func putFiles(t *testing.T, f *FilesystemStorage, paths ...string) {
+	t.Helper()
+	for _, path := range paths {
+		if err := f.Put(context.Background(), path, bytes.NewReader([]byte(path)), nil); err != nil {
+			t.Fatalf("failed to put %s: %s", path, err)
+		}
+	}
+}
+
+func TestFilesystemRoundTrip(t *testing.T) {
+	ctx := context.Background()
+	for _, c := range []struct {
+		name string
+		path string
+		data []byte
+	}{
+		{"file", "/mysql/backup.sql", []byte("backup")},
+		{"empty file", "/mysql/empty.sql", nil},
+		{"nested directories", "/mysql/2024/01/01/backup.sql.gz", bytes.Repeat([]byte{0x1f, 0x8b}, 1<<16)},
+		{"root directory", "/backup.sql", []byte("backup")},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			f, dir := newFilesystemStorage(t)
+			if err := f.Put(ctx, c.path, bytes.NewReader(c.data), map[string]string{"run": "mysql"}); err != nil {
+				t.Fatalf("failed to put: %s", err)
+			}
+			if written, err := os.ReadFile(filepath.Join(dir, filepath.FromSlash(c.path))); err != nil || !bytes.Equal(written, c.data) {
+				t.Errorf("file on filesystem differs from the uploaded one, error %v", err)
+			}
+			reader, err := f.Get(ctx, c.path)
+			if err != nil {
+				t.Fatalf("failed to get: %s", err)
+			}
+			read, err := io.ReadAll(reader)
+			reader.Close()
+			if err != nil || !bytes.Equal(read, c.data) {
+				t.Errorf("downloaded file differs from the uploaded one, error %v", err)
+			}
+			info, err := f.Stat(ctx, c.path)
+			if err != nil || info.Size != uint(len(c.data)) || info.ModTime.IsZero() || len(info.Metadata) > 0 {
+				t.Errorf("got %+v and error %v, expected size %d without metadata", info, err, len(c.data))
+			}
+			if err = f.Delete(ctx, c.path); err != nil {
+				t.Fatalf("failed to delete: %s", err)
+			}
+			// Removing missing file is not an error
+			if err = f.Delete(ctx, c.path); err != nil {
+				t.Errorf("failed to delete missing file: %s", err)
+			}
+			if _, err = f.Stat(ctx, c.path); !errors.Is(err, fs.ErrNotExist) {
+				t.Errorf("got %v for deleted file", err)
+			}
+			// Empty parent directories are removed, the root one is kept
+			if entries, err := os.ReadDir(dir); err != nil || len(entries) > 0 {
+				t.Errorf("got %d entries and error %v in the root directory", len(entries), err)
+			}
+		})
+	}
+}
+
+func TestFilesystemPathEscaping(t *testing.T) {
+	ctx := context.Background()
+	for _, c := range []struct {
+		path     string
+		expected string
+	}{
+		{"/mysql/backup.sql", "mysql/backup.sql"},
+		{"mysql/backup.sql", "mysql/backup.sql"},
+		{"../backup.sql", "backup.sql"},
+		{"/../../backup.sql", "backup.sql"},
+		{"/mysql/../../postgres/backup.sql", "postgres/backup.sql"},
+		{"//mysql/./backup.sql", "mysql/backup.sql"},
+	} {
+		t.Run(c.path, func(t *testing.T) {
+			parent := t.TempDir()
+			dir := filepath.Join(parent, "storage")
+			if err := os.Mkdir(dir, 0o750); err != nil {
+				t.Fatal(err)
+			}
+			f := newConfiguredProvider(t, "filesystem", "filesystem", map[string]string{"path": dir}, nil).(*FilesystemStorage)
+			if err := f.Put(ctx, c.path, bytes.NewReader([]byte("backup")), nil); err != nil {
+				t.Fatalf("failed to put: %s", err)
+			}
+			if _, err := os.Stat(filepath.Join(dir, filepath.FromSlash(c.expected))); err != nil {
+				t.Errorf("file is not written to %s: %s", c.expected, err)
+			}
+			// Nothing is written outside of the root directory
+			if entries, err := os.ReadDir(parent); err != nil || len(entries) != 1 {
+				t.Errorf("got %d entries and error %v next to the root directory", len(entries), err)
+			}
+		})
+	}
+}
+
+func TestFilesystemList(t *testing.T) {
+	f, _ := newFilesystemStorage(t)
+	putFiles(t, f, "/mysql/a.sql", "/mysql/b.sql", "/mysql/2024/c.sql", "/mysql-old/d.sql", "/postgres/e.sql")
+	for _, c := range []struct {
+		prefix   string
+		expected []string
+	}{
+		{"/", []string{"/mysql-old/d.sql", "/mysql/2024/c.sql", "/mysql/a.sql", "/mysql/b.sql", "/postgres/e.sql"}},
+		{"/mysql/", []string{"/mysql/2024/c.sql", "/mysql/a.sql", "/mysql/b.sql"}},
+		{"/mysql", []string{"/mysql-old/d.sql", "/mysql/2024/c.sql", "/mysql/a.sql", "/mysql/b.sql"}},
+		{"/mysql/a", []string{"/mysql/a.sql"}},
+		{"/mysql/2024/", []string{"/mysql/2024/c.sql"}},
+		{"/missing/", nil},
+		{"/missing/file", nil},
+	} {
+		t.Run(c.prefix, func(t *testing.T) {
+			list, err := f.List(context.Background(), c.prefix)
+			slices.Sort(list)
+			if err != nil || !slices.Equal(list, c.expected) {
+				t.Errorf("got list %v and error %v, expected %v", list, err, c.expected)
+			}
+		})
+	}
+}
+
+func TestFilesystemPromote(t *testing.T) {
+	ctx := context.Background()
+	for _, c := range []struct {
+		name     string
+		existing []string
+		from, to string
+		expected []string
+	}{
+		{"new path", nil, "/.backup-operator/staging/uid", "/mysql/backup.sql", []string{"/mysql/backup.sql"}},
+		{"existing file", []string{"/mysql/backup.sql"}, "/.backup-operator/staging/uid", "/mysql/backup.sql", []string{"/mysql/backup.sql"}},
+		{"other staging files", []string{"/.backup-operator/staging/other"}, "/.backup-operator/staging/uid", "/backup.sql",
+			[]string{"/.backup-operator/staging/other", "/backup.sql"}},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			f, dir := newFilesystemStorage(t)
+			putFiles(t, f, c.existing...)
+			putFiles(t, f, c.from)
+			if err := f.Promote(ctx, c.from, c.to, nil, backupstorage.PutOptions{}); err != nil {
+				t.Fatalf("failed to promote: %s", err)
+			}
+			// Content of the staging file replaces the existing one
+			if content, err := os.ReadFile(filepath.Join(dir, filepath.FromSlash(c.to))); err != nil || string(content) != c.from {
+				t.Errorf("got content %q and error %v", content, err)
+			}
+			list, err := f.List(ctx, "/")
+			slices.Sort(list)
+			if err != nil || !slices.Equal(list, c.expected) {
+				t.Errorf("got list %v and error %v, expected %v", list, err, c.expected)
+			}
+			// Empty staging directory is removed
+			if _, err = os.Stat(filepath.Join(dir, ".backup-operator")); len(c.expected) == 1 && !errors.Is(err, fs.ErrNotExist) {
+				t.Errorf("staging directory is left behind: %v", err)
+			}
+		})
+	}
+	// Missing staging file is an error
+	f, _ := newFilesystemStorage(t)
+	if err := f.Promote(ctx, "/.backup-operator/staging/missing", "/backup.sql", nil, backupstorage.PutOptions{}); err == nil {
+		t.Error("missing file has been promoted")
+	}
+}
+
+func TestFilesystemInvalidConfiguration(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "file")
+	if err := os.WriteFile(file, nil, 0o640); err != nil {
+		t.Fatal(err)
+	}
+	for _, parameters := range []map[string]string{
+		{},
+		{"path": "relative"},
+		{"path": filepath.Join(dir, "missing")},
+		{"path": file},
+	} {
+		if _, err := configureProvider("filesystem", "filesystem", parameters, nil); err == nil {
+			t.Errorf("%v: provider has been configured", parameters)
+		}
+	}
+}
@@ -0,0 +1,212 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package backupstorageproviders
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+
+	"cloud.google.com/go/storage"
+	"google.golang.org/api/iterator"
+	"google.golang.org/api/option"
+	"k8s.io/apimachinery/pkg/api/resource"
+
+	backupoperatoriov1 "backup-operator.io/api/v1"
+	backupstorage "backup-operator.io/internal/controller/backupStorage"
+	"backup-operator.io/internal/controller/utils"
+)
+
+// GCSStorage is an implementation of BackupStorage for Google Cloud Storage.
+type GCSStorage struct {
+	Endpoint string
+	Bucket   string
+	// Size of a chunk for resumable uploads, each upload buffers one chunk
+	ChunkSize int `default:"16777216"`
+
+	client *storage.Client
+	// Underlying Kubernetes object
+	object *backupoperatoriov1.BackupStorage
+}
+
+func init() {
+	backupstorage.RegisterProvider(backupstorage.ProviderRegistration{
+		Type: "gcs",
+		Name: "Google Cloud Storage",
+		Parameters: []backupstorage.ProviderParameter{
+			{Name: "bucket", Description: "Name of the bucket", Required: true},
+			{Name: "endpoint", Description: "Custom JSON API endpoint, e.g. for emulators"},
+			{Name: "chunkSize", Description: "Size of a resumable upload chunk, defaults to 16Mi"},
+			{Name: "serviceAccountKey", Description: "Key in credentials secret with service account JSON key, defaults to GOOGLE_SERVICE_ACCOUNT_KEY"},
+		},
+		Factory: func() backupstorage.BackupStorageProvider { return &GCSStorage{} },
+	})
+}
+
+// Constructor Configure Google Cloud Storage
+func (g *GCSStorage) Constructor(object *backupoperatoriov1.BackupStorage, parameters map[string]string, creds map[string]string) (err error) {
+	// Set object field
+	if object == nil {
+		return errors.New("object is mandatory but nil")
+	}
+	g.object = object
+	// Check parameters have all mandatory keys
+	if _, ok := parameters["bucket"]; !ok {
+		return errors.New("mandatory bucket parameter is not defined")
+	}
+	// Parse parameters
+	for key, value := range parameters {
+		switch key {
+		case "endpoint":
+			// fake-gcs-server endpoint looks like http://fake-gcs-server:4443/storage/v1/
+			var u *url.URL
+			if u, err = url.Parse(value); err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+				return fmt.Errorf("endpoint must be a valid http(s) URL: %s", value)
+			}
+			g.Endpoint = value
+		case "bucket":
+			g.Bucket = value
+		case "chunkSize":
+			var q resource.Quantity
+			if q, err = resource.ParseQuantity(value); err != nil {
+				return fmt.Errorf("failed to parse chunk size: %s", err)
+			}
+			g.ChunkSize = int(q.Value())
+		}
+	}
+	if g.ChunkSize < 256<<10 {
+		return errors.New("chunk size must be at least 256Ki to keep uploads resumable")
+	}
+	// Prepare client options
+	var options []option.ClientOption
+	if len(g.Endpoint) > 0 {
+		// Emulators usually implement JSON API only
+		options = append(options, option.WithEndpoint(g.Endpoint), storage.WithJSONReads())
+	}
+	keyName := "GOOGLE_SERVICE_ACCOUNT_KEY"
+	if name, ok := parameters["serviceAccountKey"]; ok {
+		keyName = name
+	}
+	switch key, ok := creds[keyName]; {
+	case ok:
+		options = append(options, option.WithCredentialsJSON([]byte(key)))
+	case len(g.Endpoint) > 0:
+		// Emulators do not need any authentication
+		options = append(options, option.WithoutAuthentication())
+	default:
+		// Application default credentials, e.g. GKE Workload Identity
+	}
+	// Every configuration gets a new object, so the client of the previous one is left to its running calls
+	if g.client, err = storage.NewClient(context.Background(), options...); err != nil {
+		return fmt.Errorf("failed to create GCS client: %s", err)
+	}
+	// Check connection
+	if _, err = g.client.Bucket(g.Bucket).Objects(context.Background(), nil).Next(); err != nil && err != iterator.Done {
+		return fmt.Errorf("failed to test GCS connection: %s", err)
+	}
+	return nil
+}
+
+// Destructor GCS storage object destructor.
+func (g *GCSStorage) Destructor() error {
+	if g.client != nil {
+		return g.client.Close()
+	}
+	return nil
+}
+
+// Put Upload file. Data is sent with resumable upload in ChunkSize chunks. Metadata is kept as object metadata.
+func (g *GCSStorage) Put(ctx context.Context, path string, reader io.Reader, metadata map[string]string) (err error) {
+	// Object is finalized on Close, so context cancellation is the only way to abort the upload
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	writer := g.client.Bucket(g.Bucket).Object(blobName(path)).NewWriter(ctx)
+	writer.ChunkSize = g.ChunkSize
+	writer.Metadata = metadata
+	if err = utils.PipeCopy(ctx, reader, writer); err != nil {
+		cancel()
+		writer.Close()
+		return
+	}
+	return writer.Close()
+}
+
+// Get Download file.
+func (g *GCSStorage) Get(ctx context.Context, path string) (io.ReadCloser, error) {
+	return g.client.Bucket(g.Bucket).Object(blobName(path)).NewReader(ctx)
+}
+
+// List path.
+func (g *GCSStorage) List(ctx context.Context, path string) (list []string, err error) {
+	objects := g.client.Bucket(g.Bucket).Objects(ctx, &storage.Query{
+		Prefix: blobName(path),
+	})
+	for {
+		var attrs *storage.ObjectAttrs
+		if attrs, err = objects.Next(); err == iterator.Done {
+			return list, nil
+		} else if err != nil {
+			return
+		}
+		list = append(list, "/"+attrs.Name)
+	}
+}
+
+// Delete Remove path
+func (g *GCSStorage) Delete(ctx context.Context, path string) error {
+	err := g.client.Bucket(g.Bucket).Object(blobName(path)).Delete(ctx)
+	if errors.Is(err, storage.ErrObjectNotExist) {
+		return nil
+	}
+	return err
+}
+
+// Promote Copy object to the new name server-side with new metadata and delete the source.
+// Copier rewrites large objects in several calls by itself.
+func (g *GCSStorage) Promote(ctx context.Context, from, to string,
+	metadata map[string]string, _ backupstorage.PutOptions,
+) (err error) {
+	source := g.client.Bucket(g.Bucket).Object(blobName(from))
+	copier := g.client.Bucket(g.Bucket).Object(blobName(to)).CopierFrom(source)
+	copier.Metadata = metadata
+	if _, err = copier.Run(ctx); err != nil {
+		return
+	}
+	return g.Delete(ctx, from)
+}
+
+// Get underlying Kubernetes object
+func (g *GCSStorage) GetObject() *backupoperatoriov1.BackupStorage {
+	return g.object
+}
+
+// Stat Get object attributes and metadata
+func (g *GCSStorage) Stat(ctx context.Context, path string) (info backupstorage.ObjectInfo, err error) {
+	var attrs *storage.ObjectAttrs
+	if attrs, err = g.client.Bucket(g.Bucket).Object(blobName(path)).Attrs(ctx); err != nil {
+		return
+	}
+	if attrs.Size > 0 {
+		info.Size = uint(attrs.Size)
+	}
+	info.ModTime = attrs.Updated
+	info.ETag = attrs.Etag
+	info.Metadata = attrs.Metadata
+	return
+}
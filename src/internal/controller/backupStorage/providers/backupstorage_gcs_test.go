@@ -0,0 +1,107 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package backupstorageproviders
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	backupoperatoriov1 "backup-operator.io/api/v1"
+)
+
+// testStorageObject BackupStorage object to configure providers with
+func testStorageObject(name string) *backupoperatoriov1.BackupStorage {
+	return &backupoperatoriov1.BackupStorage{ObjectMeta: metav1.ObjectMeta{Name: name}}
+}
+
+// newFakeGCS Serve an empty bucket over JSON API and count requests to it
+func newFakeGCS(t *testing.T) (*httptest.Server, *atomic.Int32) {
+	t.Helper()
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		if r.Method != http.MethodGet || !strings.HasSuffix(r.URL.Path, "/b/backups/o") {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"kind": "storage#objects"}`))
+	}))
+	t.Cleanup(server.Close)
+	return server, &requests
+}
+
+func TestGCSReconfiguration(t *testing.T) {
+	ctx := context.Background()
+	firstServer, firstRequests := newFakeGCS(t)
+	secondServer, secondRequests := newFakeGCS(t)
+	first := newConfiguredProvider(t, "gcs", "gcs", map[string]string{
+		"bucket":    "backups",
+		"endpoint":  firstServer.URL + "/storage/v1/",
+		"chunkSize": "1Mi",
+	}, nil).(*GCSStorage)
+	defer first.Destructor()
+	if first.ChunkSize != 1<<20 {
+		t.Errorf("got chunk size %d, expected 1Mi", first.ChunkSize)
+	}
+
+	second := newConfiguredProvider(t, "gcs", "gcs", map[string]string{
+		"bucket":   "backups",
+		"endpoint": secondServer.URL + "/storage/v1/",
+	}, nil).(*GCSStorage)
+	defer second.Destructor()
+	if second.ChunkSize != 16<<20 || second.Endpoint != secondServer.URL+"/storage/v1/" {
+		t.Errorf("removed parameters are kept: chunk size %d, endpoint %s", second.ChunkSize, second.Endpoint)
+	}
+
+	// The old provider keeps working until it is destroyed, and the new one uses the new endpoint only
+	before := firstRequests.Load()
+	if list, err := first.List(ctx, "/"); err != nil || len(list) != 0 {
+		t.Errorf("the old provider has failed to list: %v, %v", list, err)
+	}
+	if firstRequests.Load() == before {
+		t.Error("the old provider has not used its endpoint")
+	}
+	before = firstRequests.Load()
+	if list, err := second.List(ctx, "/"); err != nil || !slices.Equal(list, nil) {
+		t.Errorf("the new provider has failed to list: %v, %v", list, err)
+	}
+	if firstRequests.Load() != before || secondRequests.Load() < 2 {
+		t.Error("the new provider has used the old endpoint")
+	}
+}
+
+func TestGCSInvalidParameters(t *testing.T) {
+	for _, parameters := range []map[string]string{
+		{},
+		{"bucket": "backups", "endpoint": "ftp://emulator"},
+		{"bucket": "backups", "endpoint": "http://emulator", "chunkSize": "128Ki"},
+		{"bucket": "backups", "endpoint": "http://emulator", "chunkSize": "big"},
+	} {
+		provider := &GCSStorage{ChunkSize: 16 << 20}
+		if err := provider.Constructor(testStorageObject("gcs"), parameters, nil); err == nil {
+			t.Errorf("%v: provider has been configured", parameters)
+		}
+	}
+}
@@ -0,0 +1,342 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package backupstorageproviders
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"strconv"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+
+	storagepluginv1 "backup-operator.io/api/storageplugin/v1"
+	backupoperatoriov1 "backup-operator.io/api/v1"
+	backupstorage "backup-operator.io/internal/controller/backupStorage"
+)
+
+// PluginStorage is an implementation of BackupStorage that delegates all operations
+// to an out-of-process plugin over gRPC, e.g. a sidecar or an in-cluster Service.
+type PluginStorage struct {
+	// gRPC target like dns:///tape-gateway.backup.svc:9090 or unix:///var/run/plugin.sock
+	Address string
+	TLS     bool `default:"false"`
+	// Timeout of unary calls, streams are limited by the caller context only
+	Timeout time.Duration `default:"30s"`
+
+	// Parameters and credentials passed through to the plugin
+	parameters  map[string]string
+	credentials map[string]string
+	conn        *grpc.ClientConn
+	client      storagepluginv1.StorageProviderClient
+	// Underlying Kubernetes object
+	object *backupoperatoriov1.BackupStorage
+}
+
+func init() {
+	backupstorage.RegisterProvider(backupstorage.ProviderRegistration{
+		Type: "plugin",
+		Name: "Plugin",
+		Parameters: []backupstorage.ProviderParameter{
+			{Name: "address", Description: "gRPC target of the plugin like dns:///plugin.backup.svc:9090", Required: true},
+			{Name: "tls", Description: "Set to true to connect with TLS"},
+			{Name: "timeout", Description: "Timeout of unary calls, defaults to 30s"},
+			{Name: "caKey", Description: "Key in credentials secret with CA bundle for TLS, defaults to PLUGIN_CA_CERT"},
+		},
+		PassThroughParameters: true,
+		Factory:               func() backupstorage.BackupStorageProvider { return &PluginStorage{} },
+	})
+}
+
+// Constructor Configure plugin storage
+func (p *PluginStorage) Constructor(object *backupoperatoriov1.BackupStorage, parameters map[string]string, creds map[string]string) (err error) {
+	// Set object field
+	if object == nil {
+		return errors.New("object is mandatory but nil")
+	}
+	p.object = object
+	// Check parameters have all mandatory keys
+	if _, ok := parameters["address"]; !ok {
+		return errors.New("mandatory address parameter is not defined")
+	}
+	// Parse parameters, the ones not used to connect are passed to the plugin
+	caKey := "PLUGIN_CA_CERT"
+	p.parameters = make(map[string]string)
+	for key, value := range parameters {
+		switch key {
+		case "address":
+			p.Address = value
+		case "tls":
+			if p.TLS, err = strconv.ParseBool(value); err != nil {
+				return fmt.Errorf("failed to parse tls: %s", err)
+			}
+		case "timeout":
+			if p.Timeout, err = time.ParseDuration(value); err != nil {
+				return fmt.Errorf("failed to parse timeout: %s", err)
+			}
+		case "caKey":
+			caKey = value
+		default:
+			p.parameters[key] = value
+		}
+	}
+	p.credentials = creds
+	// Prepare transport credentials
+	transport := insecure.NewCredentials()
+	if p.TLS {
+		config := &tls.Config{MinVersion: tls.VersionTLS12}
+		if ca, ok := creds[caKey]; ok {
+			config.RootCAs = x509.NewCertPool()
+			if !config.RootCAs.AppendCertsFromPEM([]byte(ca)) {
+				return errors.New("failed to parse CA bundle")
+			}
+		}
+		transport = credentials.NewTLS(config)
+	}
+	// Every configuration gets a new object, so connection of the previous one is left to its running calls
+	if p.conn, err = grpc.NewClient(p.Address, grpc.WithTransportCredentials(transport)); err != nil {
+		return fmt.Errorf("failed to create plugin client: %s", err)
+	}
+	p.client = storagepluginv1.NewStorageProviderClient(p.conn)
+	// Plugin checks connection to the underlying storage itself
+	if err = p.configure(context.Background()); err != nil {
+		return fmt.Errorf("failed to configure plugin: %s", err)
+	}
+	return
+}
+
+// Destructor Plugin storage object destructor.
+// The storage is not released if it has been configured again at the same plugin, since the plugin
+// keeps one configuration per storage.
+func (p *PluginStorage) Destructor() error {
+	if p.conn == nil {
+		return nil
+	}
+	defer p.conn.Close()
+	if current, ok := backupstorage.GetBackupStorageProvider(p.object.Name); ok {
+		if next, isPlugin := backupstorage.Unwrap(current).(*PluginStorage); isPlugin && next != p && next.Address == p.Address {
+			return nil
+		}
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), p.Timeout)
+	defer cancel()
+	_, err := p.client.Release(ctx, &storagepluginv1.ReleaseRequest{Storage: p.object.Name})
+	return err
+}
+
+// Put Upload file. Data is streamed to the plugin in chunks, metadata is sent in the header.
+func (p *PluginStorage) Put(ctx context.Context, path string, reader io.Reader, metadata map[string]string) (err error) {
+	// Stream is aborted with context cancellation, so plugin does not finalize the file on read error
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	var stream storagepluginv1.StorageProvider_PutClient
+	if stream, err = p.client.Put(ctx); err != nil {
+		return
+	}
+	if err = stream.Send(&storagepluginv1.PutRequest{Content: &storagepluginv1.PutRequest_Header{
+		Header: &storagepluginv1.PutHeader{Storage: p.object.Name, Path: path, Metadata: metadata},
+	}}); err != nil {
+		// Real error is returned by Recv
+		_, err = stream.CloseAndRecv()
+		return p.reconfigureOnError(ctx, err)
+	}
+	buffer := make([]byte, storagepluginv1.ChunkSize)
+	for {
+		n, readErr := reader.Read(buffer)
+		if n > 0 {
+			if err = stream.Send(&storagepluginv1.PutRequest{Content: &storagepluginv1.PutRequest_Data{
+				Data: buffer[:n],
+			}}); err != nil {
+				// Real error is returned by Recv
+				_, err = stream.CloseAndRecv()
+				return p.reconfigureOnError(ctx, err)
+			}
+		}
+		if readErr == io.EOF {
+			break
+		} else if readErr != nil {
+			return readErr
+		}
+	}
+	_, err = stream.CloseAndRecv()
+	return p.reconfigureOnError(ctx, err)
+}
+
+// Get Download file.
+func (p *PluginStorage) Get(ctx context.Context, path string) (io.ReadCloser, error) {
+	var reader *pluginReader
+	err := p.retry(ctx, func() (err error) {
+		reader = &pluginReader{}
+		var streamCtx context.Context
+		streamCtx, reader.cancel = context.WithCancel(ctx)
+		if reader.stream, err = p.client.Get(streamCtx, &storagepluginv1.GetRequest{
+			Storage: p.object.Name,
+			Path:    path,
+		}); err != nil {
+			reader.cancel()
+			return
+		}
+		// Receive the first chunk right away, so errors like missing file are returned here
+		if err = reader.receive(); err != nil && err != io.EOF {
+			reader.cancel()
+			return
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fromStatus(err)
+	}
+	return reader, nil
+}
+
+// List path.
+func (p *PluginStorage) List(ctx context.Context, path string) (list []string, err error) {
+	err = p.retry(ctx, func() error {
+		ctx, cancel := context.WithTimeout(ctx, p.Timeout)
+		defer cancel()
+		response, err := p.client.List(ctx, &storagepluginv1.ListRequest{Storage: p.object.Name, Path: path})
+		list = response.GetPaths()
+		return err
+	})
+	return
+}
+
+// Delete Remove path
+func (p *PluginStorage) Delete(ctx context.Context, path string) error {
+	return fromStatus(p.retry(ctx, func() error {
+		ctx, cancel := context.WithTimeout(ctx, p.Timeout)
+		defer cancel()
+		_, err := p.client.Delete(ctx, &storagepluginv1.DeleteRequest{Storage: p.object.Name, Path: path})
+		return err
+	}))
+}
+
+// Get underlying Kubernetes object
+func (p *PluginStorage) GetObject() *backupoperatoriov1.BackupStorage {
+	return p.object
+}
+
+// Stat Get file attributes
+func (p *PluginStorage) Stat(ctx context.Context, path string) (info backupstorage.ObjectInfo, err error) {
+	err = p.retry(ctx, func() error {
+		ctx, cancel := context.WithTimeout(ctx, p.Timeout)
+		defer cancel()
+		response, err := p.client.Stat(ctx, &storagepluginv1.StatRequest{Storage: p.object.Name, Path: path})
+		if err != nil {
+			return err
+		}
+		info = backupstorage.ObjectInfo{
+			Size:     uint(response.GetSize()),
+			ETag:     response.GetEtag(),
+			Metadata: response.GetMetadata(),
+		}
+		if response.GetModTime() != nil {
+			info.ModTime = response.GetModTime().AsTime()
+		}
+		return nil
+	})
+	err = fromStatus(err)
+	return
+}
+
+// configure Send parameters and credentials to the plugin
+func (p *PluginStorage) configure(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, p.Timeout)
+	defer cancel()
+	_, err := p.client.Configure(ctx, &storagepluginv1.ConfigureRequest{
+		Storage:     p.object.Name,
+		Parameters:  p.parameters,
+		Credentials: p.credentials,
+	})
+	return err
+}
+
+// reconfigureOnError Configure the plugin again if it does not know the storage, e.g. after the plugin restart.
+// The original error is returned anyway, since the call can not be repeated.
+func (p *PluginStorage) reconfigureOnError(ctx context.Context, err error) error {
+	if status.Code(err) == codes.FailedPrecondition {
+		if configureErr := p.configure(ctx); configureErr != nil {
+			return fmt.Errorf("%s, failed to configure plugin again: %s", err, configureErr)
+		}
+	}
+	return err
+}
+
+// retry Run the call and run it once again if the plugin had to be configured again
+func (p *PluginStorage) retry(ctx context.Context, call func() error) (err error) {
+	if err = call(); status.Code(err) != codes.FailedPrecondition {
+		return
+	}
+	if err = p.configure(ctx); err != nil {
+		return fmt.Errorf("failed to configure plugin again: %s", err)
+	}
+	return call()
+}
+
+// fromStatus Convert gRPC status of missing or locked file back to the error providers return
+func fromStatus(err error) error {
+	switch status.Code(err) {
+	case codes.NotFound:
+		return fmt.Errorf("%w: %s", fs.ErrNotExist, status.Convert(err).Message())
+	case codes.PermissionDenied:
+		return fmt.Errorf("%w: %s", backupstorage.ErrObjectLocked, status.Convert(err).Message())
+	}
+	return err
+}
+
+// pluginReader Reads file from Get stream chunk by chunk
+type pluginReader struct {
+	stream storagepluginv1.StorageProvider_GetClient
+	cancel context.CancelFunc
+	chunk  []byte
+	err    error
+}
+
+// receive Receive the next chunk into the buffer
+func (r *pluginReader) receive() error {
+	var response *storagepluginv1.GetResponse
+	if response, r.err = r.stream.Recv(); r.err == nil {
+		r.chunk = response.GetData()
+	}
+	return r.err
+}
+
+func (r *pluginReader) Read(buffer []byte) (n int, err error) {
+	for len(r.chunk) == 0 {
+		if r.err != nil {
+			return 0, r.err
+		}
+		r.receive()
+	}
+	n = copy(buffer, r.chunk)
+	r.chunk = r.chunk[n:]
+	return
+}
+
+func (r *pluginReader) Close() error {
+	r.cancel()
+	return nil
+}
@@ -0,0 +1,271 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package backupstorageproviders
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"testing/iotest"
+	"time"
+
+	"github.com/creasty/defaults"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/test/bufconn"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	storagepluginv1 "backup-operator.io/api/storageplugin/v1"
+	backupoperatoriov1 "backup-operator.io/api/v1"
+	backupstorage "backup-operator.io/internal/controller/backupStorage"
+	storageplugin "backup-operator.io/internal/controller/backupStorage/plugin"
+)
+
+// newBufconnPlugin Serve the filesystem provider over in-memory connection and connect plugin storage to it
+func newBufconnPlugin(t *testing.T) (*PluginStorage, *storageplugin.Server, string) {
+	t.Helper()
+	listener := bufconn.Listen(1 << 20)
+	server := grpc.NewServer()
+	pluginServer := storageplugin.NewServer(func() backupstorage.BackupStorageProvider { return &FilesystemStorage{} })
+	storagepluginv1.RegisterStorageProviderServer(server, pluginServer)
+	go server.Serve(listener)
+	t.Cleanup(server.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return listener.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatalf("failed to create client: %s", err)
+	}
+	dir := t.TempDir()
+	p := &PluginStorage{
+		Address:    "passthrough:///bufnet",
+		Timeout:    10 * time.Second,
+		parameters: map[string]string{"path": dir},
+		conn:       conn,
+		client:     storagepluginv1.NewStorageProviderClient(conn),
+		object:     &backupoperatoriov1.BackupStorage{ObjectMeta: metav1.ObjectMeta{Name: "plugin"}},
+	}
+	if err = p.configure(context.Background()); err != nil {
+		t.Fatalf("failed to configure plugin: %s", err)
+	}
+	t.Cleanup(func() { p.Destructor() })
+	return p, pluginServer, dir
+}
+
+func TestPluginRoundTrip(t *testing.T) {
+	p, _, dir := newBufconnPlugin(t)
+	ctx := context.Background()
+	// Bigger than a few stream chunks and not aligned to them
+	data := bytes.Repeat([]byte("backup-operator"), storagepluginv1.ChunkSize/5)
+	if err := p.Put(ctx, "/mysql/backup.sql", bytes.NewReader(data), map[string]string{"run": "mysql"}); err != nil {
+		t.Fatalf("failed to put: %s", err)
+	}
+	if err := p.Put(ctx, "/mysql/empty.sql", bytes.NewReader(nil), nil); err != nil {
+		t.Fatalf("failed to put empty file: %s", err)
+	}
+	if written, _ := os.ReadFile(filepath.Join(dir, "mysql", "backup.sql")); !bytes.Equal(written, data) {
+		t.Error("file on filesystem differs from the uploaded one")
+	}
+
+	reader, err := p.Get(ctx, "/mysql/backup.sql")
+	if err != nil {
+		t.Fatalf("failed to get: %s", err)
+	}
+	read, err := io.ReadAll(reader)
+	reader.Close()
+	if err != nil || !bytes.Equal(read, data) {
+		t.Errorf("downloaded file differs from the uploaded one, error %v", err)
+	}
+	if reader, err = p.Get(ctx, "/mysql/empty.sql"); err != nil {
+		t.Fatalf("failed to get empty file: %s", err)
+	}
+	if read, err = io.ReadAll(reader); err != nil || len(read) != 0 {
+		t.Errorf("got %d bytes and error %v for empty file", len(read), err)
+	}
+	reader.Close()
+
+	list, err := p.List(ctx, "/mysql/")
+	if err != nil || !slices.Equal(list, []string{"/mysql/backup.sql", "/mysql/empty.sql"}) {
+		t.Errorf("got list %v and error %v", list, err)
+	}
+
+	info, err := p.Stat(ctx, "/mysql/backup.sql")
+	if err != nil || info.Size != uint(len(data)) || info.ModTime.IsZero() {
+		t.Errorf("got %+v and error %v, expected size %d", info, err, len(data))
+	}
+
+	if err = p.Delete(ctx, "/mysql/backup.sql"); err != nil {
+		t.Fatalf("failed to delete: %s", err)
+	}
+	// Removing missing file is not an error
+	if err = p.Delete(ctx, "/mysql/backup.sql"); err != nil {
+		t.Errorf("failed to delete missing file: %s", err)
+	}
+	if list, err = p.List(ctx, "/"); err != nil || !slices.Equal(list, []string{"/mysql/empty.sql"}) {
+		t.Errorf("got list %v and error %v after deletion", list, err)
+	}
+}
+
+func TestPluginErrors(t *testing.T) {
+	p, _, _ := newBufconnPlugin(t)
+	ctx := context.Background()
+	if _, err := p.Get(ctx, "/missing"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("got %v for missing file, expected not exist error", err)
+	}
+	if _, err := p.Stat(ctx, "/missing"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("got %v for missing file, expected not exist error", err)
+	}
+	if err := p.Put(ctx, "/file", bytes.NewReader([]byte("data")), nil); err != nil {
+		t.Fatalf("failed to put: %s", err)
+	}
+	// Other failures must not look like a missing file
+	if _, err := p.Stat(ctx, "/file/nested"); err == nil || errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("got %v for path under a file, expected other error", err)
+	}
+	if err := p.Put(ctx, "/file/nested", bytes.NewReader([]byte("data")), nil); err == nil {
+		t.Error("put under a file has not failed")
+	}
+	// Upload with read error must not leave a file behind
+	failing := io.MultiReader(bytes.NewReader([]byte("partial")), iotest.ErrReader(errors.New("read failed")))
+	if err := p.Put(ctx, "/failed", failing, nil); err == nil {
+		t.Error("put with read error has not failed")
+	}
+	// The server aborts the upload asynchronously
+	var err error
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		if _, err = p.Stat(ctx, "/failed"); errors.Is(err, fs.ErrNotExist) {
+			break
+		}
+	}
+	if !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("got %v for failed upload, expected not exist error", err)
+	}
+}
+
+func TestPluginReconfigure(t *testing.T) {
+	p, server, _ := newBufconnPlugin(t)
+	ctx := context.Background()
+	if err := p.Put(ctx, "/file", bytes.NewReader([]byte("data")), nil); err != nil {
+		t.Fatalf("failed to put: %s", err)
+	}
+	// Plugin forgets the storage like after restart
+	if _, err := server.Release(ctx, &storagepluginv1.ReleaseRequest{Storage: "plugin"}); err != nil {
+		t.Fatal(err)
+	}
+	if list, err := p.List(ctx, "/"); err != nil || !slices.Equal(list, []string{"/file"}) {
+		t.Errorf("got list %v and error %v after plugin restart", list, err)
+	}
+}
+
+// configureProvider Create provider of the type the way the operator does for every configuration
+func configureProvider(storageType, name string, parameters, credentials map[string]string) (backupstorage.BackupStorageProvider, error) {
+	registration, ok := backupstorage.GetProviderRegistration(storageType)
+	if !ok {
+		return nil, fmt.Errorf("%s provider is not registered", storageType)
+	}
+	provider := registration.Factory()
+	if err := defaults.Set(provider); err != nil {
+		return nil, err
+	}
+	return provider, provider.Constructor(testStorageObject(name), parameters, credentials)
+}
+
+// newConfiguredProvider Create provider of the type and fail the test if it can not be configured
+func newConfiguredProvider(t *testing.T, storageType, name string, parameters, credentials map[string]string) backupstorage.BackupStorageProvider {
+	t.Helper()
+	provider, err := configureProvider(storageType, name, parameters, credentials)
+	if err != nil {
+		t.Fatalf("failed to configure %s provider: %s", storageType, err)
+	}
+	return provider
+}
+
+func TestPluginReplacement(t *testing.T) {
+	const name = "plugin-replacement"
+	socket := filepath.Join(t.TempDir(), "plugin.sock")
+	listener, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := grpc.NewServer()
+	pluginServer := storageplugin.NewServer(func() backupstorage.BackupStorageProvider { return &FilesystemStorage{} })
+	storagepluginv1.RegisterStorageProviderServer(server, pluginServer)
+	go server.Serve(listener)
+	defer server.Stop()
+	defer backupstorage.RemoveBackupStorageProvider(name)
+	ctx := context.Background()
+	dir := t.TempDir()
+
+	first := newConfiguredProvider(t, "plugin", name, map[string]string{"address": "unix://" + socket, "path": dir, "timeout": "5s"}, nil)
+	backupstorage.AddBackupStorageProvider(name, first)
+	current, _ := backupstorage.GetBackupStorageProvider(name)
+	data := bytes.Repeat([]byte("backup-operator"), storagepluginv1.ChunkSize)
+	if err = current.Put(ctx, "/backup", bytes.NewReader(data), nil); err != nil {
+		t.Fatalf("failed to put: %s", err)
+	}
+	// Restore is running while the storage is configured again
+	reader, err := current.Get(ctx, "/backup")
+	if err != nil {
+		t.Fatalf("failed to get: %s", err)
+	}
+	head := make([]byte, 1000)
+	if _, err = io.ReadFull(reader, head); err != nil {
+		t.Fatalf("failed to read: %s", err)
+	}
+
+	second := newConfiguredProvider(t, "plugin", name, map[string]string{"address": "unix://" + socket, "path": dir}, nil)
+	if plugin := second.(*PluginStorage); plugin.Timeout != 30*time.Second || plugin.TLS {
+		t.Errorf("removed parameters are kept: timeout %s, tls %t", plugin.Timeout, plugin.TLS)
+	}
+	destroyed := make(chan error, 1)
+	backupstorage.ReplaceBackupStorageProvider(name, second, func(err error) { destroyed <- err })
+
+	rest, err := io.ReadAll(reader)
+	if err != nil || !bytes.Equal(append(head, rest...), data) {
+		t.Errorf("download has been broken by reconfiguration, error %v", err)
+	}
+	select {
+	case <-destroyed:
+		t.Fatal("the old provider has been destroyed during the download")
+	default:
+	}
+	reader.Close()
+	select {
+	case err = <-destroyed:
+		if err != nil {
+			t.Errorf("failed to destroy the old provider: %s", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("the old provider has not been destroyed")
+	}
+	// The old provider must not release the configuration of the new one
+	if _, err = pluginServer.List(ctx, &storagepluginv1.ListRequest{Storage: name, Path: "/"}); err != nil {
+		t.Errorf("storage has been released at the plugin: %s", err)
+	}
+	if _, err = second.Stat(ctx, "/backup"); err != nil {
+		t.Errorf("the new provider does not work: %s", err)
+	}
+}
@@ -18,20 +18,29 @@ package backupstorageproviders
 
 import (
 	"context"
+	"encoding/base64"
 	"errors"
 	"fmt"
 	"io"
+	"net/url"
 	"regexp"
+	"slices"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
 	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/credentials/stscreds"
 	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/s3"
 	"github.com/aws/aws-sdk-go/service/s3/s3manager"
+	"github.com/aws/aws-sdk-go/service/sts"
 	"k8s.io/utils/ptr"
 
 	backupoperatoriov1 "backup-operator.io/api/v1"
+	backupstorage "backup-operator.io/internal/controller/backupStorage"
 )
 
 // S3Storage is an implementation of BackupStorage for Amazon S3.
@@ -41,15 +50,136 @@ type S3Storage struct {
 	Region           string `default:"us-east-1"`
 	Insecure         bool   `default:"false"`
 	S3ForcePathStyle bool   `default:"false"`
+	// Server-side encryption mode, one of SSE-S3, SSE-KMS or SSE-C
+	SSE string
+	// KMS key for SSE-KMS, AWS managed key is used if empty
+	SSEKMSKeyID string
+	// Default storage class of uploaded objects, bucket default is used if empty
+	StorageClass string
+	// How long restored copy of an archived object is kept
+	RestoreDays int64 `default:"1"`
+	// Speed of the restoration from archive
+	RestoreTier string `default:"Standard"`
+	// Role to assume, either with web identity token or with base credentials
+	RoleARN string
+	// Projected service account token for web identity federation, e.g. IRSA
+	WebIdentityTokenFile string
+	// External ID required by the role trust policy
+	ExternalID      string
+	RoleSessionName string        `default:"backup-operator"`
+	RoleDuration    time.Duration `default:"1h"`
+	// Custom STS endpoint, AWS one for the region is used if empty
+	STSEndpoint string
 
-	credentials *credentials.Credentials
-	session     *session.Session
-	s3svc       *s3.S3
-	uploader    *s3manager.Uploader
+	// Raw 256-bit key for SSE-C
+	sseCustomerKey *string
+	// Bucket has Object Lock enabled, so deletion removes the exact object version
+	objectLockEnabled bool
+	credentials       *credentials.Credentials
+	session           *session.Session
+	s3svc             *s3.S3
+	uploader          *s3manager.Uploader
 	// Underlying Kubernetes object
 	object *backupoperatoriov1.BackupStorage
 }
 
+func init() {
+	backupstorage.RegisterProvider(backupstorage.ProviderRegistration{
+		Type: "s3",
+		Name: "S3",
+		Parameters: []backupstorage.ProviderParameter{
+			{Name: "bucket", Description: "Name of the bucket", Required: true},
+			{Name: "endpoint", Description: "Custom endpoint like http://minio.minio.svc:9000"},
+			{Name: "region", Description: "Bucket region, defaults to us-east-1"},
+			{Name: "insecure", Description: "Set to true to disable TLS"},
+			{Name: "s3ForcePathStyle", Description: "Set to true for path-style addressing"},
+			{Name: "accessKey", Description: "Key in credentials secret with access key, defaults to AWS_ACCESS_KEY_ID"},
+			{Name: "secretKey", Description: "Key in credentials secret with secret key, defaults to AWS_SECRET_ACCESS_KEY"},
+			{Name: "sse", Description: "Server-side encryption mode, one of SSE-S3, SSE-KMS or SSE-C"},
+			{Name: "sseKmsKeyId", Description: "KMS key ID or ARN for SSE-KMS, defaults to AWS managed key"},
+			{Name: "sseCustomerKey", Description: "Key in credentials secret with SSE-C key, defaults to AWS_SSE_CUSTOMER_KEY"},
+			{Name: "sessionToken", Description: "Key in credentials secret with session token, defaults to AWS_SESSION_TOKEN"},
+			{Name: "roleArn", Description: "ARN of the role to assume"},
+			{Name: "webIdentityTokenFile", Description: "Path to web identity token file to assume the role with, e.g. IRSA token"},
+			{Name: "externalId", Description: "External ID to assume the role with"},
+			{Name: "roleSessionName", Description: "Session name of the assumed role, defaults to backup-operator"},
+			{Name: "roleDuration", Description: "Duration of the assumed role session, defaults to 1h"},
+			{Name: "stsEndpoint", Description: "Custom STS endpoint"},
+			{Name: "storageClass", Description: "Default storage class of backups, e.g. STANDARD_IA or DEEP_ARCHIVE"},
+			{Name: "restoreDays", Description: "Days to keep restored copy of archived backups, defaults to 1"},
+			{Name: "restoreTier", Description: "Restoration tier of archived backups, one of Expedited, Standard or Bulk, defaults to Standard"},
+		},
+		Factory:  func() backupstorage.BackupStorageProvider { return &S3Storage{} },
+		Validate: validateS3Parameters,
+	})
+}
+
+// S3 server-side encryption modes
+const (
+	s3SSES3  = "SSE-S3"
+	s3SSEKMS = "SSE-KMS"
+	s3SSEC   = "SSE-C"
+)
+
+// validateS3Parameters Validate combinations of server-side encryption parameters
+func validateS3Parameters(parameters map[string]string) (warnings []string, err error) {
+	sse, sseSet := parameters["sse"]
+	switch sse {
+	case s3SSES3, s3SSEKMS, s3SSEC:
+	default:
+		if sseSet {
+			return nil, fmt.Errorf("sse must be one of %s, %s or %s", s3SSES3, s3SSEKMS, s3SSEC)
+		}
+	}
+	if _, ok := parameters["sseKmsKeyId"]; ok && sse != s3SSEKMS {
+		return nil, fmt.Errorf("sseKmsKeyId requires sse to be %s", s3SSEKMS)
+	}
+	if _, ok := parameters["sseCustomerKey"]; ok && sse != s3SSEC {
+		return nil, fmt.Errorf("sseCustomerKey requires sse to be %s", s3SSEC)
+	}
+	if insecure, _ := strconv.ParseBool(parameters["insecure"]); insecure && sse == s3SSEC {
+		return nil, fmt.Errorf("%s keys can not be sent without TLS, insecure must be false", s3SSEC)
+	}
+	_, roleSet := parameters["roleArn"]
+	_, webIdentitySet := parameters["webIdentityTokenFile"]
+	_, externalIDSet := parameters["externalId"]
+	switch {
+	case webIdentitySet && !roleSet:
+		return nil, errors.New("webIdentityTokenFile requires roleArn")
+	case externalIDSet && !roleSet:
+		return nil, errors.New("externalId requires roleArn")
+	case externalIDSet && webIdentitySet:
+		return nil, errors.New("externalId can not be used with webIdentityTokenFile")
+	}
+	if duration, ok := parameters["roleDuration"]; ok {
+		if d, parseErr := time.ParseDuration(duration); parseErr != nil || d < 15*time.Minute {
+			return nil, errors.New("roleDuration must be a duration of 15m at least")
+		}
+	}
+	if class, ok := parameters["storageClass"]; ok {
+		if err = validateS3StorageClass(class); err != nil {
+			return
+		}
+	}
+	if days, ok := parameters["restoreDays"]; ok {
+		if n, parseErr := strconv.ParseInt(days, 10, 64); parseErr != nil || n < 1 {
+			return nil, errors.New("restoreDays must be a positive integer")
+		}
+	}
+	if tier, ok := parameters["restoreTier"]; ok && !slices.Contains(s3.Tier_Values(), tier) {
+		return nil, fmt.Errorf("restoreTier must be one of %s", strings.Join(s3.Tier_Values(), ", "))
+	}
+	return
+}
+
+// validateS3StorageClass Check the storage class is known to S3
+func validateS3StorageClass(class string) error {
+	if !slices.Contains(s3.StorageClass_Values(), class) {
+		return fmt.Errorf("storage class must be one of %s", strings.Join(s3.StorageClass_Values(), ", "))
+	}
+	return nil
+}
+
 // Constructor Configure S3
 func (s *S3Storage) Constructor(object *backupoperatoriov1.BackupStorage, parameters map[string]string, creds map[string]string) (err error) {
 	// Set object field
@@ -61,25 +191,12 @@ func (s *S3Storage) Constructor(object *backupoperatoriov1.BackupStorage, parame
 	if _, ok := parameters["bucket"]; !ok {
 		return errors.New("mandatory bucket parameter is not defined")
 	}
-	// Try to load access and secret keys from credentials
-	func() {
-		var accessKey, secretKey string
-		var ok bool
-		if accessKey, ok = parameters["accessKey"]; !ok {
-			accessKey = "AWS_ACCESS_KEY_ID"
-		}
-		if secretKey, ok = parameters["secretKey"]; !ok {
-			secretKey = "AWS_SECRET_ACCESS_KEY"
-		}
-		// Create credentials
-		if accessKey, ok = creds[accessKey]; !ok {
-			return
-		}
-		if secretKey, ok = creds[secretKey]; !ok {
-			return
-		}
-		s.credentials = credentials.NewStaticCredentials(accessKey, secretKey, "")
-	}()
+	// Webhook validates the same, but storage may have been created before
+	if _, err = validateS3Parameters(parameters); err != nil {
+		return
+	}
+	// Static credentials are the base ones for the role to assume as well
+	s.credentials = staticS3Credentials(parameters, creds)
 	// Parse other parameters
 	for key, value := range parameters {
 		switch key {
@@ -110,6 +227,57 @@ func (s *S3Storage) Constructor(object *backupoperatoriov1.BackupStorage, parame
 				return
 			}
 			s.S3ForcePathStyle = b
+		case "sse":
+			s.SSE = value
+		case "sseKmsKeyId":
+			s.SSEKMSKeyID = value
+		case "storageClass":
+			s.StorageClass = value
+		case "restoreDays":
+			if s.RestoreDays, err = strconv.ParseInt(value, 10, 64); err != nil {
+				return
+			}
+		case "restoreTier":
+			s.RestoreTier = value
+		case "roleArn":
+			s.RoleARN = value
+		case "webIdentityTokenFile":
+			s.WebIdentityTokenFile = value
+		case "externalId":
+			s.ExternalID = value
+		case "roleSessionName":
+			s.RoleSessionName = value
+		case "roleDuration":
+			if s.RoleDuration, err = time.ParseDuration(value); err != nil {
+				return
+			}
+		case "stsEndpoint":
+			s.STSEndpoint = value
+		}
+	}
+	// SSE-C key is never stored by S3, so it has to be sent with every request
+	if s.SSE == s3SSEC {
+		keyName := "AWS_SSE_CUSTOMER_KEY"
+		if name, ok := parameters["sseCustomerKey"]; ok {
+			keyName = name
+		}
+		key, ok := creds[keyName]
+		if !ok {
+			return fmt.Errorf("%s key %s is not found in credentials", s3SSEC, keyName)
+		}
+		// Key may be either raw or base64 encoded
+		if decoded, decodeErr := base64.StdEncoding.DecodeString(key); decodeErr == nil && len(decoded) == 32 {
+			key = string(decoded)
+		}
+		if len(key) != 32 {
+			return fmt.Errorf("%s key must be 256 bits long", s3SSEC)
+		}
+		s.sseCustomerKey = &key
+	}
+	// Assume the role if requested
+	if len(s.RoleARN) > 0 {
+		if err = s.assumeRole(); err != nil {
+			return
 		}
 	}
 	// Create a new AWS session
@@ -136,6 +304,78 @@ func (s *S3Storage) Constructor(object *backupoperatoriov1.BackupStorage, parame
 	}); err != nil {
 		return fmt.Errorf("failed to test S3 connection: %s", err)
 	}
+	// Check Object Lock, missing configuration or permission means it is disabled
+	var lock *s3.GetObjectLockConfigurationOutput
+	if lock, err = s.s3svc.GetObjectLockConfiguration(&s3.GetObjectLockConfigurationInput{
+		Bucket: &s.Bucket,
+	}); err == nil && lock.ObjectLockConfiguration != nil {
+		s.objectLockEnabled = aws.StringValue(lock.ObjectLockConfiguration.ObjectLockEnabled) == s3.ObjectLockEnabledEnabled
+	}
+	return nil
+}
+
+// staticS3Credentials Load access and secret keys with optional session token from credentials.
+// Key names may be overridden by parameters. Nil is returned if any key is missing, so the default
+// chain is used: environment, IRSA, instance profile, etc.
+func staticS3Credentials(parameters map[string]string, creds map[string]string) *credentials.Credentials {
+	var accessKey, secretKey, sessionToken string
+	var ok bool
+	if accessKey, ok = parameters["accessKey"]; !ok {
+		accessKey = "AWS_ACCESS_KEY_ID"
+	}
+	if secretKey, ok = parameters["secretKey"]; !ok {
+		secretKey = "AWS_SECRET_ACCESS_KEY"
+	}
+	if sessionToken, ok = parameters["sessionToken"]; !ok {
+		sessionToken = "AWS_SESSION_TOKEN"
+	}
+	if accessKey, ok = creds[accessKey]; !ok {
+		return nil
+	}
+	if secretKey, ok = creds[secretKey]; !ok {
+		return nil
+	}
+	// Session token is optional
+	return credentials.NewStaticCredentials(accessKey, secretKey, creds[sessionToken])
+}
+
+// assumeRole Replace credentials with the ones of the assumed role.
+// Credentials are refreshed automatically before they expire.
+func (s *S3Storage) assumeRole() (err error) {
+	// STS must not be called at S3 endpoint
+	var base *session.Session
+	if base, err = session.NewSessionWithOptions(session.Options{
+		SharedConfigState: session.SharedConfigEnable,
+		Config: aws.Config{
+			Endpoint:    ptr.To[string](s.STSEndpoint),
+			Region:      ptr.To[string](s.Region),
+			Credentials: s.credentials,
+		},
+	}); err != nil {
+		return fmt.Errorf("failed to make a STS session: %s", err)
+	}
+	svc := sts.New(base)
+	if len(s.WebIdentityTokenFile) > 0 {
+		// Token file is read on every refresh, since kubelet rotates it
+		s.credentials = credentials.NewCredentials(stscreds.NewWebIdentityRoleProviderWithOptions(
+			svc, s.RoleARN, s.RoleSessionName, stscreds.FetchTokenPath(s.WebIdentityTokenFile),
+			func(p *stscreds.WebIdentityRoleProvider) {
+				p.Duration = s.RoleDuration
+			},
+		))
+	} else {
+		s.credentials = stscreds.NewCredentialsWithClient(svc, s.RoleARN, func(p *stscreds.AssumeRoleProvider) {
+			p.RoleSessionName = s.RoleSessionName
+			p.Duration = s.RoleDuration
+			if len(s.ExternalID) > 0 {
+				p.ExternalID = &s.ExternalID
+			}
+		})
+	}
+	// Fail early with a clear error instead of failed connection check
+	if _, err = s.credentials.Get(); err != nil {
+		return fmt.Errorf("failed to assume role %s: %s", s.RoleARN, err)
+	}
 	return
 }
 
@@ -144,67 +384,392 @@ func (s *S3Storage) Destructor() error {
 	return nil
 }
 
-// Put Upload file.
-func (s *S3Storage) Put(ctx context.Context, path string, reader io.Reader) error {
+// Put Upload file. Metadata is kept as object user metadata.
+func (s *S3Storage) Put(ctx context.Context, path string, reader io.Reader, metadata map[string]string) error {
+	return s.PutWithOptions(ctx, path, reader, metadata, backupstorage.PutOptions{})
+}
+
+// PutWithOptions Upload file with Object Lock retention and storage class.
+func (s *S3Storage) PutWithOptions(ctx context.Context, path string, reader io.Reader,
+	metadata map[string]string, options backupstorage.PutOptions,
+) error {
+	storageClass, err := s.storageClass(options)
+	if err != nil {
+		return err
+	}
+	// Staged backups are promoted with server-side copy, which archived objects do not allow.
+	// Other files of the operator, e.g. health probes and deduplicated chunks, are read back too.
+	if strings.HasPrefix(path, backupstorage.InternalPrefix) {
+		storageClass = s3.StorageClassStandard
+	}
 	// Upload the file to S3/MinIO bucket
-	_, err := s.uploader.UploadWithContext(ctx, &s3manager.UploadInput{
+	input := &s3manager.UploadInput{
 		Bucket: &s.Bucket,
 		Key:    &path,
 		Body:   reader,
 		// ContentType: aws.String("application/octet-stream"),
-	})
+	}
+	if len(metadata) > 0 {
+		input.Metadata = aws.StringMap(metadata)
+	}
+	// Uploader passes SSE-C parameters to every part of multipart uploads as well
+	input.ServerSideEncryption, input.SSEKMSKeyId = s.serverSideEncryption()
+	input.SSECustomerAlgorithm, input.SSECustomerKey = s.sseCustomerAlgorithm(), s.sseCustomerKey
+	if len(storageClass) > 0 {
+		input.StorageClass = &storageClass
+	}
+	// SDK adds Content-MD5 required for Object Lock to every request
+	if options.Retention != nil {
+		input.ObjectLockMode = aws.String(options.Retention.Mode)
+		input.ObjectLockRetainUntilDate = aws.Time(options.Retention.RetainUntil)
+	}
+	_, err = s.uploader.UploadWithContext(ctx, input)
 	return err
 }
 
+// storageClass Get storage class of the upload, the default one unless options set it
+func (s *S3Storage) storageClass(options backupstorage.PutOptions) (string, error) {
+	if len(options.StorageClass) == 0 {
+		return s.StorageClass, nil
+	}
+	if err := validateS3StorageClass(options.StorageClass); err != nil {
+		return "", err
+	}
+	return options.StorageClass, nil
+}
+
+// Objects up to this size are copied with a single request, larger ones with multipart copy
+const (
+	s3MaxCopySize  = 5 << 30
+	s3CopyPartSize = 512 << 20
+)
+
+// Promote Copy object to the new key server-side and delete the source.
+// Metadata, storage class, encryption and retention are set for the copy.
+func (s *S3Storage) Promote(ctx context.Context, from, to string,
+	metadata map[string]string, options backupstorage.PutOptions,
+) (err error) {
+	var storageClass string
+	if storageClass, err = s.storageClass(options); err != nil {
+		return
+	}
+	var head *s3.HeadObjectOutput
+	if head, err = s.s3svc.HeadObjectWithContext(ctx, &s3.HeadObjectInput{
+		Bucket:               &s.Bucket,
+		Key:                  &from,
+		SSECustomerAlgorithm: s.sseCustomerAlgorithm(),
+		SSECustomerKey:       s.sseCustomerKey,
+	}); err != nil {
+		return
+	}
+	// Copy source is URL-encoded bucket and key
+	segments := strings.Split(s.Bucket+"/"+from, "/")
+	for i := range segments {
+		segments[i] = url.PathEscape(segments[i])
+	}
+	source := strings.Join(segments, "/")
+	if size := aws.Int64Value(head.ContentLength); size <= s3MaxCopySize {
+		input := &s3.CopyObjectInput{
+			Bucket:                         &s.Bucket,
+			Key:                            &to,
+			CopySource:                     &source,
+			CopySourceSSECustomerAlgorithm: s.sseCustomerAlgorithm(),
+			CopySourceSSECustomerKey:       s.sseCustomerKey,
+			SSECustomerAlgorithm:           s.sseCustomerAlgorithm(),
+			SSECustomerKey:                 s.sseCustomerKey,
+			MetadataDirective:              aws.String(s3.MetadataDirectiveReplace),
+		}
+		if len(metadata) > 0 {
+			input.Metadata = aws.StringMap(metadata)
+		}
+		input.ServerSideEncryption, input.SSEKMSKeyId = s.serverSideEncryption()
+		if len(storageClass) > 0 {
+			input.StorageClass = &storageClass
+		}
+		if options.Retention != nil {
+			input.ObjectLockMode = aws.String(options.Retention.Mode)
+			input.ObjectLockRetainUntilDate = aws.Time(options.Retention.RetainUntil)
+		}
+		_, err = s.s3svc.CopyObjectWithContext(ctx, input)
+	} else {
+		err = s.multipartCopy(ctx, source, to, size, metadata, storageClass, options)
+	}
+	if err != nil {
+		return
+	}
+	// Bucket default retention locks the staged object as well, so it is left until retention expires
+	if err = s.Delete(ctx, from); errors.Is(err, backupstorage.ErrObjectLocked) {
+		return nil
+	}
+	return
+}
+
+// multipartCopy Copy object larger than a single copy request allows part by part
+func (s *S3Storage) multipartCopy(ctx context.Context, source, to string, size int64,
+	metadata map[string]string, storageClass string, options backupstorage.PutOptions,
+) (err error) {
+	input := &s3.CreateMultipartUploadInput{
+		Bucket:               &s.Bucket,
+		Key:                  &to,
+		SSECustomerAlgorithm: s.sseCustomerAlgorithm(),
+		SSECustomerKey:       s.sseCustomerKey,
+	}
+	if len(metadata) > 0 {
+		input.Metadata = aws.StringMap(metadata)
+	}
+	input.ServerSideEncryption, input.SSEKMSKeyId = s.serverSideEncryption()
+	if len(storageClass) > 0 {
+		input.StorageClass = &storageClass
+	}
+	if options.Retention != nil {
+		input.ObjectLockMode = aws.String(options.Retention.Mode)
+		input.ObjectLockRetainUntilDate = aws.Time(options.Retention.RetainUntil)
+	}
+	var upload *s3.CreateMultipartUploadOutput
+	if upload, err = s.s3svc.CreateMultipartUploadWithContext(ctx, input); err != nil {
+		return
+	}
+	defer func() {
+		if err != nil {
+			s.s3svc.AbortMultipartUploadWithContext(context.WithoutCancel(ctx), &s3.AbortMultipartUploadInput{
+				Bucket:   &s.Bucket,
+				Key:      &to,
+				UploadId: upload.UploadId,
+			})
+		}
+	}()
+	var parts []*s3.CompletedPart
+	for offset := int64(0); offset < size; offset += s3CopyPartSize {
+		number := int64(len(parts) + 1)
+		var part *s3.UploadPartCopyOutput
+		if part, err = s.s3svc.UploadPartCopyWithContext(ctx, &s3.UploadPartCopyInput{
+			Bucket:                         &s.Bucket,
+			Key:                            &to,
+			UploadId:                       upload.UploadId,
+			PartNumber:                     &number,
+			CopySource:                     &source,
+			CopySourceRange:                aws.String(fmt.Sprintf("bytes=%d-%d", offset, min(offset+s3CopyPartSize, size)-1)),
+			CopySourceSSECustomerAlgorithm: s.sseCustomerAlgorithm(),
+			CopySourceSSECustomerKey:       s.sseCustomerKey,
+			SSECustomerAlgorithm:           s.sseCustomerAlgorithm(),
+			SSECustomerKey:                 s.sseCustomerKey,
+		}); err != nil {
+			return fmt.Errorf("failed to copy part %d: %s", number, err)
+		}
+		parts = append(parts, &s3.CompletedPart{ETag: part.CopyPartResult.ETag, PartNumber: &number})
+	}
+	_, err = s.s3svc.CompleteMultipartUploadWithContext(ctx, &s3.CompleteMultipartUploadInput{
+		Bucket:          &s.Bucket,
+		Key:             &to,
+		UploadId:        upload.UploadId,
+		MultipartUpload: &s3.CompletedMultipartUpload{Parts: parts},
+	})
+	return
+}
+
 // Get Download file.
 func (s *S3Storage) Get(ctx context.Context, path string) (io.ReadCloser, error) {
 	output, err := s.s3svc.GetObjectWithContext(ctx, &s3.GetObjectInput{
-		Bucket: &s.Bucket,
-		Key:    &path,
+		Bucket:               &s.Bucket,
+		Key:                  &path,
+		SSECustomerAlgorithm: s.sseCustomerAlgorithm(),
+		SSECustomerKey:       s.sseCustomerKey,
 	})
 	return output.Body, err
 }
 
 // List path.
-func (s *S3Storage) List(ctx context.Context, path string) ([]string, error) {
-	output, err := s.s3svc.ListObjectsWithContext(ctx, &s3.ListObjectsInput{
+func (s *S3Storage) List(ctx context.Context, path string) (list []string, err error) {
+	// Listing is paginated by 1000 keys
+	err = s.s3svc.ListObjectsPagesWithContext(ctx, &s3.ListObjectsInput{
 		Bucket: &s.Bucket,
 		Prefix: &path,
+	}, func(page *s3.ListObjectsOutput, _ bool) bool {
+		for _, object := range page.Contents {
+			list = append(list, *object.Key)
+		}
+		return true
 	})
-	var list []string
-	for _, object := range output.Contents {
-		list = append(list, *object.Key)
-	}
-	return list, err
+	return
 }
 
 // Delete Remove path
 func (s *S3Storage) Delete(ctx context.Context, path string) error {
-	_, err := s.s3svc.DeleteObjectWithContext(ctx, &s3.DeleteObjectInput{
+	input := &s3.DeleteObjectInput{
 		Bucket: &s.Bucket,
 		Key:    &path,
+	}
+	// Object Lock buckets are versioned, and deletion without version only adds a delete marker,
+	// so the exact version is deleted to actually free the space or to find out it is locked
+	if s.objectLockEnabled {
+		head, err := s.s3svc.HeadObjectWithContext(ctx, &s3.HeadObjectInput{
+			Bucket:               &s.Bucket,
+			Key:                  &path,
+			SSECustomerAlgorithm: s.sseCustomerAlgorithm(),
+			SSECustomerKey:       s.sseCustomerKey,
+		})
+		if err != nil {
+			if isS3NotFound(err) {
+				return nil
+			}
+			return err
+		}
+		input.VersionId = head.VersionId
+	}
+	_, err := s.s3svc.DeleteObjectWithContext(ctx, input)
+	if s.objectLockEnabled && isS3ErrorCode(err, "AccessDenied") {
+		// Access may be denied by the policy as well, so the error is kept unless the lock is confirmed
+		if lock, lockErr := s.GetObjectLock(ctx, path); lockErr == nil && lock.Locked() {
+			return fmt.Errorf("%w: %s", backupstorage.ErrObjectLocked, err)
+		}
+	}
+	return err
+}
+
+// SetLegalHold Put or release Object Lock legal hold
+func (s *S3Storage) SetLegalHold(ctx context.Context, path string, hold bool) error {
+	status := s3.ObjectLockLegalHoldStatusOff
+	if hold {
+		status = s3.ObjectLockLegalHoldStatusOn
+	}
+	_, err := s.s3svc.PutObjectLegalHoldWithContext(ctx, &s3.PutObjectLegalHoldInput{
+		Bucket:    &s.Bucket,
+		Key:       &path,
+		LegalHold: &s3.ObjectLockLegalHold{Status: aws.String(status)},
 	})
 	return err
 }
 
+// GetObjectLock Get Object Lock retention and legal hold of the file
+func (s *S3Storage) GetObjectLock(ctx context.Context, path string) (lock backupstorage.ObjectLockStatus, err error) {
+	var retention *s3.GetObjectRetentionOutput
+	if retention, err = s.s3svc.GetObjectRetentionWithContext(ctx, &s3.GetObjectRetentionInput{
+		Bucket: &s.Bucket,
+		Key:    &path,
+	}); err == nil && retention.Retention != nil && retention.Retention.RetainUntilDate != nil {
+		lock.Retention = &backupstorage.ObjectRetention{
+			Mode:        aws.StringValue(retention.Retention.Mode),
+			RetainUntil: *retention.Retention.RetainUntilDate,
+		}
+	} else if err != nil && !isS3ErrorCode(err, "NoSuchObjectLockConfiguration") {
+		return
+	}
+	var legalHold *s3.GetObjectLegalHoldOutput
+	if legalHold, err = s.s3svc.GetObjectLegalHoldWithContext(ctx, &s3.GetObjectLegalHoldInput{
+		Bucket: &s.Bucket,
+		Key:    &path,
+	}); err == nil && legalHold.LegalHold != nil {
+		lock.LegalHold = aws.StringValue(legalHold.LegalHold.Status) == s3.ObjectLockLegalHoldStatusOn
+	} else if err != nil && !isS3ErrorCode(err, "NoSuchObjectLockConfiguration") {
+		return
+	}
+	return lock, nil
+}
+
 // Get underlying Kubernetes object
 func (s *S3Storage) GetObject() *backupoperatoriov1.BackupStorage {
 	return s.object
 }
 
-// Get underlying Kubernetes object
-func (s *S3Storage) GetSize(ctx context.Context, path string) (size uint, err error) {
+// Stat Get file attributes and user metadata
+func (s *S3Storage) Stat(ctx context.Context, path string) (info backupstorage.ObjectInfo, err error) {
 	var head *s3.HeadObjectOutput
 	if head, err = s.s3svc.HeadObjectWithContext(ctx, &s3.HeadObjectInput{
-		Bucket: &s.Bucket,
-		Key:    &path,
+		Bucket:               &s.Bucket,
+		Key:                  &path,
+		SSECustomerAlgorithm: s.sseCustomerAlgorithm(),
+		SSECustomerKey:       s.sseCustomerKey,
 	}); err != nil {
 		return
 	}
-	if *head.ContentLength < 0 {
-		size = 0
-	} else {
-		size = uint(*head.ContentLength)
+	if size := aws.Int64Value(head.ContentLength); size > 0 {
+		info.Size = uint(size)
+	}
+	info.ModTime = aws.TimeValue(head.LastModified)
+	info.ETag = strings.Trim(aws.StringValue(head.ETag), `"`)
+	// SDK returns metadata keys in canonical header form like Run-Uid
+	info.Metadata = make(map[string]string, len(head.Metadata))
+	for key, value := range head.Metadata {
+		info.Metadata[strings.ToLower(key)] = aws.StringValue(value)
 	}
 	return
 }
+
+// serverSideEncryption Get SSE-S3 or SSE-KMS parameters of uploads, nil if neither is enabled
+func (s *S3Storage) serverSideEncryption() (mode, kmsKeyID *string) {
+	switch s.SSE {
+	case s3SSES3:
+		return aws.String(s3.ServerSideEncryptionAes256), nil
+	case s3SSEKMS:
+		if len(s.SSEKMSKeyID) > 0 {
+			kmsKeyID = &s.SSEKMSKeyID
+		}
+		return aws.String(s3.ServerSideEncryptionAwsKms), kmsKeyID
+	}
+	return nil, nil
+}
+
+// sseCustomerAlgorithm Get SSE-C algorithm, nil unless SSE-C is enabled.
+// MD5 of the key is calculated by SDK.
+func (s *S3Storage) sseCustomerAlgorithm() *string {
+	if s.sseCustomerKey == nil {
+		return nil
+	}
+	return aws.String(s3.ServerSideEncryptionAes256)
+}
+
+// Rehydrate Request restoration of the archived object, e.g. from GLACIER or DEEP_ARCHIVE storage class
+func (s *S3Storage) Rehydrate(ctx context.Context, path string) (pending bool, err error) {
+	var head *s3.HeadObjectOutput
+	if head, err = s.s3svc.HeadObjectWithContext(ctx, &s3.HeadObjectInput{
+		Bucket:               &s.Bucket,
+		Key:                  &path,
+		SSECustomerAlgorithm: s.sseCustomerAlgorithm(),
+		SSECustomerKey:       s.sseCustomerKey,
+	}); err != nil {
+		return
+	}
+	// Intelligent-Tiering reports archive tiers with archive status, other classes are archived by themselves
+	archiveTier := head.ArchiveStatus != nil
+	switch aws.StringValue(head.StorageClass) {
+	case s3.StorageClassGlacier, s3.StorageClassDeepArchive:
+	default:
+		if !archiveTier {
+			return false, nil
+		}
+	}
+	// Restore header looks like ongoing-request="false", expiry-date="Fri, 21 Dec 2012 00:00:00 GMT"
+	switch restore := aws.StringValue(head.Restore); {
+	case strings.Contains(restore, `ongoing-request="true"`):
+		return true, nil
+	case strings.Contains(restore, `ongoing-request="false"`):
+		return false, nil
+	}
+	request := &s3.RestoreRequest{
+		GlacierJobParameters: &s3.GlacierJobParameters{Tier: &s.RestoreTier},
+	}
+	// Intelligent-Tiering moves the object back to the frequent access tier, so there are no days
+	if !archiveTier {
+		request.Days = &s.RestoreDays
+	}
+	if _, err = s.s3svc.RestoreObjectWithContext(ctx, &s3.RestoreObjectInput{
+		Bucket:         &s.Bucket,
+		Key:            &path,
+		RestoreRequest: request,
+	}); err != nil && !isS3ErrorCode(err, "RestoreAlreadyInProgress") {
+		return
+	}
+	return true, nil
+}
+
+// isS3ErrorCode Check whether the error is S3 API error with the code
+func isS3ErrorCode(err error, code string) bool {
+	var awsErr awserr.Error
+	return errors.As(err, &awsErr) && awsErr.Code() == code
+}
+
+// isS3NotFound Check whether the error means the object does not exist.
+// HeadObject has no body, so it returns bare NotFound code.
+func isS3NotFound(err error) bool {
+	return isS3ErrorCode(err, s3.ErrCodeNoSuchKey) || isS3ErrorCode(err, "NotFound")
+}
@@ -0,0 +1,423 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package backupstorageproviders
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/credentials/stscreds"
+
+	backupstorage "backup-operator.io/internal/controller/backupStorage"
+)
+
+// fakeS3 Minimal path-style S3 API of a single bucket, Object Lock is disabled unless it is set before configuration
+type fakeS3 struct {
+	*httptest.Server
+	mutex sync.Mutex
+	// Storage class header of every upload by object key
+	storageClasses map[string]string
+	// Access key the last request has been signed with
+	accessKey string
+	// Bucket has Object Lock enabled
+	objectLock bool
+	// Objects by key
+	objects map[string]*fakeS3Object
+	// Version IDs of deleted objects by key
+	deletedVersions map[string]string
+}
+
+// fakeS3Object Single version of an object
+type fakeS3Object struct {
+	retainUntil time.Time
+	legalHold   bool
+	// Deletion is denied by the bucket policy
+	denied bool
+}
+
+// writeS3Error Respond with S3 error
+func writeS3Error(w http.ResponseWriter, status int, code string) {
+	w.WriteHeader(status)
+	fmt.Fprintf(w, `<Error><Code>%s</Code><Message>%s</Message></Error>`, code, code)
+}
+
+// signedWith Get access key of the signature of the request, empty for anonymous requests
+func signedWith(r *http.Request) string {
+	_, credential, _ := strings.Cut(r.Header.Get("Authorization"), "Credential=")
+	accessKey, _, _ := strings.Cut(credential, "/")
+	return accessKey
+}
+
+func newFakeS3(t *testing.T) *fakeS3 {
+	t.Helper()
+	fake := &fakeS3{
+		storageClasses:  map[string]string{},
+		objects:         map[string]*fakeS3Object{},
+		deletedVersions: map[string]string{},
+	}
+	fake.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := strings.TrimPrefix(r.URL.Path, "/backups/")
+		fake.mutex.Lock()
+		defer fake.mutex.Unlock()
+		fake.accessKey = signedWith(r)
+		object, exists := fake.objects[key]
+		switch {
+		case r.URL.Query().Has("object-lock") && fake.objectLock:
+			w.Write([]byte(`<ObjectLockConfiguration><ObjectLockEnabled>Enabled</ObjectLockEnabled></ObjectLockConfiguration>`))
+		case r.URL.Query().Has("object-lock"):
+			writeS3Error(w, http.StatusNotFound, "ObjectLockConfigurationNotFoundError")
+		case r.Method == http.MethodGet && r.URL.Path == "/backups":
+			w.Write([]byte(`<ListBucketResult><Name>backups</Name><IsTruncated>false</IsTruncated></ListBucketResult>`))
+		case r.Method == http.MethodPut:
+			io.Copy(io.Discard, r.Body)
+			fake.storageClasses[key] = r.Header.Get("X-Amz-Storage-Class")
+			fake.objects[key] = &fakeS3Object{}
+			w.Header().Set("ETag", `"etag"`)
+		case !exists:
+			writeS3Error(w, http.StatusNotFound, "NoSuchKey")
+		case r.Method == http.MethodHead:
+			w.Header().Set("X-Amz-Version-Id", "version")
+		case r.URL.Query().Has("retention") && !object.retainUntil.IsZero():
+			fmt.Fprintf(w, `<Retention><Mode>COMPLIANCE</Mode><RetainUntilDate>%s</RetainUntilDate></Retention>`,
+				object.retainUntil.UTC().Format(time.RFC3339))
+		case r.URL.Query().Has("legal-hold") && object.legalHold:
+			w.Write([]byte(`<LegalHold><Status>ON</Status></LegalHold>`))
+		case r.URL.Query().Has("retention"), r.URL.Query().Has("legal-hold"):
+			writeS3Error(w, http.StatusNotFound, "NoSuchObjectLockConfiguration")
+		case r.Method == http.MethodDelete && (object.denied || object.legalHold || object.retainUntil.After(time.Now())):
+			writeS3Error(w, http.StatusForbidden, "AccessDenied")
+		case r.Method == http.MethodDelete:
+			delete(fake.objects, key)
+			fake.deletedVersions[key] = r.URL.Query().Get("versionId")
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			w.WriteHeader(http.StatusNotImplemented)
+		}
+	}))
+	t.Cleanup(fake.Close)
+	return fake
+}
+
+// newFakeS3Provider Configure S3 provider with static credentials against the fake
+func newFakeS3Provider(t *testing.T, fake *fakeS3, parameters map[string]string) *S3Storage {
+	t.Helper()
+	parameters["bucket"] = "backups"
+	parameters["endpoint"] = fake.URL
+	parameters["insecure"] = "true"
+	parameters["s3ForcePathStyle"] = "true"
+	return newConfiguredProvider(t, "s3", "s3", parameters, map[string]string{
+		"AWS_ACCESS_KEY_ID":     "access",
+		"AWS_SECRET_ACCESS_KEY": "secret",
+	}).(*S3Storage)
+}
+
+func TestS3StorageClass(t *testing.T) {
+	ctx := context.Background()
+	fake := newFakeS3(t)
+	s := newFakeS3Provider(t, fake, map[string]string{"storageClass": "DEEP_ARCHIVE"})
+	for _, tc := range []struct {
+		path     string
+		options  backupstorage.PutOptions
+		expected string
+	}{
+		{path: "/mysql/db.gz", expected: "DEEP_ARCHIVE"},
+		{path: "/mysql/monthly.gz", options: backupstorage.PutOptions{StorageClass: "GLACIER_IR"}, expected: "GLACIER_IR"},
+		// Files of the operator are read back, so they must never be archived
+		{path: backupstorage.StagingPrefix + "uid", expected: "STANDARD"},
+		{path: backupstorage.StagingPrefix + "other-uid", options: backupstorage.PutOptions{StorageClass: "GLACIER"}, expected: "STANDARD"},
+		{path: backupstorage.InternalPrefix + "health/s3", expected: "STANDARD"},
+		{path: backupstorage.InternalPrefix + "chunks/00/00ff", expected: "STANDARD"},
+		{path: backupstorage.InternalPrefix + "refs/00/00ff/manifest", expected: "STANDARD"},
+	} {
+		t.Run(tc.path, func(t *testing.T) {
+			if err := s.PutWithOptions(ctx, tc.path, strings.NewReader("backup"), nil, tc.options); err != nil {
+				t.Fatal(err)
+			}
+			fake.mutex.Lock()
+			defer fake.mutex.Unlock()
+			if class := fake.storageClasses[strings.TrimPrefix(tc.path, "/")]; class != tc.expected {
+				t.Errorf("got storage class %q, expected %q", class, tc.expected)
+			}
+		})
+	}
+}
+
+// fakeSTS Minimal STS API issuing the same credentials for any role
+type fakeSTS struct {
+	*httptest.Server
+	mutex sync.Mutex
+	// Form of the last request
+	form url.Values
+	// Access key and session token the last request has been signed with
+	accessKey, sessionToken string
+}
+
+func newFakeSTS(t *testing.T) *fakeSTS {
+	t.Helper()
+	fake := &fakeSTS{}
+	fake.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseForm(); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		fake.mutex.Lock()
+		fake.form = r.PostForm
+		fake.accessKey = signedWith(r)
+		fake.sessionToken = r.Header.Get("X-Amz-Security-Token")
+		fake.mutex.Unlock()
+		action := r.PostForm.Get("Action")
+		switch action {
+		case "AssumeRole", "AssumeRoleWithWebIdentity":
+			fmt.Fprintf(w, `<%[1]sResponse xmlns="https://sts.amazonaws.com/doc/2011-06-15/"><%[1]sResult><Credentials>
+<AccessKeyId>assumed</AccessKeyId><SecretAccessKey>assumed-secret</SecretAccessKey><SessionToken>assumed-token</SessionToken>
+<Expiration>%[2]s</Expiration></Credentials></%[1]sResult></%[1]sResponse>`, action, time.Now().Add(time.Hour).UTC().Format(time.RFC3339))
+		default:
+			w.WriteHeader(http.StatusNotImplemented)
+		}
+	}))
+	t.Cleanup(fake.Close)
+	return fake
+}
+
+func TestS3StaticCredentials(t *testing.T) {
+	for _, tc := range []struct {
+		name        string
+		parameters  map[string]string
+		credentials map[string]string
+		// Nil if the default chain is expected
+		expected *credentials.Value
+	}{
+		{
+			name:        "default keys",
+			credentials: map[string]string{"AWS_ACCESS_KEY_ID": "access", "AWS_SECRET_ACCESS_KEY": "secret"},
+			expected:    &credentials.Value{AccessKeyID: "access", SecretAccessKey: "secret"},
+		},
+		{
+			name: "session token",
+			credentials: map[string]string{
+				"AWS_ACCESS_KEY_ID": "access", "AWS_SECRET_ACCESS_KEY": "secret", "AWS_SESSION_TOKEN": "token",
+			},
+			expected: &credentials.Value{AccessKeyID: "access", SecretAccessKey: "secret", SessionToken: "token"},
+		},
+		{
+			name:        "custom keys",
+			parameters:  map[string]string{"accessKey": "id", "secretKey": "key", "sessionToken": "session"},
+			credentials: map[string]string{"id": "access", "key": "secret", "session": "token", "AWS_SESSION_TOKEN": "other"},
+			expected:    &credentials.Value{AccessKeyID: "access", SecretAccessKey: "secret", SessionToken: "token"},
+		},
+		{
+			name:        "custom keys missing",
+			parameters:  map[string]string{"accessKey": "id", "secretKey": "key"},
+			credentials: map[string]string{"AWS_ACCESS_KEY_ID": "access", "AWS_SECRET_ACCESS_KEY": "secret"},
+		},
+		{
+			name:        "secret key missing",
+			credentials: map[string]string{"AWS_ACCESS_KEY_ID": "access", "AWS_SESSION_TOKEN": "token"},
+		},
+		{
+			name: "no credentials",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			creds := staticS3Credentials(tc.parameters, tc.credentials)
+			switch {
+			case tc.expected == nil && creds != nil:
+				t.Error("static credentials are used instead of the default chain")
+			case tc.expected != nil && creds == nil:
+				t.Error("default chain is used instead of static credentials")
+			case tc.expected != nil:
+				value, err := creds.Get()
+				if err != nil {
+					t.Fatal(err)
+				}
+				tc.expected.ProviderName = credentials.StaticProviderName
+				if value != *tc.expected {
+					t.Errorf("got %+v, expected %+v", value, *tc.expected)
+				}
+			}
+		})
+	}
+}
+
+func TestS3RoleCredentials(t *testing.T) {
+	tokenFile := filepath.Join(t.TempDir(), "token")
+	if err := os.WriteFile(tokenFile, []byte("web-identity-token"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	for _, tc := range []struct {
+		name        string
+		parameters  map[string]string
+		credentials map[string]string
+		provider    string
+		// Expected STS request
+		form                    map[string]string
+		accessKey, sessionToken string
+	}{
+		{
+			name:        "assume role with static credentials",
+			parameters:  map[string]string{"roleArn": "arn:aws:iam::123456789012:role/backup"},
+			credentials: map[string]string{"AWS_ACCESS_KEY_ID": "base", "AWS_SECRET_ACCESS_KEY": "base-secret"},
+			provider:    stscreds.ProviderName,
+			form: map[string]string{
+				"Action": "AssumeRole", "RoleArn": "arn:aws:iam::123456789012:role/backup",
+				"RoleSessionName": "backup-operator", "DurationSeconds": "3600", "ExternalId": "",
+			},
+			accessKey: "base",
+		},
+		{
+			name: "assume role with session token and external ID",
+			parameters: map[string]string{
+				"roleArn": "arn:aws:iam::123456789012:role/backup", "externalId": "tenant",
+				"roleSessionName": "tenant-backup", "roleDuration": "30m",
+			},
+			credentials: map[string]string{
+				"AWS_ACCESS_KEY_ID": "base", "AWS_SECRET_ACCESS_KEY": "base-secret", "AWS_SESSION_TOKEN": "base-token",
+			},
+			provider: stscreds.ProviderName,
+			form: map[string]string{
+				"Action": "AssumeRole", "RoleArn": "arn:aws:iam::123456789012:role/backup",
+				"RoleSessionName": "tenant-backup", "DurationSeconds": "1800", "ExternalId": "tenant",
+			},
+			accessKey:    "base",
+			sessionToken: "base-token",
+		},
+		{
+			name: "web identity",
+			parameters: map[string]string{
+				"roleArn": "arn:aws:iam::123456789012:role/backup", "webIdentityTokenFile": tokenFile,
+			},
+			provider: stscreds.WebIdentityProviderName,
+			form: map[string]string{
+				"Action": "AssumeRoleWithWebIdentity", "RoleArn": "arn:aws:iam::123456789012:role/backup",
+				"RoleSessionName": "backup-operator", "DurationSeconds": "3600", "WebIdentityToken": "web-identity-token",
+			},
+		},
+		{
+			// Static credentials are not needed to exchange the token
+			name: "web identity with static credentials",
+			parameters: map[string]string{
+				"roleArn": "arn:aws:iam::123456789012:role/backup", "webIdentityTokenFile": tokenFile,
+			},
+			credentials: map[string]string{"AWS_ACCESS_KEY_ID": "base", "AWS_SECRET_ACCESS_KEY": "base-secret"},
+			provider:    stscreds.WebIdentityProviderName,
+			form: map[string]string{
+				"Action": "AssumeRoleWithWebIdentity", "WebIdentityToken": "web-identity-token",
+			},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			s3Fake, stsFake := newFakeS3(t), newFakeSTS(t)
+			tc.parameters["bucket"] = "backups"
+			tc.parameters["endpoint"] = s3Fake.URL
+			tc.parameters["insecure"] = "true"
+			tc.parameters["s3ForcePathStyle"] = "true"
+			tc.parameters["region"] = "us-east-1"
+			tc.parameters["stsEndpoint"] = stsFake.URL
+			s := newConfiguredProvider(t, "s3", "s3", tc.parameters, tc.credentials).(*S3Storage)
+			value, err := s.credentials.Get()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if value.ProviderName != tc.provider || value.AccessKeyID != "assumed" || value.SessionToken != "assumed-token" {
+				t.Errorf("got credentials of %s with access key %s, expected assumed ones of %s",
+					value.ProviderName, value.AccessKeyID, tc.provider)
+			}
+			stsFake.mutex.Lock()
+			defer stsFake.mutex.Unlock()
+			for key, expected := range tc.form {
+				if actual := stsFake.form.Get(key); actual != expected {
+					t.Errorf("STS request has %s %q, expected %q", key, actual, expected)
+				}
+			}
+			if stsFake.accessKey != tc.accessKey || stsFake.sessionToken != tc.sessionToken {
+				t.Errorf("STS request is signed with %q and session token %q, expected %q and %q",
+					stsFake.accessKey, stsFake.sessionToken, tc.accessKey, tc.sessionToken)
+			}
+			// Connection check is done with the assumed role
+			s3Fake.mutex.Lock()
+			defer s3Fake.mutex.Unlock()
+			if s3Fake.accessKey != "assumed" {
+				t.Errorf("S3 request is signed with %q", s3Fake.accessKey)
+			}
+		})
+	}
+}
+
+func TestS3DeleteObjectLock(t *testing.T) {
+	ctx := context.Background()
+	for _, tc := range []struct {
+		name       string
+		objectLock bool
+		object     *fakeS3Object
+		// Deletion error is ErrObjectLocked or another one
+		locked, failed bool
+	}{
+		{name: "unlocked", objectLock: true, object: &fakeS3Object{}},
+		{name: "expired retention", objectLock: true, object: &fakeS3Object{retainUntil: time.Now().Add(-time.Hour)}},
+		{name: "missing", objectLock: true},
+		{name: "retention", objectLock: true, object: &fakeS3Object{retainUntil: time.Now().Add(time.Hour)}, locked: true},
+		{name: "legal hold", objectLock: true, object: &fakeS3Object{legalHold: true}, locked: true},
+		// Access denied by the policy is not mistaken for the lock
+		{name: "denied by policy", objectLock: true, object: &fakeS3Object{denied: true}, failed: true},
+		{name: "denied without object lock", object: &fakeS3Object{denied: true}, failed: true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			fake := newFakeS3(t)
+			fake.objectLock = tc.objectLock
+			if tc.object != nil {
+				fake.objects["mysql/db.gz"] = tc.object
+			}
+			s := newFakeS3Provider(t, fake, map[string]string{})
+			if s.objectLockEnabled != tc.objectLock {
+				t.Fatalf("object lock is detected as %t", s.objectLockEnabled)
+			}
+			err := s.Delete(ctx, "mysql/db.gz")
+			switch {
+			case tc.locked && !errors.Is(err, backupstorage.ErrObjectLocked):
+				t.Fatalf("got error %v instead of locked object", err)
+			case tc.failed && (err == nil || errors.Is(err, backupstorage.ErrObjectLocked)):
+				t.Fatalf("got error %v instead of access denied", err)
+			case !tc.locked && !tc.failed && err != nil:
+				t.Fatal(err)
+			}
+			fake.mutex.Lock()
+			defer fake.mutex.Unlock()
+			version, deleted := fake.deletedVersions["mysql/db.gz"]
+			switch {
+			case (tc.locked || tc.failed) && fake.objects["mysql/db.gz"] == nil:
+				t.Error("object has been deleted")
+			case tc.object != nil && !tc.locked && !tc.failed && !deleted:
+				t.Error("object has not been deleted")
+			// Exact version is deleted, otherwise only a delete marker would be added
+			case deleted && version != "version":
+				t.Errorf("got deleted version %q", version)
+			}
+		})
+	}
+}
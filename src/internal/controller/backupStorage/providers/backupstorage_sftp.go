@@ -0,0 +1,391 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package backupstorageproviders
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net"
+	"os"
+	posixpath "path"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/sftp"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+
+	backupoperatoriov1 "backup-operator.io/api/v1"
+	backupstorage "backup-operator.io/internal/controller/backupStorage"
+	"backup-operator.io/internal/controller/utils"
+)
+
+// SFTPStorage is an implementation of BackupStorage for SSH File Transfer Protocol servers.
+type SFTPStorage struct {
+	Host     string
+	Port     uint16 `default:"22"`
+	User     string
+	Path     string        `default:"/"`
+	Insecure bool          `default:"false"`
+	Timeout  time.Duration `default:"30s"`
+
+	config *ssh.ClientConfig
+	// Guards connection fields below, they are recreated on connection loss
+	mutex     sync.Mutex
+	sshClient *ssh.Client
+	client    *sftp.Client
+	// Underlying Kubernetes object
+	object *backupoperatoriov1.BackupStorage
+}
+
+func init() {
+	backupstorage.RegisterProvider(backupstorage.ProviderRegistration{
+		Type: "sftp",
+		Name: "SFTP",
+		Parameters: []backupstorage.ProviderParameter{
+			{Name: "host", Description: "Server hostname or address", Required: true},
+			{Name: "port", Description: "Server port, defaults to 22"},
+			{Name: "user", Description: "Username to log in with", Required: true},
+			{Name: "path", Description: "Absolute path to the root directory for backups, defaults to /"},
+			{Name: "knownHosts", Description: "Server host keys in known_hosts format"},
+			{Name: "insecure", Description: "Set to true to skip host key verification"},
+			{Name: "timeout", Description: "Connection timeout, defaults to 30s"},
+			{Name: "passwordKey", Description: "Key in credentials secret with password, defaults to SFTP_PASSWORD"},
+			{Name: "privateKeyKey", Description: "Key in credentials secret with private key, defaults to SFTP_PRIVATE_KEY"},
+			{Name: "passphraseKey", Description: "Key in credentials secret with private key passphrase, defaults to SFTP_PRIVATE_KEY_PASSPHRASE"},
+		},
+		Factory: func() backupstorage.BackupStorageProvider { return &SFTPStorage{} },
+	})
+}
+
+// Constructor Configure SFTP
+func (s *SFTPStorage) Constructor(object *backupoperatoriov1.BackupStorage, parameters map[string]string, creds map[string]string) (err error) {
+	// Set object field
+	if object == nil {
+		return errors.New("object is mandatory but nil")
+	}
+	s.object = object
+	// Check parameters have all mandatory keys
+	for _, key := range []string{"host", "user"} {
+		if _, ok := parameters[key]; !ok {
+			return fmt.Errorf("mandatory %s parameter is not defined", key)
+		}
+	}
+	// Parse parameters
+	var knownHosts string
+	for key, value := range parameters {
+		switch key {
+		case "host":
+			s.Host = value
+		case "port":
+			var port uint64
+			if port, err = strconv.ParseUint(value, 10, 16); err != nil {
+				return fmt.Errorf("failed to parse port: %s", err)
+			}
+			s.Port = uint16(port)
+		case "user":
+			s.User = value
+		case "path":
+			if !posixpath.IsAbs(value) {
+				return fmt.Errorf("path must be absolute: %s", value)
+			}
+			s.Path = posixpath.Clean(value)
+		case "knownHosts":
+			knownHosts = value
+		case "insecure":
+			if s.Insecure, err = strconv.ParseBool(value); err != nil {
+				return
+			}
+		case "timeout":
+			if s.Timeout, err = time.ParseDuration(value); err != nil {
+				return fmt.Errorf("failed to parse timeout: %s", err)
+			}
+		}
+	}
+	// Prepare authentication methods from credentials
+	var auth []ssh.AuthMethod
+	if auth, err = s.authMethods(parameters, creds); err != nil {
+		return
+	}
+	// Prepare host key verification
+	var hostKeyCallback ssh.HostKeyCallback
+	switch {
+	case len(knownHosts) > 0:
+		if hostKeyCallback, err = parseKnownHosts(knownHosts); err != nil {
+			return
+		}
+	case s.Insecure:
+		// nosemgrep: go.lang.security.audit.crypto.insecure_ssh.avoid-ssh-insecure-ignore-host-key
+		hostKeyCallback = ssh.InsecureIgnoreHostKey()
+	default:
+		return errors.New("knownHosts parameter is mandatory unless insecure is set to true")
+	}
+	s.config = &ssh.ClientConfig{
+		User:            s.User,
+		Auth:            auth,
+		HostKeyCallback: hostKeyCallback,
+		Timeout:         s.Timeout,
+	}
+	// Check the connection, every configuration gets a new object, so the previous connection is left to its running calls
+	var client *sftp.Client
+	if client, err = s.connect(); err != nil {
+		return fmt.Errorf("failed to test SFTP connection: %s", err)
+	}
+	if _, err = client.Stat(s.Path); err != nil {
+		return fmt.Errorf("failed to stat path %s: %s", s.Path, err)
+	}
+	return
+}
+
+// authMethods reads password and private key from credentials.
+// Secret keys may be overridden with passwordKey, privateKeyKey and passphraseKey parameters.
+func (s *SFTPStorage) authMethods(parameters map[string]string, creds map[string]string) (auth []ssh.AuthMethod, err error) {
+	keyName := func(parameter, fallback string) string {
+		if name, ok := parameters[parameter]; ok {
+			return name
+		}
+		return fallback
+	}
+	if privateKey, ok := creds[keyName("privateKeyKey", "SFTP_PRIVATE_KEY")]; ok {
+		var signer ssh.Signer
+		if passphrase, ok := creds[keyName("passphraseKey", "SFTP_PRIVATE_KEY_PASSPHRASE")]; ok {
+			signer, err = ssh.ParsePrivateKeyWithPassphrase([]byte(privateKey), []byte(passphrase))
+		} else {
+			signer, err = ssh.ParsePrivateKey([]byte(privateKey))
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse private key: %s", err)
+		}
+		auth = append(auth, ssh.PublicKeys(signer))
+	}
+	if password, ok := creds[keyName("passwordKey", "SFTP_PASSWORD")]; ok {
+		auth = append(auth, ssh.Password(password))
+	}
+	if len(auth) == 0 {
+		return nil, errors.New("neither password nor private key is found in credentials")
+	}
+	return
+}
+
+// parseKnownHosts creates host key callback from known_hosts formatted content
+func parseKnownHosts(content string) (callback ssh.HostKeyCallback, err error) {
+	// knownhosts package reads files only, so we put content to a temporary one
+	var file *os.File
+	if file, err = os.CreateTemp("", "known_hosts-*"); err != nil {
+		return nil, fmt.Errorf("failed to create known hosts file: %s", err)
+	}
+	defer os.Remove(file.Name())
+	if _, err = file.WriteString(content); err != nil {
+		file.Close()
+		return nil, fmt.Errorf("failed to write known hosts file: %s", err)
+	}
+	file.Close()
+	if callback, err = knownhosts.New(file.Name()); err != nil {
+		return nil, fmt.Errorf("failed to parse known hosts: %s", err)
+	}
+	return
+}
+
+// connect returns the current SFTP client or dials a new one if there is no connection
+func (s *SFTPStorage) connect() (client *sftp.Client, err error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.client != nil {
+		return s.client, nil
+	}
+	var sshClient *ssh.Client
+	address := net.JoinHostPort(s.Host, strconv.Itoa(int(s.Port)))
+	if sshClient, err = ssh.Dial("tcp", address, s.config); err != nil {
+		return nil, fmt.Errorf("failed to dial %s: %s", address, err)
+	}
+	if client, err = sftp.NewClient(sshClient, sftp.UseConcurrentWrites(true)); err != nil {
+		sshClient.Close()
+		return nil, fmt.Errorf("failed to start SFTP subsystem: %s", err)
+	}
+	s.sshClient, s.client = sshClient, client
+	// Forget the connection once it is lost, so the next call will dial again
+	go func() {
+		_ = sshClient.Wait()
+		s.mutex.Lock()
+		defer s.mutex.Unlock()
+		if s.sshClient == sshClient {
+			s.sshClient, s.client = nil, nil
+		}
+	}()
+	return
+}
+
+// disconnect closes the current connection if any
+func (s *SFTPStorage) disconnect() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.client != nil {
+		s.client.Close()
+	}
+	if s.sshClient != nil {
+		s.sshClient.Close()
+	}
+	s.sshClient, s.client = nil, nil
+}
+
+// Destructor SFTP storage object destructor.
+func (s *SFTPStorage) Destructor() error {
+	s.disconnect()
+	return nil
+}
+
+// Put Upload file. Metadata is not kept.
+func (s *SFTPStorage) Put(ctx context.Context, path string, reader io.Reader, _ map[string]string) (err error) {
+	var client *sftp.Client
+	if client, err = s.connect(); err != nil {
+		return
+	}
+	fullPath := s.fullPath(path)
+	if err = client.MkdirAll(posixpath.Dir(fullPath)); err != nil {
+		return
+	}
+	var file *sftp.File
+	if file, err = client.Create(fullPath); err != nil {
+		return
+	}
+	if err = utils.PipeCopy(ctx, reader, file); err != nil {
+		// Do not leave truncated files behind
+		file.Close()
+		client.Remove(fullPath)
+		return
+	}
+	return file.Close()
+}
+
+// Get Download file.
+func (s *SFTPStorage) Get(_ context.Context, path string) (io.ReadCloser, error) {
+	client, err := s.connect()
+	if err != nil {
+		return nil, err
+	}
+	return client.Open(s.fullPath(path))
+}
+
+// List path.
+func (s *SFTPStorage) List(_ context.Context, path string) (list []string, err error) {
+	var client *sftp.Client
+	if client, err = s.connect(); err != nil {
+		return
+	}
+	// Only the directory of the path is walked, missing one has no files
+	root := s.fullPath(path)
+	if !strings.HasSuffix(path, "/") && root != s.fullPath("/") {
+		root = posixpath.Dir(root)
+	}
+	walker := client.Walk(root)
+	for walker.Step() {
+		if err = walker.Err(); errors.Is(err, fs.ErrNotExist) && walker.Path() == root {
+			return nil, nil
+		} else if err != nil {
+			return
+		}
+		if walker.Stat().IsDir() {
+			continue
+		}
+		// Keys are slash separated and start with slash like in run .spec.storage.path
+		key := "/" + strings.TrimPrefix(strings.TrimPrefix(walker.Path(), s.Path), "/")
+		if strings.HasPrefix(key, path) {
+			list = append(list, key)
+		}
+	}
+	return
+}
+
+// Delete Remove path
+func (s *SFTPStorage) Delete(_ context.Context, path string) (err error) {
+	var client *sftp.Client
+	if client, err = s.connect(); err != nil {
+		return
+	}
+	fullPath := s.fullPath(path)
+	if err = client.Remove(fullPath); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return
+	}
+	s.removeEmptyParents(client, fullPath)
+	return nil
+}
+
+// Promote Rename file. Plain SFTP rename fails if the target exists, so POSIX rename extension
+// replacing it atomically is preferred, otherwise the target is removed first. Metadata is not kept.
+func (s *SFTPStorage) Promote(_ context.Context, from, to string, _ map[string]string, _ backupstorage.PutOptions) (err error) {
+	var client *sftp.Client
+	if client, err = s.connect(); err != nil {
+		return
+	}
+	fromPath, toPath := s.fullPath(from), s.fullPath(to)
+	if err = client.MkdirAll(posixpath.Dir(toPath)); err != nil {
+		return
+	}
+	if _, supported := client.HasExtension("posix-rename@openssh.com"); supported {
+		err = client.PosixRename(fromPath, toPath)
+	} else {
+		if err = client.Remove(toPath); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return
+		}
+		err = client.Rename(fromPath, toPath)
+	}
+	if err != nil {
+		return
+	}
+	s.removeEmptyParents(client, fromPath)
+	return nil
+}
+
+// removeEmptyParents Clean up empty parent directories, but never the root one
+func (s *SFTPStorage) removeEmptyParents(client *sftp.Client, fullPath string) {
+	for dir := posixpath.Dir(fullPath); dir != s.Path && strings.HasPrefix(dir, s.Path); dir = posixpath.Dir(dir) {
+		if client.RemoveDirectory(dir) != nil {
+			break
+		}
+	}
+}
+
+// Get underlying Kubernetes object
+func (s *SFTPStorage) GetObject() *backupoperatoriov1.BackupStorage {
+	return s.object
+}
+
+// Stat Get file size and modification time
+func (s *SFTPStorage) Stat(_ context.Context, path string) (info backupstorage.ObjectInfo, err error) {
+	var client *sftp.Client
+	if client, err = s.connect(); err != nil {
+		return
+	}
+	var fileInfo fs.FileInfo
+	if fileInfo, err = client.Stat(s.fullPath(path)); err != nil {
+		return
+	}
+	info.Size = uint(fileInfo.Size())
+	info.ModTime = fileInfo.ModTime()
+	return
+}
+
+// fullPath converts backup path to the path on the server.
+// Path is cleaned as an absolute one first, so it never escapes the root directory.
+func (s *SFTPStorage) fullPath(path string) string {
+	return posixpath.Join(s.Path, posixpath.Clean("/"+path))
+}
@@ -0,0 +1,239 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package backupstorageproviders
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"errors"
+	"io"
+	"net"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/creasty/defaults"
+	"github.com/pkg/sftp"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+
+	backupstorage "backup-operator.io/internal/controller/backupStorage"
+)
+
+// newTestSFTPServer Serve SFTP subsystem with password authentication, returns address and known_hosts line
+func newTestSFTPServer(t *testing.T, password string) (host, port, knownHostsLine string) {
+	t.Helper()
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signer, err := ssh.NewSignerFromKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	config := &ssh.ServerConfig{
+		PasswordCallback: func(_ ssh.ConnMetadata, given []byte) (*ssh.Permissions, error) {
+			if string(given) != password {
+				return nil, errors.New("wrong password")
+			}
+			return nil, nil
+		},
+	}
+	config.AddHostKey(signer)
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go serveSFTP(conn, config)
+		}
+	}()
+	address := listener.Addr().String()
+	host, port, _ = net.SplitHostPort(address)
+	return host, port, knownhosts.Line([]string{knownhosts.Normalize(address)}, signer.PublicKey())
+}
+
+// serveSFTP Serve sessions of the SSH connection with SFTP subsystem only
+func serveSFTP(conn net.Conn, config *ssh.ServerConfig) {
+	serverConn, channels, requests, err := ssh.NewServerConn(conn, config)
+	if err != nil {
+		conn.Close()
+		return
+	}
+	defer serverConn.Close()
+	go ssh.DiscardRequests(requests)
+	for newChannel := range channels {
+		if newChannel.ChannelType() != "session" {
+			newChannel.Reject(ssh.UnknownChannelType, "only sessions are supported")
+			continue
+		}
+		channel, channelRequests, err := newChannel.Accept()
+		if err != nil {
+			return
+		}
+		go func() {
+			for request := range channelRequests {
+				// Payload is the length prefixed subsystem name
+				isSFTP := request.Type == "subsystem" && len(request.Payload) > 4 && string(request.Payload[4:]) == "sftp"
+				request.Reply(isSFTP, nil)
+				if isSFTP {
+					server, err := sftp.NewServer(channel)
+					if err == nil {
+						server.Serve()
+						server.Close()
+					}
+					channel.Close()
+				}
+			}
+		}()
+	}
+}
+
+func TestSFTPRoundTrip(t *testing.T) {
+	host, port, knownHostsLine := newTestSFTPServer(t, "secret")
+	dir := t.TempDir()
+	s := newConfiguredProvider(t, "sftp", "sftp", map[string]string{
+		"host": host, "port": port, "user": "backup", "path": dir, "knownHosts": knownHostsLine,
+	}, map[string]string{"SFTP_PASSWORD": "secret"}).(*SFTPStorage)
+	defer s.Destructor()
+	ctx := context.Background()
+	data := bytes.Repeat([]byte("backup-operator"), 100000)
+	for _, path := range []string{"/mysql/backup.sql", "/mysql/nested/backup.sql", "/postgresql/backup.sql"} {
+		if err := s.Put(ctx, path, bytes.NewReader(data), nil); err != nil {
+			t.Fatalf("failed to put %s: %s", path, err)
+		}
+	}
+	reader, err := s.Get(ctx, "/mysql/backup.sql")
+	if err != nil {
+		t.Fatalf("failed to get: %s", err)
+	}
+	read, err := io.ReadAll(reader)
+	reader.Close()
+	if err != nil || !bytes.Equal(read, data) {
+		t.Errorf("downloaded file differs from the uploaded one, error %v", err)
+	}
+	if info, err := s.Stat(ctx, "/mysql/backup.sql"); err != nil || info.Size != uint(len(data)) {
+		t.Errorf("got %+v and error %v, expected size %d", info, err, len(data))
+	}
+	// Walk order is not sorted
+	list, err := s.List(ctx, "/mysql/")
+	if slices.Sort(list); err != nil || !slices.Equal(list, []string{"/mysql/backup.sql", "/mysql/nested/backup.sql"}) {
+		t.Errorf("got list %v and error %v", list, err)
+	}
+	if list, err := s.List(ctx, "/missing/"); err != nil || len(list) != 0 {
+		t.Errorf("got list %v and error %v for missing directory", list, err)
+	}
+	// Paths never escape the root directory
+	if err = s.Put(ctx, "/../escaped.sql", bytes.NewReader(data), nil); err != nil {
+		t.Fatalf("failed to put: %s", err)
+	}
+	if _, err = s.Stat(ctx, "/escaped.sql"); err != nil {
+		t.Errorf("file is not kept in the root directory: %s", err)
+	}
+	if err = s.Promote(ctx, "/mysql/backup.sql", "/mysql/promoted.sql", nil, backupstorage.PutOptions{}); err != nil {
+		t.Fatalf("failed to promote: %s", err)
+	}
+	if _, err = s.Stat(ctx, "/mysql/backup.sql"); err == nil {
+		t.Error("promoted file is still at the old path")
+	}
+	for _, path := range []string{"/mysql/promoted.sql", "/mysql/promoted.sql", "/mysql/nested/backup.sql"} {
+		if err = s.Delete(ctx, path); err != nil {
+			t.Errorf("failed to delete %s: %s", path, err)
+		}
+	}
+	list, err = s.List(ctx, "/")
+	if slices.Sort(list); err != nil || !slices.Equal(list, []string{"/escaped.sql", "/postgresql/backup.sql"}) {
+		t.Errorf("got list %v and error %v after deletion", list, err)
+	}
+}
+
+func TestSFTPReconfiguration(t *testing.T) {
+	host, port, knownHostsLine := newTestSFTPServer(t, "secret")
+	dir := t.TempDir()
+	credentials := map[string]string{"SFTP_PASSWORD": "secret"}
+	parameters := map[string]string{
+		"host": host, "port": port, "user": "backup", "path": dir, "insecure": "true", "timeout": "5s",
+	}
+	first := newConfiguredProvider(t, "sftp", "sftp", parameters, credentials).(*SFTPStorage)
+	defer first.Destructor()
+	ctx := context.Background()
+	if err := first.Put(ctx, "/backup.sql", strings.NewReader("backup"), nil); err != nil {
+		t.Fatalf("failed to put: %s", err)
+	}
+
+	// Host key verification is on again once insecure is removed
+	delete(parameters, "insecure")
+	delete(parameters, "timeout")
+	delete(parameters, "path")
+	second := &SFTPStorage{}
+	if err := defaults.Set(second); err != nil {
+		t.Fatal(err)
+	}
+	if err := second.Constructor(testStorageObject("sftp"), parameters, credentials); err == nil ||
+		!strings.Contains(err.Error(), "knownHosts") {
+		t.Errorf("got %v, expected knownHosts to be mandatory", err)
+	}
+	if second.Insecure || second.Timeout.String() != "30s" || second.Path != "/" {
+		t.Errorf("removed parameters are kept: insecure %t, timeout %s, path %s", second.Insecure, second.Timeout, second.Path)
+	}
+	// Host key of another server is rejected
+	_, _, otherKnownHostsLine := newTestSFTPServer(t, "secret")
+	address, otherAddress := strings.Fields(knownHostsLine)[0], strings.Fields(otherKnownHostsLine)[0]
+	parameters["knownHosts"] = strings.Replace(otherKnownHostsLine, otherAddress, address, 1)
+	if _, err := configureProvider("sftp", "sftp", parameters, credentials); err == nil {
+		t.Error("provider has been configured with wrong host key")
+	}
+	parameters["knownHosts"] = knownHostsLine
+	parameters["path"] = dir
+	third := newConfiguredProvider(t, "sftp", "sftp", parameters, credentials).(*SFTPStorage)
+	defer third.Destructor()
+	// The old connection is not closed by the new configuration
+	for _, provider := range []*SFTPStorage{first, third} {
+		if _, err := provider.Stat(ctx, "/backup.sql"); err != nil {
+			t.Errorf("failed to stat: %s", err)
+		}
+	}
+}
+
+func TestSFTPInvalidConfiguration(t *testing.T) {
+	host, port, knownHostsLine := newTestSFTPServer(t, "secret")
+	for _, c := range []struct {
+		parameters  map[string]string
+		credentials map[string]string
+	}{
+		{map[string]string{"user": "backup", "insecure": "true"}, map[string]string{"SFTP_PASSWORD": "secret"}},
+		{map[string]string{"host": host, "port": port, "insecure": "true"}, map[string]string{"SFTP_PASSWORD": "secret"}},
+		{map[string]string{"host": host, "port": "65536", "user": "backup", "insecure": "true"}, map[string]string{"SFTP_PASSWORD": "secret"}},
+		{map[string]string{"host": host, "port": port, "user": "backup", "path": "relative", "insecure": "true"}, map[string]string{"SFTP_PASSWORD": "secret"}},
+		{map[string]string{"host": host, "port": port, "user": "backup", "knownHosts": knownHostsLine}, nil},
+		{map[string]string{"host": host, "port": port, "user": "backup", "knownHosts": knownHostsLine}, map[string]string{"SFTP_PASSWORD": "wrong"}},
+		{map[string]string{"host": host, "port": port, "user": "backup", "knownHosts": knownHostsLine}, map[string]string{"SFTP_PRIVATE_KEY": "invalid"}},
+	} {
+		if provider, err := configureProvider("sftp", "sftp", c.parameters, c.credentials); err == nil {
+			provider.Destructor()
+			t.Errorf("%v: provider has been configured", c.parameters)
+		}
+	}
+}
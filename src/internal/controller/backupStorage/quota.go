@@ -0,0 +1,115 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package backupstorage
+
+import (
+	"fmt"
+	"strings"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/ptr"
+
+	backupoperatoriov1 "backup-operator.io/api/v1"
+	"backup-operator.io/internal/controller/utils"
+)
+
+// CheckQuota Check storage usage against its quota, so new backups have to wait. Runs waiting
+// for the quota are not counted, since they have not stored anything yet.
+func CheckQuota(storage *backupoperatoriov1.BackupStorage, runs []backupoperatoriov1.BackupRun) (exceeded bool, message string) {
+	return checkQuota(storage, runs, nil)
+}
+
+// CheckRunQuota Check whether the backup of the run has to wait for the quota. Waiting runs
+// are released oldest first while the storage has room for them.
+func CheckRunQuota(storage *backupoperatoriov1.BackupStorage, runs []backupoperatoriov1.BackupRun,
+	run *backupoperatoriov1.BackupRun,
+) (exceeded bool, message string) {
+	return checkQuota(storage, runs, run)
+}
+
+// checkQuota Check usage of the storage by the runs, message describes exceeded limits.
+// Runs waiting ahead of the run take the room as well, if the run is set.
+func checkQuota(storage *backupoperatoriov1.BackupStorage, runs []backupoperatoriov1.BackupRun,
+	run *backupoperatoriov1.BackupRun,
+) (exceeded bool, message string) {
+	quota := storage.Spec.Quota
+	if quota == nil {
+		return
+	}
+	var reasons []string
+	// Size limit is reached when the storage is full
+	size := ptr.Deref(storage.Status.SizeInBytes, 0)
+	if quota.MaxSize != nil && int64(size) >= quota.MaxSize.Value() {
+		reasons = append(reasons, fmt.Sprintf("size %s reached the limit of %s",
+			utils.ConvertBytesToHumanReadable(size), quota.MaxSize.String()))
+	}
+	// Count limit is reached when there is no room for one more backup
+	if quota.MaxRuns != nil {
+		var stored, ahead uint16
+		for i := range runs {
+			switch other := &runs[i]; {
+			case !isWaitingForBackup(other):
+				stored++
+			case !other.DeletionTimestamp.IsZero():
+				// Deleted run never starts its backup
+			case run != nil && other.UID != run.UID && isQueuedBefore(other, run):
+				ahead++
+			}
+		}
+		if stored+ahead >= *quota.MaxRuns {
+			if ahead > 0 {
+				reasons = append(reasons, fmt.Sprintf("%d runs and %d runs waiting ahead reached the limit of %d",
+					stored, ahead, *quota.MaxRuns))
+			} else {
+				reasons = append(reasons, fmt.Sprintf("%d runs reached the limit of %d", stored, *quota.MaxRuns))
+			}
+		}
+	}
+	if len(reasons) == 0 {
+		return
+	}
+	return true, fmt.Sprintf("storage %s is over quota: %s", storage.Name, strings.Join(reasons, ", "))
+}
+
+// isWaitingForBackup Check the run has a backup to make, but has not started it yet
+func isWaitingForBackup(run *backupoperatoriov1.BackupRun) bool {
+	if run.Spec.Backup == nil {
+		return false
+	}
+	if _, imported := run.GetAnnotations()[backupoperatoriov1.AnnotationImportedFrom]; imported {
+		return false
+	}
+	for _, conditionType := range []backupoperatoriov1.BackupRunConditionType{
+		backupoperatoriov1.BackupRunConditionTypeInProgress,
+		backupoperatoriov1.BackupRunConditionTypeSuccessful,
+		backupoperatoriov1.BackupRunConditionTypeFailed,
+	} {
+		if condition, _ := utils.GetConditionByType(&run.Status.Conditions, string(conditionType)); condition != nil &&
+			condition.Status == metav1.ConditionTrue {
+			return false
+		}
+	}
+	return true
+}
+
+// isQueuedBefore Check the run has been created before the other one, names break ties
+func isQueuedBefore(run, other *backupoperatoriov1.BackupRun) bool {
+	if !run.CreationTimestamp.Equal(&other.CreationTimestamp) {
+		return run.CreationTimestamp.Before(&other.CreationTimestamp)
+	}
+	return run.Namespace+"/"+run.Name < other.Namespace+"/"+other.Name
+}
@@ -0,0 +1,120 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package backupstorage
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/utils/ptr"
+
+	backupoperatoriov1 "backup-operator.io/api/v1"
+)
+
+// quotaStorage Make a storage with the quota set from JSON, since its type is not exported
+func quotaStorage(t *testing.T, quota string) *backupoperatoriov1.BackupStorage {
+	t.Helper()
+	storage := &backupoperatoriov1.BackupStorage{ObjectMeta: metav1.ObjectMeta{Name: "quota"}}
+	if err := json.Unmarshal([]byte(fmt.Sprintf(`{"quota": %s}`, quota)), &storage.Spec); err != nil {
+		t.Fatal(err)
+	}
+	return storage
+}
+
+// quotaRun Make a backup run created minutes after the epoch with the condition set, if any
+func quotaRun(name string, minutes int, condition backupoperatoriov1.BackupRunConditionType) backupoperatoriov1.BackupRun {
+	run := backupoperatoriov1.BackupRun{ObjectMeta: metav1.ObjectMeta{
+		Name:              name,
+		Namespace:         "default",
+		UID:               types.UID(name),
+		CreationTimestamp: metav1.NewTime(time.Unix(0, 0).Add(time.Duration(minutes) * time.Minute)),
+	}}
+	run.Spec.Backup = &backupoperatoriov1.BackupRunAction{}
+	if len(condition) > 0 {
+		run.Status.Conditions = []metav1.Condition{{Type: string(condition), Status: metav1.ConditionTrue}}
+	}
+	return run
+}
+
+func TestCheckRunQuota(t *testing.T) {
+	runs := []backupoperatoriov1.BackupRun{
+		quotaRun("successful", 0, backupoperatoriov1.BackupRunConditionTypeSuccessful),
+		quotaRun("failed", 1, backupoperatoriov1.BackupRunConditionTypeFailed),
+		quotaRun("in-progress", 2, backupoperatoriov1.BackupRunConditionTypeInProgress),
+		// Held runs in the order they have been created, names break the tie
+		quotaRun("held-b", 4, backupoperatoriov1.BackupRunConditionTypeQuotaExceeded),
+		quotaRun("held-a", 4, backupoperatoriov1.BackupRunConditionTypeQuotaExceeded),
+		quotaRun("held-c", 5, backupoperatoriov1.BackupRunConditionTypeQuotaExceeded),
+		quotaRun("new", 6, ""),
+	}
+	deleted := quotaRun("deleted", 3, backupoperatoriov1.BackupRunConditionTypeQuotaExceeded)
+	deleted.DeletionTimestamp = ptr.To(metav1.Now())
+	runs = append(runs, deleted)
+
+	for _, tc := range []struct {
+		maxRuns  int
+		released []string
+	}{
+		{maxRuns: 2},
+		{maxRuns: 3},
+		{maxRuns: 4, released: []string{"held-a"}},
+		{maxRuns: 5, released: []string{"held-a", "held-b"}},
+		{maxRuns: 7, released: []string{"held-a", "held-b", "held-c", "new"}},
+	} {
+		t.Run(fmt.Sprintf("maxRuns %d", tc.maxRuns), func(t *testing.T) {
+			storage := quotaStorage(t, fmt.Sprintf(`{"maxRuns": %d}`, tc.maxRuns))
+			var released []string
+			for i := range runs {
+				if !isWaitingForBackup(&runs[i]) || !runs[i].DeletionTimestamp.IsZero() {
+					continue
+				}
+				if exceeded, _ := CheckRunQuota(storage, runs, &runs[i]); !exceeded {
+					released = append(released, runs[i].Name)
+				}
+			}
+			sort.Strings(released)
+			if fmt.Sprint(released) != fmt.Sprint(tc.released) {
+				t.Errorf("released %v, expected %v", released, tc.released)
+			}
+			// Storage is over quota while completed runs and runs in progress leave no room
+			if exceeded, message := CheckQuota(storage, runs); exceeded != (tc.maxRuns <= 3) {
+				t.Errorf("storage quota exceeded is %t: %s", exceeded, message)
+			}
+		})
+	}
+}
+
+func TestCheckQuotaSize(t *testing.T) {
+	storage := quotaStorage(t, `{"maxSize": "1Ki", "maxRuns": 10}`)
+	storage.Status.SizeInBytes = ptr.To(uint(1023))
+	run := quotaRun("new", 0, "")
+	if exceeded, message := CheckRunQuota(storage, nil, &run); exceeded {
+		t.Errorf("run is held below the size limit: %s", message)
+	}
+	storage.Status.SizeInBytes = ptr.To(uint(1024))
+	if exceeded, _ := CheckRunQuota(storage, nil, &run); !exceeded {
+		t.Error("run is not held at the size limit")
+	}
+	if exceeded, _ := CheckQuota(quotaStorage(t, `null`), nil); exceeded {
+		t.Error("storage without quota is over quota")
+	}
+}
@@ -0,0 +1,118 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package backupstorage
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+
+	backupoperatoriov1 "backup-operator.io/api/v1"
+)
+
+// ProviderParameter describes a single parameter from BackupStorage .spec.parameters
+type ProviderParameter struct {
+	// Parameter key
+	Name string
+	// Short human-readable description
+	Description string
+	// Whether the parameter is mandatory
+	Required bool
+}
+
+// ProviderRegistration describes a storage provider implementation
+type ProviderRegistration struct {
+	// Value of BackupStorage .spec.type
+	Type string
+	// Human-readable provider name
+	Name string
+	// All parameters the provider understands
+	Parameters []ProviderParameter
+	// Unknown parameters are passed through to the provider as is, so they are not reported
+	PassThroughParameters bool
+	// Creates a new provider object, it is configured later with Constructor
+	Factory func() BackupStorageProvider
+	// Optional provider specific validation of parameters, e.g. their values or combinations.
+	// It runs in the webhook, so credentials are not available there.
+	Validate func(parameters map[string]string) (warnings []string, err error)
+}
+
+// All registered provider implementations by type
+var providerRegistrations sync.Map
+
+// RegisterProvider Register provider implementation, it is meant to be called from init functions.
+// Panics if the type is registered twice.
+func RegisterProvider(registration ProviderRegistration) {
+	if _, loaded := providerRegistrations.LoadOrStore(registration.Type, registration); loaded {
+		panic(fmt.Sprintf("storage provider %s is registered twice", registration.Type))
+	}
+}
+
+// GetProviderRegistration Get provider implementation by storage type
+func GetProviderRegistration(storageType string) (registration ProviderRegistration, ok bool) {
+	var value any
+	if value, ok = providerRegistrations.Load(storageType); ok {
+		registration, ok = value.(ProviderRegistration)
+	}
+	return
+}
+
+// ListProviderRegistrations List all provider implementations sorted by type
+func ListProviderRegistrations() (registrations []ProviderRegistration) {
+	providerRegistrations.Range(func(_, value any) bool {
+		registrations = append(registrations, value.(ProviderRegistration))
+		return true
+	})
+	sort.Slice(registrations, func(i, j int) bool {
+		return registrations[i].Type < registrations[j].Type
+	})
+	return
+}
+
+// ValidateProviderSpec Validate storage type and parameters against the registered provider.
+// Unknown parameters are not fatal, they are returned as warnings.
+func ValidateProviderSpec(storageType string, parameters map[string]string) (warnings []string, err error) {
+	registration, ok := GetProviderRegistration(storageType)
+	if !ok {
+		var types []string
+		for _, r := range ListProviderRegistrations() {
+			types = append(types, r.Type)
+		}
+		return nil, fmt.Errorf("%w %s, valid values are %v", backupoperatoriov1.ErrUnknownStorageType, storageType, types)
+	}
+	known := make(map[string]bool, len(registration.Parameters))
+	for _, p := range registration.Parameters {
+		known[p.Name] = true
+		if _, set := parameters[p.Name]; p.Required && !set {
+			return nil, fmt.Errorf("mandatory parameter %s is not defined for %s storage", p.Name, registration.Name)
+		}
+	}
+	for key := range parameters {
+		if !known[key] && !registration.PassThroughParameters {
+			warnings = append(warnings, fmt.Sprintf("parameter %s is unknown to %s storage and is ignored", key, registration.Name))
+		}
+	}
+	sort.Strings(warnings)
+	if registration.Validate != nil {
+		var providerWarnings []string
+		if providerWarnings, err = registration.Validate(parameters); err != nil {
+			return nil, fmt.Errorf("invalid parameters for %s storage: %w", registration.Name, err)
+		}
+		warnings = append(warnings, providerWarnings...)
+	}
+	return
+}
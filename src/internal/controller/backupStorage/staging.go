@@ -0,0 +1,118 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package backupstorage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	backupoperatoriov1 "backup-operator.io/api/v1"
+)
+
+// StagingPrefix Backups are uploaded under this path and promoted to their paths after success
+const StagingPrefix = InternalPrefix + "staging/"
+
+// Staging files of runs that do not exist anymore are looked for with this interval
+const stagingCleanupInterval = time.Hour
+
+// Time of the last staging cleanup by storage UID
+var stagingCleanups = &sync.Map{}
+
+// StagingPath Get path the run uploads its backup to before it is promoted
+func StagingPath(run *backupoperatoriov1.BackupRun) string {
+	return StagingPrefix + string(run.UID)
+}
+
+// UntilNextStagingCleanup Get time left until the next staging cleanup of the storage, it is due if not positive
+func UntilNextStagingCleanup(storage *backupoperatoriov1.BackupStorage) time.Duration {
+	if cleanedAt, cleaned := stagingCleanups.Load(storage.UID); cleaned {
+		return time.Until(cleanedAt.(time.Time).Add(stagingCleanupInterval))
+	}
+	return 0
+}
+
+// ForgetStagingCleanup Drop staging cleanup state of the deleted storage
+func ForgetStagingCleanup(storage *backupoperatoriov1.BackupStorage) {
+	stagingCleanups.Delete(storage.UID)
+}
+
+// CleanStaging Delete staging files left by runs that do not exist anymore, e.g. after the operator restart.
+// Runs of all storages are checked, since storages may share a bucket. The bucket may be shared with other
+// clusters as well, so only files uploaded by the operator for paths under the inventory prefix are deleted,
+// and only after the inventory grace period.
+func CleanStaging(ctx context.Context, c client.Client, storage *backupoperatoriov1.BackupStorage,
+	provider BackupStorageProvider,
+) (deleted []string, err error) {
+	stagingCleanups.Store(storage.UID, time.Now())
+	inventory := storage.Spec.Inventory
+	if inventory == nil {
+		return
+	}
+	var paths []string
+	if paths, err = provider.List(ctx, StagingPrefix); err != nil || len(paths) == 0 {
+		return
+	}
+	runs := &backupoperatoriov1.BackupRunList{}
+	if err = c.List(ctx, runs); err != nil {
+		return nil, fmt.Errorf("could not list runs: %s", err)
+	}
+	uids := make(map[types.UID]bool, len(runs.Items))
+	for _, run := range runs.Items {
+		uids[run.UID] = true
+	}
+	staleBefore := time.Now().Add(-inventory.GracePeriod.Duration)
+	for _, path := range paths {
+		uid := strings.TrimPrefix(path, StagingPrefix)
+		if uids[types.UID(uid)] {
+			continue
+		}
+		// File may have been published by a run of another cluster meanwhile
+		info, statErr := provider.Stat(ctx, path)
+		if statErr != nil || !isStaleStaging(info, uid, inventory.Prefix, staleBefore) {
+			continue
+		}
+		// Staged file may be locked by bucket default retention, it is deleted after it expires
+		if err = provider.Delete(ctx, path); errors.Is(err, ErrObjectLocked) {
+			continue
+		} else if err != nil {
+			return deleted, fmt.Errorf("could not delete staging file %s: %s", path, err)
+		}
+		deleted = append(deleted, path)
+	}
+	return
+}
+
+// isStaleStaging Check the staging file has been uploaded by the operator for a path under the prefix
+// before the time. Owner of the file is unknown if the provider does not keep metadata, so it is
+// taken for the operator's one only if the whole storage is inventoried.
+func isStaleStaging(info ObjectInfo, uid, prefix string, before time.Time) bool {
+	if !info.ModTime.Before(before) {
+		return false
+	}
+	if len(info.Metadata) == 0 {
+		return prefix == "/"
+	}
+	path, hasPath := info.Metadata[MetadataPath]
+	return info.Metadata[MetadataRunUID] == uid && hasPath && strings.HasPrefix(path, prefix)
+}
@@ -0,0 +1,124 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package backupstorage
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	backupoperatoriov1 "backup-operator.io/api/v1"
+)
+
+// stagingProvider Keeps attributes of staging files and records deletions
+type stagingProvider struct {
+	fakeProvider
+	files   map[string]ObjectInfo
+	deleted []string
+}
+
+func (p *stagingProvider) List(context.Context, string) (paths []string, err error) {
+	for path := range p.files {
+		paths = append(paths, path)
+	}
+	return
+}
+func (p *stagingProvider) Delete(_ context.Context, path string) error {
+	p.deleted = append(p.deleted, path)
+	return nil
+}
+func (p *stagingProvider) Stat(_ context.Context, path string) (ObjectInfo, error) {
+	if info, exists := p.files[path]; exists {
+		return info, nil
+	}
+	return ObjectInfo{}, io.EOF
+}
+
+func TestCleanStaging(t *testing.T) {
+	old := time.Now().Add(-48 * time.Hour)
+	staged := func(uid, path string, modTime time.Time) ObjectInfo {
+		return ObjectInfo{ModTime: modTime, Metadata: map[string]string{MetadataRunUID: uid, MetadataPath: path}}
+	}
+	files := map[string]ObjectInfo{
+		// Left by a deleted run
+		StagingPrefix + "deleted": staged("deleted", "/mysql/db.gz", old),
+		// Run exists
+		StagingPrefix + "running": staged("running", "/mysql/db.gz", old),
+		// Backup of another cluster may be still uploading
+		StagingPrefix + "recent": staged("recent", "/mysql/db.gz", time.Now()),
+		// Not under the inventory prefix
+		StagingPrefix + "other-prefix": staged("other-prefix", "/postgres/db.gz", old),
+		// Not uploaded by the operator
+		StagingPrefix + "foreign":   {ModTime: old, Metadata: map[string]string{"owner": "someone"}},
+		StagingPrefix + "other-uid": staged("another", "/mysql/db.gz", old),
+		StagingPrefix + "no-path":   {ModTime: old, Metadata: map[string]string{MetadataRunUID: "no-path"}},
+		// Provider does not keep metadata
+		StagingPrefix + "no-metadata": {ModTime: old},
+	}
+	scheme := runtime.NewScheme()
+	if err := backupoperatoriov1.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(&backupoperatoriov1.BackupRun{
+		ObjectMeta: metav1.ObjectMeta{Name: "running", Namespace: "default", UID: types.UID("running")},
+	}).Build()
+	for _, tc := range []struct {
+		name     string
+		prefix   string
+		expected []string
+	}{
+		{name: "disabled"},
+		{name: "prefix", prefix: "/mysql/", expected: []string{StagingPrefix + "deleted"}},
+		{name: "whole storage", prefix: "/", expected: []string{
+			StagingPrefix + "deleted", StagingPrefix + "no-metadata", StagingPrefix + "other-prefix",
+		}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			storage := &backupoperatoriov1.BackupStorage{ObjectMeta: metav1.ObjectMeta{UID: types.UID(tc.name)}}
+			defer ForgetStagingCleanup(storage)
+			if len(tc.prefix) > 0 {
+				inventory := fmt.Sprintf(`{"inventory": {"prefix": %q, "gracePeriod": "24h"}}`, tc.prefix)
+				if err := json.Unmarshal([]byte(inventory), &storage.Spec); err != nil {
+					t.Fatal(err)
+				}
+			}
+			provider := &stagingProvider{files: files}
+			deleted, err := CleanStaging(context.Background(), c, storage, provider)
+			if err != nil {
+				t.Fatal(err)
+			}
+			sort.Strings(deleted)
+			sort.Strings(provider.deleted)
+			if !reflect.DeepEqual(deleted, tc.expected) || !reflect.DeepEqual(provider.deleted, deleted) {
+				t.Errorf("deleted %v, expected %v", provider.deleted, tc.expected)
+			}
+			if UntilNextStagingCleanup(storage) <= 0 {
+				t.Error("next cleanup is due right away")
+			}
+		})
+	}
+}
@@ -0,0 +1,186 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	backupoperatoriov1 "backup-operator.io/api/v1"
+	backupimport "backup-operator.io/internal/controller/backupImport"
+	backupstorage "backup-operator.io/internal/controller/backupStorage"
+	"backup-operator.io/internal/controller/utils"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+// BackupImportReconciler reconciles a BackupImport object
+type BackupImportReconciler struct {
+	client.Client
+	Config   *rest.Config
+	Scheme   *runtime.Scheme
+	Recorder record.EventRecorder
+}
+
+//+kubebuilder:rbac:groups=backup-operator.io,resources=backupimports,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=backup-operator.io,resources=backupimports/status,verbs=get;update;patch
+//+kubebuilder:rbac:groups=backup-operator.io,resources=backupimports/finalizers,verbs=update
+//+kubebuilder:rbac:groups=backup-operator.io,resources=backupruns,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups="",resources=events,verbs=create;get;list;patch;update;watch
+
+// Reconcile is part of the main kubernetes reconciliation loop which aims to
+// move the current state of the cluster closer to the desired state.
+// (user): Modify the Reconcile function to compare the state specified by
+// the BackupImport object against the actual cluster state, and then
+// perform operations to make the cluster state reflect the state specified by
+// the user.
+//
+// For more details, check Reconcile and its Result here:
+// - https://pkg.go.dev/sigs.k8s.io/controller-runtime@v0.14.1/pkg/reconcile
+func (r *BackupImportReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	return utils.ManageLifecycle(ctx, &utils.ManagedLifecycleReconcile{
+		Client:   r.Client,
+		Config:   r.Config,
+		Scheme:   r.Scheme,
+		Recorder: r.Recorder,
+		Request:  req,
+		Object:   &backupoperatoriov1.BackupImport{},
+	}, &backupImportLifecycle{})
+}
+
+// Implements ManagedLifecycleObject interface
+type backupImportLifecycle struct{}
+
+// ┌─┐┌─┐┌┐┐┐─┐┌┐┐┬─┐┬ ┐┌┐┐┌─┐┬─┐
+// │  │ ││││└─┐ │ │┬┘│ │ │ │ ││┬┘
+// └─┘┘─┘┘└┘──┘ ┘ ┘└┘┘─┘ ┘ ┘─┘┘└┘
+
+func (b *backupImportLifecycle) Constructor(_ context.Context, _ *utils.ManagedLifecycleReconcile) (result ctrl.Result, err error) {
+	return
+}
+
+// ┬─┐┬─┐┐─┐┌┐┐┬─┐┬ ┐┌─┐┌┐┐┌─┐┬─┐
+// │ │├─ └─┐ │ │┬┘│ ││   │ │ ││┬┘
+// ┘─┘┴─┘──┘ ┘ ┘└┘┘─┘└─┘ ┘ ┘─┘┘└┘
+
+func (b *backupImportLifecycle) Destructor(_ context.Context, _ *utils.ManagedLifecycleReconcile) (result ctrl.Result, err error) {
+	// Imported runs are not owned by the import and stay as they are
+	return
+}
+
+// ┬─┐┬─┐┌─┐┌─┐┬─┐┐─┐┐─┐┌─┐┬─┐
+// │─┘│┬┘│ ││  ├─ └─┐└─┐│ ││┬┘
+// ┘  ┘└┘┘─┘└─┘┴─┘──┘──┘┘─┘┘└┘
+
+func (b *backupImportLifecycle) Processor(ctx context.Context, r *utils.ManagedLifecycleReconcile) (result ctrl.Result, err error) {
+	backupImport := r.Object.(*backupoperatoriov1.BackupImport)
+	log := log.FromContext(ctx)
+	if err = r.Client.Get(ctx, client.ObjectKeyFromObject(backupImport), backupImport); err != nil {
+		utils.Log(r, log, err, backupImport, "FailedGet", "could not get the import")
+		return
+	}
+	// Skip if the current generation has been imported already and it is not time to scan again
+	_, triggered := backupImport.Annotations[backupoperatoriov1.AnnotationTriggerImport]
+	ready, _ := utils.GetConditionByType(&backupImport.Status.Conditions, backupoperatoriov1.ConditionTypeReady)
+	if !triggered && ready != nil && ready.Status == metav1.ConditionTrue &&
+		ready.ObservedGeneration == backupImport.Generation && backupImport.Status.LastImportTime != nil {
+		if backupImport.Spec.Interval == nil {
+			return
+		}
+		if result.RequeueAfter = time.Until(backupImport.Status.LastImportTime.Add(importInterval(backupImport))); result.RequeueAfter > 0 {
+			return
+		}
+	}
+	result.RequeueAfter = 0
+	if triggered {
+		if err = utils.SetAnnotations(ctx, r.Client, backupImport, func() (a map[string]string) {
+			a = make(map[string]string)
+			for k, v := range backupImport.GetAnnotations() {
+				if k != backupoperatoriov1.AnnotationTriggerImport {
+					a[k] = v
+				}
+			}
+			return
+		}()); err != nil {
+			utils.Log(r, log, err, backupImport, "FailedUpdate", "failed to remove trigger annotation")
+			return
+		}
+	}
+	// Imported runs are restore-only
+	if backupImport.Spec.Template.Spec.Restore == nil {
+		err = errors.New("restore action is mandatory in the template")
+		utils.Log(r, log, err, backupImport, "InvalidTemplate", "")
+		backupimport.ChangeImportReadiness(ctx, r.Client, backupImport, false, "InvalidTemplate", err.Error())
+		// Nothing changes until the spec is fixed
+		return result, nil
+	}
+	// Trying to get backup storage provider...
+	storageName := backupImport.Spec.Template.Spec.Storage.Name
+	storage, ok := backupstorage.GetBackupStorageProvider(storageName)
+	if !ok {
+		// ...if fail - reschedule
+		utils.Log(r, log, errors.New("FailedFindStorage"), backupImport, "FailedFindStorage",
+			fmt.Sprintf("no storage provider with name %s found, ignore if operator has restarted recently", storageName),
+		)
+		backupimport.ChangeImportReadiness(ctx, r.Client, backupImport, false, "StorageNotReady",
+			fmt.Sprintf("storage %s is not ready", storageName))
+		result.RequeueAfter = time.Second * 20
+		return
+	}
+	// Import files
+	var created []string
+	if created, err = backupimport.ImportFiles(ctx, r.Client, backupImport, storage); err != nil {
+		utils.Log(r, log, err, backupImport, "FailedImport", "failed to import files")
+		backupimport.ChangeImportReadiness(ctx, r.Client, backupImport, false, "FailedImport", err.Error())
+		return
+	}
+	for _, name := range created {
+		utils.Log(r, log, nil, backupImport, "Imported", fmt.Sprintf("created run %s", name))
+	}
+	message := fmt.Sprintf("created %d runs", len(created))
+	if len(backupImport.Status.Skipped) > 0 {
+		message = fmt.Sprintf("%s, skipped %d files", message, len(backupImport.Status.Skipped))
+	}
+	if err = backupimport.ChangeImportReadiness(ctx, r.Client, backupImport, true, "Imported", message); err != nil {
+		utils.Log(r, log, err, backupImport, "FailedUpdateStatus", "failed to update the status")
+		return
+	}
+	if backupImport.Spec.Interval != nil {
+		result.RequeueAfter = importInterval(backupImport)
+	}
+	return
+}
+
+// importInterval Get scan interval of the import, too short ones would make the storage listed in a loop
+func importInterval(backupImport *backupoperatoriov1.BackupImport) time.Duration {
+	return max(backupImport.Spec.Interval.Duration, time.Minute)
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *BackupImportReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&backupoperatoriov1.BackupImport{}).
+		Complete(r)
+}
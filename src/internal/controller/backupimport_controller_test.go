@@ -0,0 +1,272 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	metricsserver "sigs.k8s.io/controller-runtime/pkg/metrics/server"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/yaml"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	backupoperatoriov1 "backup-operator.io/api/v1"
+	backupstorage "backup-operator.io/internal/controller/backupStorage"
+	backupstorageproviders "backup-operator.io/internal/controller/backupStorage/providers"
+)
+
+var _ = Describe("BackupImport Controller", Ordered, func() {
+	const (
+		importName  = "import-test"
+		storageName = "import-test"
+		ownedPath   = "/mysql/20240104-000000.sql.gz"
+	)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	// Runs are listed by storage name with the cache index, while imports are read from the API server,
+	// so status updates do not conflict with a stale cache
+	var c client.Client
+	recorder := &record.FakeRecorder{}
+
+	importKey := client.ObjectKey{Name: importName, Namespace: "default"}
+	listImportedRuns := func() []backupoperatoriov1.BackupRun {
+		runs := &backupoperatoriov1.BackupRunList{}
+		Expect(k8sClient.List(ctx, runs, client.InNamespace("default"),
+			client.MatchingLabels{"app": importName})).To(Succeed())
+		return runs.Items
+	}
+	reconcileImport := func() {
+		reconciler := &BackupImportReconciler{Client: c, Scheme: c.Scheme(), Recorder: recorder}
+		_, err := reconciler.Reconcile(ctx, reconcile.Request{NamespacedName: importKey})
+		Expect(err).NotTo(HaveOccurred())
+	}
+
+	BeforeAll(func() {
+		By("starting the cache with run indexes")
+		mgr, err := ctrl.NewManager(cfg, ctrl.Options{
+			Scheme:  scheme.Scheme,
+			Metrics: metricsserver.Options{BindAddress: "0"},
+			Client: client.Options{Cache: &client.CacheOptions{
+				DisableFor: []client.Object{&backupoperatoriov1.BackupImport{}},
+			}},
+		})
+		Expect(err).NotTo(HaveOccurred())
+		for path, function := range backupRunIndexers {
+			Expect(mgr.GetFieldIndexer().IndexField(ctx, &backupoperatoriov1.BackupRun{}, path, function)).To(Succeed())
+		}
+		go func() {
+			defer GinkgoRecover()
+			Expect(mgr.Start(ctx)).To(Succeed())
+		}()
+		Expect(mgr.GetCache().WaitForCacheSync(ctx)).To(BeTrue())
+		c = mgr.GetClient()
+
+		By("preparing backup files in the filesystem storage")
+		dir := GinkgoT().TempDir()
+		// Format is detected by the first bytes, while compression under encryption is taken from extensions
+		for path, content := range map[string]string{
+			"/mysql/20240101-000000.sql":         "backup",
+			"/mysql/20240102-000000.sql.gz":      "\x1f\x8bbackup",
+			"/mysql/20240103-000000.sql.zst.age": "age-encryption.org/v1\nbackup",
+			ownedPath:                            "\x1f\x8bbackup",
+			"/postgresql/20240101-000000.sql.gz": "\x1f\x8bbackup",
+		} {
+			Expect(os.MkdirAll(filepath.Join(dir, filepath.Dir(path)), 0o755)).To(Succeed())
+			Expect(os.WriteFile(filepath.Join(dir, path), []byte(content), 0o644)).To(Succeed())
+		}
+		storage := &backupstorageproviders.FilesystemStorage{}
+		object := &backupoperatoriov1.BackupStorage{ObjectMeta: metav1.ObjectMeta{Name: storageName}}
+		Expect(storage.Constructor(object, map[string]string{"path": dir}, nil)).To(Succeed())
+		backupstorage.AddBackupStorageProvider(storageName, storage)
+
+		By("creating the run which owns one of the files already")
+		owned := &backupoperatoriov1.BackupRun{}
+		Expect(yaml.Unmarshal([]byte(`
+metadata:
+  name: import-test-owned
+  namespace: default
+spec:
+  retainPolicy: Retain
+  storage:
+    name: `+storageName+`
+    path: `+ownedPath+`
+  restore:
+    container: mysql
+    command: [mysql]
+  template:
+    spec:
+      containers:
+      - name: mysql
+        image: mysql
+`), owned)).To(Succeed())
+		Expect(k8sClient.Create(ctx, owned)).To(Succeed())
+		Eventually(func() error {
+			return c.Get(ctx, client.ObjectKeyFromObject(owned), &backupoperatoriov1.BackupRun{})
+		}).Should(Succeed())
+
+		By("creating the import")
+		backupImport := &backupoperatoriov1.BackupImport{}
+		Expect(yaml.Unmarshal([]byte(`
+metadata:
+  name: `+importName+`
+  namespace: default
+spec:
+  prefix: /mysql/
+  template:
+    metadata:
+      labels:
+        app: `+importName+`
+    spec:
+      retainPolicy: Delete
+      storage:
+        name: `+storageName+`
+        path: /unused
+      backup:
+        container: mysql
+        command: [mysqldump]
+      restore:
+        container: mysql
+        command: [mysql]
+      compression:
+        algorithm: zstd
+        level: 9
+      encryption:
+        recipients:
+        - age1ql3z7hjy54pw3hyww5ayyfg7zqgvc7w3j2elw8zmrj2kg5sfn9aqmcac8p
+        decryptionKey:
+          name: backup-keys
+          key: identity
+      template:
+        spec:
+          containers:
+          - name: mysql
+            image: mysql
+`), backupImport)).To(Succeed())
+		Expect(k8sClient.Create(ctx, backupImport)).To(Succeed())
+	})
+
+	AfterAll(func() {
+		By("cleaning up the import, the runs and the storage")
+		runs := append(listImportedRuns(), backupoperatoriov1.BackupRun{
+			ObjectMeta: metav1.ObjectMeta{Name: "import-test-owned", Namespace: "default"},
+		})
+		objects := []client.Object{&backupoperatoriov1.BackupImport{ObjectMeta: metav1.ObjectMeta{
+			Name: importName, Namespace: "default",
+		}}}
+		for i := range runs {
+			objects = append(objects, &runs[i])
+		}
+		for _, object := range objects {
+			// Finalizers are removed by the lifecycle, which is not the subject here
+			if err := k8sClient.Get(ctx, client.ObjectKeyFromObject(object), object); err == nil {
+				object.SetFinalizers(nil)
+				Expect(k8sClient.Update(ctx, object)).To(Succeed())
+				Expect(client.IgnoreNotFound(k8sClient.Delete(ctx, object))).To(Succeed())
+			}
+		}
+		backupstorage.RemoveBackupStorageProvider(storageName)
+		cancel()
+	})
+
+	It("should create restore-only runs for files without runs", func() {
+		reconcileImport()
+
+		runs := listImportedRuns()
+		paths := make(map[string]backupoperatoriov1.BackupRun, len(runs))
+		for _, run := range runs {
+			paths[run.Spec.Storage.Path] = run
+		}
+		Expect(paths).To(HaveLen(3))
+		Expect(paths).NotTo(HaveKey(ownedPath))
+		Expect(paths).NotTo(HaveKey("/postgresql/20240101-000000.sql.gz"))
+		for _, run := range runs {
+			Expect(run.Spec.Backup).To(BeNil())
+			Expect(run.Spec.Restore).NotTo(BeNil())
+			Expect(*run.Spec.RetainPolicy).To(Equal(backupoperatoriov1.BackupRetainRetain))
+			Expect(run.Annotations).To(HaveKeyWithValue(backupoperatoriov1.AnnotationImportedFrom, importName))
+		}
+
+		By("inferring compression and encryption from the extensions")
+		plain := paths["/mysql/20240101-000000.sql"]
+		Expect(plain.Spec.Compression).To(BeNil())
+		Expect(plain.Spec.Encryption).To(BeNil())
+		gzip := paths["/mysql/20240102-000000.sql.gz"]
+		Expect(gzip.Spec.Compression).NotTo(BeNil())
+		Expect(gzip.Spec.Compression.Algorithm).To(Equal(backupoperatoriov1.GZIP))
+		Expect(gzip.Spec.Compression.Level).To(BeNil())
+		Expect(gzip.Spec.Encryption).To(BeNil())
+		zstd := paths["/mysql/20240103-000000.sql.zst.age"]
+		Expect(zstd.Spec.Compression).NotTo(BeNil())
+		Expect(zstd.Spec.Compression.Algorithm).To(Equal(backupoperatoriov1.ZSTD))
+		Expect(zstd.Spec.Compression.Level).To(HaveValue(BeEquivalentTo(9)))
+		Expect(zstd.Spec.Encryption).NotTo(BeNil())
+
+		backupImport := &backupoperatoriov1.BackupImport{}
+		Expect(k8sClient.Get(ctx, importKey, backupImport)).To(Succeed())
+		Expect(backupImport.Status.Imported).To(HaveValue(BeEquivalentTo(3)))
+		Expect(backupImport.Status.Skipped).To(BeEmpty())
+		Expect(meta.IsStatusConditionTrue(backupImport.Status.Conditions, backupoperatoriov1.ConditionTypeReady)).To(BeTrue())
+	})
+
+	It("should mark imported runs successful without running them", func() {
+		reconciler := &BackupRunReconciler{Client: k8sClient, Scheme: k8sClient.Scheme(), Recorder: recorder}
+		for _, run := range listImportedRuns() {
+			Eventually(func() bool {
+				_, err := reconciler.Reconcile(ctx, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(&run)})
+				Expect(err).NotTo(HaveOccurred())
+				Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(&run), &run)).To(Succeed())
+				return meta.IsStatusConditionTrue(run.Status.Conditions,
+					string(backupoperatoriov1.BackupRunConditionTypeSuccessful))
+			}).Should(BeTrue())
+			Expect(meta.IsStatusConditionTrue(run.Status.Conditions,
+				string(backupoperatoriov1.BackupRunConditionTypeNeverRun))).To(BeFalse())
+			// No Pod is created for the run
+			Expect(run.Status.PodName).To(BeNil())
+		}
+	})
+
+	It("should not import the same files twice", func() {
+		backupImport := &backupoperatoriov1.BackupImport{}
+		Expect(k8sClient.Get(ctx, importKey, backupImport)).To(Succeed())
+		backupImport.Annotations = map[string]string{backupoperatoriov1.AnnotationTriggerImport: "true"}
+		Expect(k8sClient.Update(ctx, backupImport)).To(Succeed())
+		// Wait for the cache to see the created runs, otherwise they would be skipped as already existing
+		Eventually(func() int {
+			runs := &backupoperatoriov1.BackupRunList{}
+			Expect(c.List(ctx, runs, client.MatchingFields{".spec.storage.name": storageName})).To(Succeed())
+			return len(runs.Items)
+		}).Should(Equal(4))
+
+		reconcileImport()
+
+		Expect(listImportedRuns()).To(HaveLen(3))
+		Expect(k8sClient.Get(ctx, importKey, backupImport)).To(Succeed())
+		Expect(backupImport.Annotations).NotTo(HaveKey(backupoperatoriov1.AnnotationTriggerImport))
+		Expect(backupImport.Status.Imported).To(HaveValue(BeEquivalentTo(3)))
+		Expect(backupImport.Status.Skipped).To(BeEmpty())
+	})
+})
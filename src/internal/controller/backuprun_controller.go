@@ -102,9 +102,11 @@ func (b *backupRunLifecycle) Constructor(ctx context.Context, r *utils.ManagedLi
 		}
 		// Check compression
 		if state.Compressed {
-			r.Recorder.Eventf(run, corev1.EventTypeNormal, "Compression",
-				fmt.Sprintf("%s with level %d", run.Spec.Compression.Algorithm, run.Spec.Compression.Level),
-			)
+			r.Recorder.Eventf(run, corev1.EventTypeNormal, "Compression", run.Spec.Compression.Description())
+		}
+		compressedMessage := utils.EventReasonInitializing
+		if state.Compressed {
+			compressedMessage = run.Spec.Compression.Description()
 		}
 		// Create respective conditions
 		run.Status.Conditions = *utils.AddConditions(run.Status.Conditions,
@@ -168,7 +170,7 @@ func (b *backupRunLifecycle) Constructor(ctx context.Context, r *utils.ManagedLi
 				Type:               string(backupoperatoriov1.BackupRunConditionTypeCompressed),
 				Status:             utils.ToConditionStatus(&state.Compressed),
 				Reason:             utils.EventReasonInitializing,
-				Message:            utils.EventReasonInitializing,
+				Message:            compressedMessage,
 				LastTransitionTime: metav1.Now(),
 				ObservedGeneration: run.Generation,
 			},
@@ -212,8 +214,27 @@ func (b *backupRunLifecycle) Destructor(ctx context.Context, r *utils.ManagedLif
 			return
 		}
 		log = log.WithValues("storageName", run.Spec.Storage.Name, "backupPath", run.Spec.Storage.Path)
+		// Legal hold may be released by removing the annotation from the run under deletion
+		if _, err = backuprun.SyncLegalHold(ctx, r.Client, run, storage); err != nil {
+			utils.Log(r, log, err, run, "FailedLegalHold", "failed to change legal hold of the backup")
+		}
 		utils.Log(r, log, err, run, "DeletingFromStorage", fmt.Sprintf("deleting backup at %s", run.Spec.Storage.Path))
-		if err = storage.Delete(ctx, run.Spec.Storage.Path); err != nil {
+		if err = storage.Delete(ctx, run.Spec.Storage.Path); errors.Is(err, backupstorage.ErrObjectLocked) {
+			// Retrying every minute is useless, so wait until the retention is over
+			message, retryAfter := backuprun.DescribeObjectLock(ctx, run, storage)
+			utils.Log(r, log, err, run, "DeletionBlocked", message)
+			if err = backuprun.SetRunCondition(ctx, r.Client, run, backupoperatoriov1.BackupRunConditionTypeDeletionBlocked,
+				metav1.ConditionTrue, "ObjectLocked", message); err != nil {
+				utils.Log(r, log, err, run, "FailedUpdateStatus", "failed to set DeletionBlocked condition")
+			}
+			// Legal hold and locks which could not be read have no end date,
+			// hold release or spec change triggers reconciliation anyway
+			result.RequeueAfter = 24 * time.Hour
+			if retryAfter > 0 {
+				result.RequeueAfter = min(max(retryAfter, time.Minute), result.RequeueAfter)
+			}
+			return result, nil
+		} else if err != nil {
 			utils.Log(r, log, err, run, "FailedDeletion", "failed to delete the backup from storage")
 			result.RequeueAfter = time.Minute
 			return
@@ -244,6 +265,41 @@ func (b *backupRunLifecycle) Processor(ctx context.Context, r *utils.ManagedLife
 	}
 	// Exit if we do not have to run
 	if !state.HaveToBackup && !state.HaveToRestore {
+		// Imported backup is complete from the very beginning
+		if state.Imported && state.NeverRun {
+			utils.Log(r, log, err, run, "Imported", "existing backup has been imported")
+			if err = backuprun.ChangeRunState(ctx, r.Client, run, backupoperatoriov1.BackupRunConditionTypeSuccessful, state); err != nil {
+				utils.Log(r, log, err, run, "FailedChangeState", "failed to change the state")
+				return
+			}
+			if storage, ok := backupstorage.GetBackupStorageProvider(run.Spec.Storage.Name); ok {
+				if err = backuprun.SetBackupSizeInStatus(ctx, r.Client, run, storage); err != nil {
+					utils.Log(r, log, err, run, "FailedSetBackupSize", "failed to set backup size in status")
+					// No need to fail, that is not critical
+					err = nil
+				}
+			}
+		}
+		// Save checksums which could not be saved right after the backup
+		if state.Successful {
+			if err = backuprun.SetPendingChecksums(ctx, r.Client, run); err != nil {
+				utils.Log(r, log, err, run, "FailedSetChecksums", "failed to set checksums in status")
+				return
+			}
+		}
+		// Keep legal hold of the backup in sync with the annotation
+		if state.Successful {
+			if storage, ok := backupstorage.GetBackupStorageProvider(run.Spec.Storage.Name); ok {
+				var changed bool
+				if changed, err = backuprun.SyncLegalHold(ctx, r.Client, run, storage); err != nil {
+					utils.Log(r, log, err, run, "FailedLegalHold", "failed to change legal hold of the backup")
+					result.RequeueAfter = time.Minute
+					err = nil
+				} else if changed {
+					utils.Log(r, log, err, run, "LegalHold", "legal hold of the backup has been changed")
+				}
+			}
+		}
 		// Update metrics
 		backuprun.UpdateMetric(run)
 		return
@@ -259,6 +315,48 @@ func (b *backupRunLifecycle) Processor(ctx context.Context, r *utils.ManagedLife
 		result.RequeueAfter = time.Second * 20
 		return
 	}
+	// New backups are held while the storage is over quota
+	if state.HaveToBackup {
+		var exceeded bool
+		var message string
+		if exceeded, message, err = backuprun.CheckStorageQuota(ctx, r.Client, run); err != nil {
+			utils.Log(r, log, err, run, "FailedCheckQuota", "failed to check the storage quota")
+			return
+		}
+		held, _ := utils.GetConditionByType(&run.Status.Conditions, string(backupoperatoriov1.BackupRunConditionTypeQuotaExceeded))
+		if exceeded {
+			if held == nil {
+				utils.Log(r, log, errors.New(message), run, "QuotaExceeded", "backup is held")
+			}
+			if err = backuprun.SetRunCondition(ctx, r.Client, run, backupoperatoriov1.BackupRunConditionTypeQuotaExceeded,
+				metav1.ConditionTrue, "QuotaExceeded", message); err != nil {
+				utils.Log(r, log, err, run, "FailedUpdateStatus", "failed to set QuotaExceeded condition")
+			}
+			// Storage changes do not trigger runs reconciliation
+			result.RequeueAfter = time.Minute
+			return result, nil
+		} else if held != nil {
+			utils.Log(r, log, nil, run, "WithinQuota", "storage usage is within the quota, backup is not held anymore")
+			if err = backuprun.RemoveRunCondition(ctx, r.Client, run, backupoperatoriov1.BackupRunConditionTypeQuotaExceeded); err != nil {
+				utils.Log(r, log, err, run, "FailedUpdateStatus", "failed to remove QuotaExceeded condition")
+				return
+			}
+		}
+	}
+	// Archived backups have to be rehydrated before the restoration, it may take hours
+	if state.HaveToRestore {
+		var pending bool
+		if pending, err = backuprun.Rehydrate(ctx, r.Client, run, storage); err != nil {
+			// Retried with backoff, the error may be transient
+			utils.Log(r, log, err, run, "FailedRehydration", "failed to rehydrate the backup")
+			return
+		}
+		if pending {
+			utils.Log(r, log, err, run, "Rehydrating", "waiting for the backup to be restored from archive")
+			result.RequeueAfter = 10 * time.Minute
+			return
+		}
+	}
 	// Set InProgress to true
 	utils.Log(r, log, err, run, "InProgress", "run is in progress")
 	if err = backuprun.ChangeRunState(ctx, r.Client, run, backupoperatoriov1.BackupRunConditionTypeInProgress, state); err != nil {
@@ -295,7 +393,12 @@ func (b *backupRunLifecycle) Processor(ctx context.Context, r *utils.ManagedLife
 	switch {
 	case state.HaveToBackup:
 		utils.Log(r, log, err, run, "MakingBackup", "creating a new backup")
-		if err = backuprun.Backup(ctx, r.Client, r.Scheme, r.Config, run, pod, storage); err != nil {
+		if err = backuprun.Backup(ctx, r.Client, r.Scheme, r.Config, run, pod, storage); errors.Is(err, backuprun.ErrChecksumsPending) {
+			// Backup is published already, so the run is successful, checksums are saved on the next reconciliation
+			utils.Log(r, log, err, run, "FailedSetChecksums", "failed to set checksums in status, will retry")
+			result.RequeueAfter = time.Minute
+			err = nil
+		} else if err != nil {
 			utils.Log(r, log, err, run, "FailedBackup", "failed to make a backup")
 			backuprun.ChangeRunState(ctx, r.Client, run, backupoperatoriov1.BackupRunConditionTypeFailed, state)
 			return
@@ -318,6 +421,8 @@ func (b *backupRunLifecycle) Processor(ctx context.Context, r *utils.ManagedLife
 		}())
 		// Start restoration
 		if err = backuprun.Restore(ctx, r.Client, r.Scheme, r.Config, run, pod, storage); err != nil {
+			state.ChecksumMismatch = errors.Is(err, backuprun.ErrChecksumMismatch)
+			state.FormatMismatch = errors.Is(err, backuprun.ErrFormatMismatch)
 			backuprun.ChangeRunState(ctx, r.Client, run, backupoperatoriov1.BackupRunConditionTypeFailed, state)
 			// Make failure restoration event
 			utils.Log(r, log, err, run, "FailedRestore", "failed to restore a backup")
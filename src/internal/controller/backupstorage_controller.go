@@ -18,6 +18,7 @@ package controller
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"sync"
 
@@ -29,18 +30,23 @@ import (
 	"k8s.io/client-go/tools/record"
 	"k8s.io/client-go/util/retry"
 	"k8s.io/utils/ptr"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/controller"
+	"sigs.k8s.io/controller-runtime/pkg/event"
 	"sigs.k8s.io/controller-runtime/pkg/handler"
 	"sigs.k8s.io/controller-runtime/pkg/log"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
 
 	corev1 "k8s.io/api/core/v1"
 	ctrl "sigs.k8s.io/controller-runtime"
 
 	backupoperatoriov1 "backup-operator.io/api/v1"
 	backupstorage "backup-operator.io/internal/controller/backupStorage"
-	backupstorageproviders "backup-operator.io/internal/controller/backupStorage/providers"
+	"backup-operator.io/internal/controller/backupStorage/dedup"
+	// Register built-in storage providers
+	_ "backup-operator.io/internal/controller/backupStorage/providers"
 	utils "backup-operator.io/internal/controller/utils"
 	"backup-operator.io/internal/monitoring"
 )
@@ -89,6 +95,9 @@ type backupStorageLifecycle struct{}
 // we have to call provider.Constructor. We have both parameters and credentials content.
 var storageProvidersConfigurationHashes = &sync.Map{}
 
+// Map with hashes of credentials of configured providers to tell credentials rotation from other changes
+var storageCredentialsHashes = &sync.Map{}
+
 // ┌─┐┌─┐┌┐┐┐─┐┌┐┐┬─┐┬ ┐┌┐┐┌─┐┬─┐
 // │  │ ││││└─┐ │ │┬┘│ │ │ │ ││┬┘
 // └─┘┘─┘┘└┘──┘ ┘ ┘└┘┘─┘ ┘ ┘─┘┘└┘
@@ -139,8 +148,15 @@ func (b *backupStorageLifecycle) Destructor(ctx context.Context, r *utils.Manage
 	}
 	// Forget its configuration hash
 	storageProvidersConfigurationHashes.Delete(storage.GetUID())
-	// Delete metric
+	storageCredentialsHashes.Delete(storage.GetUID())
+	// Delete metrics
 	backupstorage.DeleteMetric(storage)
+	backupstorage.DeleteInventoryMetric(storage)
+	backupstorage.DeleteQuotaMetric(storage)
+	backupstorage.DeleteOperationMetric(storage)
+	backupstorage.ForgetInventory(storage)
+	backupstorage.ForgetHealth(storage)
+	backupstorage.ForgetStagingCleanup(storage)
 	return
 }
 
@@ -162,7 +178,7 @@ func (b *backupStorageLifecycle) Processor(ctx context.Context, r *utils.Managed
 		secret := &corev1.Secret{}
 		secret.Name = storage.Spec.Credentials.Name
 		secret.Namespace = storage.Spec.Credentials.Namespace
-		// Read credentials secret
+		// Read credentials secret, it is never cached, so a rotated one is read right away
 		if err = r.Client.Get(ctx, client.ObjectKeyFromObject(secret), secret); err != nil {
 			err = fmt.Errorf("could not get credentials secret: %s", err.Error())
 			utils.Log(r, log, err, storage, "FailedGetCredentials", "")
@@ -171,47 +187,61 @@ func (b *backupStorageLifecycle) Processor(ctx context.Context, r *utils.Managed
 		}
 		credentials = utils.DecodeSecretData(secret)
 	}
-	// Creating respective provider
-	provider, providerExists := backupstorage.GetBackupStorageProvider(storage.Name)
-	switch storage.Spec.Type {
-	case "s3":
-		// If provider is not listed in BackupStorageProviders map - create new one
-		if !providerExists {
-			provider = &backupstorageproviders.S3Storage{}
-			if err = defaults.Set(provider); err != nil {
-				err = fmt.Errorf("could not set default provider settings: %s", err.Error())
-				utils.Log(r, log, err, storage, "FailedSetDefaults", "")
-				backupstorage.ChangeStorageReadiness(ctx, r.Client, storage, false, err.Error())
-				return
-			}
-		}
-	default:
-		// Error if type is unknown
-		err = fmt.Errorf("unknown storage type: %s", storage.Spec.Type)
-		utils.Log(r, log, err, storage, "UnknownType", "")
-		backupstorage.ChangeStorageReadiness(ctx, r.Client, storage, false, err.Error())
-		return
-	}
 	// Configure provider
-	hash := utils.Hash(storage.Spec.Parameters, credentials)
-	// If has differs or not found...
-	_, found := backupstorage.GetBackupStorageProvider(storage.Name)
+	hash := utils.Hash(storage.Spec.Parameters, credentials, dedup.GetSettings(storage))
+	provider, providerExists := backupstorage.GetBackupStorageProvider(storage.Name)
+	// If hash differs or storage type has been changed...
 	var oldHash any
-	if oldHash, _ = storageProvidersConfigurationHashes.Load(storage.UID); !found || hash != oldHash {
-		// ...and construct provider (for the first time or again, does not matter)
+	if oldHash, _ = storageProvidersConfigurationHashes.Load(storage.UID); !providerExists || hash != oldHash ||
+		provider.GetObject().Spec.Type != storage.Spec.Type {
+		// ...create a new provider, the current one is used by running backups and restores as is
+		registration, registered := backupstorage.GetProviderRegistration(string(storage.Spec.Type))
+		if !registered {
+			// Error if type is unknown
+			err = fmt.Errorf("unknown storage type: %s", storage.Spec.Type)
+			utils.Log(r, log, err, storage, "UnknownType", "")
+			backupstorage.ChangeStorageReadiness(ctx, r.Client, storage, false, err.Error())
+			return
+		}
+		provider = registration.Factory()
+		if err = defaults.Set(provider); err != nil {
+			err = fmt.Errorf("could not set default provider settings: %s", err.Error())
+			utils.Log(r, log, err, storage, "FailedSetDefaults", "")
+			backupstorage.ChangeStorageReadiness(ctx, r.Client, storage, false, err.Error())
+			return
+		}
+		// ...and construct it
 		if err = provider.Constructor(storage, storage.Spec.Parameters, credentials); err != nil {
+			// Release whatever has been set up before the failure
+			provider.Destructor()
 			err = fmt.Errorf("could not configure the provider %s: %s", storage.Spec.Type, err.Error())
 			utils.Log(r, log, err, storage, "FailedConfigure", "")
 			backupstorage.ChangeStorageReadiness(ctx, r.Client, storage, false, err.Error())
 			return
 		}
 		utils.Log(r, log, err, storage, "Reconciled", "(Re)configured successfully")
+		// ...record credentials rotation
+		credentialsHash := utils.Hash(credentials)
+		if oldCredentialsHash, known := storageCredentialsHashes.Load(storage.UID); known &&
+			oldCredentialsHash != credentialsHash && storage.Spec.Credentials != nil {
+			utils.Log(r, log, nil, storage, "CredentialsRotated", fmt.Sprintf("credentials from secret %s/%s have been rotated",
+				storage.Spec.Credentials.Namespace, storage.Spec.Credentials.Name))
+		}
+		storageCredentialsHashes.Store(storage.UID, credentialsHash)
 		// ...save hash
 		storageProvidersConfigurationHashes.Store(storage.UID, hash)
-		// Add backup storage provider to memory
-		backupstorage.AddBackupStorageProvider(storage.Name, provider)
+		// Replace backup storage provider in memory, the old one is destroyed when its operations are over
+		backupstorage.ReplaceBackupStorageProvider(storage.Name, dedup.Wrap(provider, storage), func(err error) {
+			if err != nil {
+				utils.Log(r, log, err, storage, "FailedDestruct", "failed to destroy the previous provider")
+			}
+		})
+		// Check the new configuration right away
+		backupstorage.ForgetHealth(storage)
 	}
 	// Count child schedules
+	var quotaExceeded, quotaNewlyExceeded bool
+	var quotaMessage string
 	if err = retry.RetryOnConflict(retry.DefaultRetry, func() error {
 		if err = r.Client.Get(ctx, client.ObjectKeyFromObject(storage), storage); err != nil {
 			utils.Log(r, log, err, storage, "FailedGet", "could not get the storage")
@@ -239,18 +269,62 @@ func (b *backupStorageLifecycle) Processor(ctx context.Context, r *utils.Managed
 		storage.Status.Runs = ptr.To(uint16(len(childRuns.Items)))
 		storage.Status.SizeInBytes = ptr.To(childRunsSizeInBytes)
 		storage.Status.Size = ptr.To(utils.ConvertBytesToHumanReadable(childRunsSizeInBytes))
-		backupstorage.ChangeStorageReadiness(ctx, r.Client, storage, true, "Provider object is reconfigured and ready to work")
+		if healthErr := backupstorage.GetHealthError(storage); healthErr != nil && backupstorage.HealthCheckEnabled(storage) {
+			backupstorage.ChangeStorageReadiness(ctx, r.Client, storage, false, fmt.Sprintf("health check has failed: %s", healthErr))
+		} else {
+			backupstorage.ChangeStorageReadiness(ctx, r.Client, storage, true, "Provider object is reconfigured and ready to work")
+		}
+		// Check quota with the new totals
+		quotaExceeded, quotaMessage = backupstorage.CheckQuota(storage, childRuns.Items)
+		quotaNewlyExceeded = quotaExceeded
+		condition := metav1.Condition{
+			Type:               backupoperatoriov1.ConditionTypeQuotaExceeded,
+			Status:             utils.ToConditionStatus(&quotaExceeded),
+			Reason:             "WithinQuota",
+			Message:            "Storage usage is within the quota",
+			LastTransitionTime: metav1.Now(),
+			ObservedGeneration: storage.Generation,
+		}
+		if quotaExceeded {
+			condition.Reason = "QuotaExceeded"
+			condition.Message = quotaMessage
+		}
+		if old, err := utils.GetConditionByType(&storage.Status.Conditions, condition.Type); err == nil && old.Status == condition.Status {
+			condition.LastTransitionTime = old.LastTransitionTime
+			quotaNewlyExceeded = false
+		}
+		if storage.Spec.Quota == nil {
+			storage.Status.Conditions = *utils.RemoveCondition(storage.Status.Conditions, condition)
+		} else {
+			storage.Status.Conditions = *utils.AddOrUpdateConditions(storage.Status.Conditions, condition)
+		}
 		return r.Client.Status().Update(ctx, storage)
 	}); err != nil {
 		backupstorage.ChangeStorageReadiness(ctx, r.Client, storage, false, err.Error())
 		return
 	}
+	if quotaNewlyExceeded {
+		utils.Log(r, log, errors.New(quotaMessage), storage, "QuotaExceeded", "new backups are held")
+	}
 	// Update metrics
 	backupstorage.UpdateMetric(storage)
+	backupstorage.UpdateQuotaMetric(storage, quotaExceeded)
+	// Health checks, inventory and staging cleanup are run by backupStorageTasks
+	if storage.Spec.Inventory == nil {
+		backupstorage.DeleteInventoryMetric(storage)
+		backupstorage.ForgetStagingCleanup(storage)
+	}
 	return
 }
 
 var backupStorageIndexers = map[string]client.IndexerFunc{
+	".spec.credentials": func(o client.Object) []string {
+		storage := o.(*backupoperatoriov1.BackupStorage)
+		if storage.Spec.Credentials == nil {
+			return nil
+		}
+		return []string{fmt.Sprintf("%s/%s", storage.Spec.Credentials.Namespace, storage.Spec.Credentials.Name)}
+	},
 	".metadata.controller": func(o client.Object) []string {
 		owner := metav1.GetControllerOf(o)
 		if owner == nil {
@@ -268,8 +342,20 @@ func (r *BackupStorageReconciler) SetupWithManager(mgr ctrl.Manager) error {
 			return err
 		}
 	}
+	// Run periodic tasks of storages in the background
+	tasks := &backupStorageTasks{
+		Reader:   mgr.GetAPIReader(),
+		Client:   r.Client,
+		Recorder: r.Recorder,
+		events:   make(chan event.GenericEvent, 16),
+	}
+	if err := mgr.Add(tasks); err != nil {
+		return err
+	}
 	return ctrl.NewControllerManagedBy(mgr).
 		For(&backupoperatoriov1.BackupStorage{}).
+		// Reconcile storages whose health has changed
+		WatchesRawSource(source.Channel(tasks.events, &handler.EnqueueRequestForObject{})).
 		Watches(&backupoperatoriov1.BackupSchedule{}, handler.EnqueueRequestsFromMapFunc(
 			func(_ context.Context, o client.Object) []reconcile.Request {
 				schedule := o.(*backupoperatoriov1.BackupSchedule)
@@ -294,6 +380,26 @@ func (r *BackupStorageReconciler) SetupWithManager(mgr ctrl.Manager) error {
 				}
 			}),
 		).
+		// Reconfigure providers as soon as their credentials are rotated. Only metadata of Secrets is
+		// cached, since the credentials Secret is read from the API server while the provider is configured.
+		Watches(&corev1.Secret{}, handler.EnqueueRequestsFromMapFunc(
+			func(ctx context.Context, o client.Object) (requests []reconcile.Request) {
+				storages := &backupoperatoriov1.BackupStorageList{}
+				if err := r.List(ctx, storages, client.MatchingFields{
+					".spec.credentials": fmt.Sprintf("%s/%s", o.GetNamespace(), o.GetName()),
+				}); err != nil {
+					log.FromContext(ctx).Error(err, "could not list storages using the secret")
+					return
+				}
+				for _, storage := range storages.Items {
+					requests = append(requests, reconcile.Request{
+						NamespacedName: types.NamespacedName{Name: storage.Name},
+					})
+				}
+				return
+			}),
+			builder.OnlyMetadata,
+		).
 		WithOptions(controller.Options{MaxConcurrentReconciles: 10}).
 		Complete(r)
 }
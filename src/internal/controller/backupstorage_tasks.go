@@ -0,0 +1,198 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	backupoperatoriov1 "backup-operator.io/api/v1"
+	backupstorage "backup-operator.io/internal/controller/backupStorage"
+	utils "backup-operator.io/internal/controller/utils"
+)
+
+const (
+	// How often storages are checked for due tasks, it is the minimum health check interval
+	storageTasksPeriod = 10 * time.Second
+	// Timeout of the inventory and the staging cleanup, the health check has its own one
+	storageTaskTimeout = 30 * time.Minute
+)
+
+// backupStorageTasks Runs periodic tasks of storages: health checks, inventory and staging cleanup.
+// They may take long on large buckets or slow storages, so they run in the background instead of
+// holding reconcile workers. Storages are reconciled again when their health changes.
+type backupStorageTasks struct {
+	// Storages are read from the API server, so tasks are never repeated because of a stale cache
+	Reader   client.Reader
+	Client   client.Client
+	Recorder record.EventRecorder
+	// Storages to reconcile
+	events chan event.GenericEvent
+	// Running tasks by storage UID and task name
+	running sync.Map
+	wg      sync.WaitGroup
+}
+
+// Start Check storages for due tasks until the manager stops, implements manager.Runnable
+func (t *backupStorageTasks) Start(ctx context.Context) error {
+	ticker := time.NewTicker(storageTasksPeriod)
+	defer ticker.Stop()
+	defer t.wg.Wait()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+			t.runDueTasks(ctx)
+		}
+	}
+}
+
+// NeedLeaderElection Tasks change storages, so only the leader runs them
+func (t *backupStorageTasks) NeedLeaderElection() bool {
+	return true
+}
+
+// runDueTasks Start tasks whose time has come and which are not running already
+func (t *backupStorageTasks) runDueTasks(ctx context.Context) {
+	storages := &backupoperatoriov1.BackupStorageList{}
+	if err := t.Reader.List(ctx, storages); err != nil {
+		log.FromContext(ctx).Error(err, "could not list storages for periodic tasks")
+		return
+	}
+	for i := range storages.Items {
+		storage := &storages.Items[i]
+		if !storage.DeletionTimestamp.IsZero() {
+			continue
+		}
+		// Provider is configured by the reconciliation
+		provider, ok := backupstorage.GetBackupStorageProvider(storage.Name)
+		if !ok {
+			continue
+		}
+		if backupstorage.HealthCheckEnabled(storage) && backupstorage.UntilNextHealthCheck(storage) <= 0 {
+			t.run(ctx, storage, "health", func(ctx context.Context) {
+				t.checkHealth(ctx, storage, provider)
+			})
+		}
+		if storage.Spec.Inventory == nil {
+			continue
+		}
+		if backupstorage.UntilNextInventory(storage) <= 0 {
+			t.run(ctx, storage, "inventory", func(ctx context.Context) {
+				ctx, cancel := context.WithTimeout(ctx, storageTaskTimeout)
+				defer cancel()
+				t.takeInventory(ctx, storage, provider)
+			})
+		}
+		// Staging cleanup is enabled along with the inventory
+		if backupstorage.UntilNextStagingCleanup(storage) <= 0 {
+			t.run(ctx, storage, "staging", func(ctx context.Context) {
+				ctx, cancel := context.WithTimeout(ctx, storageTaskTimeout)
+				defer cancel()
+				t.cleanStaging(ctx, storage, provider)
+			})
+		}
+	}
+}
+
+// run Start the task of the storage in the background unless it is running already
+func (t *backupStorageTasks) run(ctx context.Context, storage *backupoperatoriov1.BackupStorage,
+	name string, task func(ctx context.Context),
+) {
+	key := fmt.Sprintf("%s/%s", storage.UID, name)
+	if _, running := t.running.LoadOrStore(key, struct{}{}); running {
+		return
+	}
+	t.wg.Add(1)
+	go func() {
+		defer t.wg.Done()
+		defer t.running.Delete(key)
+		task(log.IntoContext(ctx, log.FromContext(ctx).WithValues("BackupStorage", storage.Name, "task", name)))
+	}()
+}
+
+// checkHealth Probe the storage and reconcile it if its health has changed
+func (t *backupStorageTasks) checkHealth(ctx context.Context, storage *backupoperatoriov1.BackupStorage,
+	provider backupstorage.BackupStorageProvider,
+) {
+	previous := backupstorage.GetHealthError(storage)
+	err := backupstorage.CheckHealth(ctx, storage, provider)
+	if err != nil {
+		t.log(ctx, err, storage, "FailedHealthCheck", "storage health check has failed")
+	}
+	// Readiness is set by the reconciliation
+	if (previous == nil) != (err == nil) {
+		select {
+		case t.events <- event.GenericEvent{Object: storage}:
+		case <-ctx.Done():
+		}
+	}
+}
+
+// takeInventory Take inventory of the storage and report changes
+func (t *backupStorageTasks) takeInventory(ctx context.Context, storage *backupoperatoriov1.BackupStorage,
+	provider backupstorage.BackupStorageProvider,
+) {
+	inventory, err := backupstorage.TakeInventory(ctx, t.Client, storage, provider)
+	if err != nil {
+		t.log(ctx, err, storage, "FailedInventory", "failed to take inventory of the storage")
+		return
+	}
+	if len(inventory.NewOrphans) > 0 {
+		t.log(ctx, fmt.Errorf("%d new files no run points at, e.g. %s",
+			len(inventory.NewOrphans), inventory.NewOrphans[0]), storage, "OrphanedObjects", "")
+	}
+	if len(inventory.NewMissing) > 0 {
+		t.log(ctx, fmt.Errorf("%d runs have lost their files, e.g. %s",
+			len(inventory.NewMissing), inventory.NewMissing[0]), storage, "MissingObjects", "")
+	}
+	for _, path := range inventory.Deleted {
+		t.log(ctx, nil, storage, "DeletedOrphan", fmt.Sprintf("deleted orphaned file %s", path))
+	}
+	for path, err := range inventory.FailedDeletions {
+		t.log(ctx, err, storage, "FailedDeleteOrphan", fmt.Sprintf("failed to delete orphaned file %s", path))
+	}
+}
+
+// cleanStaging Delete staging files left by deleted runs
+func (t *backupStorageTasks) cleanStaging(ctx context.Context, storage *backupoperatoriov1.BackupStorage,
+	provider backupstorage.BackupStorageProvider,
+) {
+	deleted, err := backupstorage.CleanStaging(ctx, t.Client, storage, provider)
+	for _, path := range deleted {
+		t.log(ctx, nil, storage, "DeletedStaging", fmt.Sprintf("deleted staging file %s of a deleted run", path))
+	}
+	if err != nil {
+		t.log(ctx, err, storage, "FailedStagingCleanup", "failed to delete staging files")
+	}
+}
+
+// log Log and record the event like reconcilers do
+func (t *backupStorageTasks) log(ctx context.Context, err error, storage *backupoperatoriov1.BackupStorage,
+	reason, message string,
+) {
+	utils.Log(&utils.ManagedLifecycleReconcile{Client: t.Client, Recorder: t.Recorder},
+		log.FromContext(ctx), err, storage, reason, message)
+}
@@ -32,6 +32,9 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/client"
 )
 
+// Version of the operator, it is set on build with -ldflags "-X backup-operator.io/internal/controller/utils.Version=..."
+var Version = "dev"
+
 // Common event logging reasons
 const (
 	EventReasonReady        string = "Ready"
@@ -40,6 +40,42 @@ var (
 		Name:      "status",
 		Help:      "BackupStorage execution status.",
 	}, []string{"name", "type"})
+	BackupOperatorStorageOrphanedObjectsFullName = fmt.Sprintf("%s_%s_%s", metricsNamespace, "storage", "orphaned_objects")
+	BackupOperatorStorageOrphanedObjects         = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: metricsNamespace,
+		Subsystem: "storage",
+		Name:      "orphaned_objects",
+		Help:      "Count of files in the storage that no BackupRun points at.",
+	}, []string{"name"})
+	BackupOperatorStorageMissingObjectsFullName = fmt.Sprintf("%s_%s_%s", metricsNamespace, "storage", "missing_objects")
+	BackupOperatorStorageMissingObjects         = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: metricsNamespace,
+		Subsystem: "storage",
+		Name:      "missing_objects",
+		Help:      "Count of completed BackupRuns whose files are absent in the storage.",
+	}, []string{"name"})
+	BackupOperatorStorageQuotaExceededFullName = fmt.Sprintf("%s_%s_%s", metricsNamespace, "storage", "quota_exceeded")
+	BackupOperatorStorageQuotaExceeded         = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: metricsNamespace,
+		Subsystem: "storage",
+		Name:      "quota_exceeded",
+		Help:      "Set to 1 if the storage usage is over its quota and new backups are held.",
+	}, []string{"name"})
+	BackupOperatorStorageOperationDurationSecondsFullName = fmt.Sprintf("%s_%s_%s", metricsNamespace, "storage", "operation_duration_seconds")
+	BackupOperatorStorageOperationDurationSeconds         = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: metricsNamespace,
+		Subsystem: "storage",
+		Name:      "operation_duration_seconds",
+		Help:      "Duration of storage operations. Put includes the upload, get includes opening the file only.",
+		Buckets:   prometheus.ExponentialBuckets(0.005, 4, 10),
+	}, []string{"name", "operation"})
+	BackupOperatorStorageOperationErrorsTotalFullName = fmt.Sprintf("%s_%s_%s", metricsNamespace, "storage", "operation_errors_total")
+	BackupOperatorStorageOperationErrorsTotal         = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Subsystem: "storage",
+		Name:      "operation_errors_total",
+		Help:      "Count of failed storage operations.",
+	}, []string{"name", "operation"})
 	// ┐─┐┌─┐┬ ┬┬─┐┬─┐┬ ┐┬  ┬─┐
 	// └─┐│  │─┤├─ │ ││ ││  ├─
 	// ──┘└─┘┘ ┴┴─┘┘─┘┘─┘┘─┘┴─┘
@@ -83,6 +119,11 @@ var (
 // RegisterMetrics registers all metrics in the Metrics map with Prometheus's global registry.
 func RegisterMetrics() {
 	metrics.Registry.MustRegister(BackupOperatorStorageStatus)
+	metrics.Registry.MustRegister(BackupOperatorStorageOrphanedObjects)
+	metrics.Registry.MustRegister(BackupOperatorStorageMissingObjects)
+	metrics.Registry.MustRegister(BackupOperatorStorageQuotaExceeded)
+	metrics.Registry.MustRegister(BackupOperatorStorageOperationDurationSeconds)
+	metrics.Registry.MustRegister(BackupOperatorStorageOperationErrorsTotal)
 	metrics.Registry.MustRegister(BackupOperatorScheduleStatus)
 	metrics.Registry.MustRegister(BackupOperatorRunStatus)
 	metrics.Registry.MustRegister(BackupOperatorRunBackupSizeBytes)